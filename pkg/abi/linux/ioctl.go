@@ -54,7 +54,7 @@ const (
 	TIOCCONS    = 0x0000541d
 	TIOCSSERIAL = 0x0000541f
 	TIOCGEXCL   = 0x80045440
-	TIOCGPTPEER = 0x80045441
+	TIOCGPTPEER = 0x00005441
 	TIOCGICOUNT = 0x0000545d
 	FIONCLEX    = 0x00005450
 	FIOCLEX     = 0x00005451
@@ -0,0 +1,104 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// RotatingWriter is an io.Writer that spreads writes over a fixed set of
+// files, moving on to the next file once the current one reaches a size
+// threshold.
+//
+// All files are opened by the caller up front, so rotation never needs to
+// open or rename paths. This allows it to be used by processes that have lost
+// access to the host filesystem, e.g. the sandbox and gofer after chroot and
+// syscall filter installation. When every file has been used, the oldest one
+// is truncated and reused.
+type RotatingWriter struct {
+	// maxSize is the size threshold in bytes. Immutable.
+	maxSize int64
+
+	// files are the files to rotate over. Immutable.
+	files []*os.File
+
+	mu sync.Mutex
+
+	// cur is the index of the file currently being written to.
+	cur int
+
+	// size is the number of bytes written to the current file.
+	size int64
+}
+
+// NewRotatingWriter creates a RotatingWriter over the given files. Writing
+// starts at the end of files[0].
+func NewRotatingWriter(files []*os.File, maxSize int64) (*RotatingWriter, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to rotate over")
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid max size %d", maxSize)
+	}
+	fi, err := files[0].Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat(%q): %v", files[0].Name(), err)
+	}
+	return &RotatingWriter{
+		maxSize: maxSize,
+		files:   files,
+		size:    fi.Size(),
+	}, nil
+}
+
+// Write implements io.Writer.Write.
+//
+// A single write is never split across files, so log lines are kept intact
+// even if that means exceeding the size threshold.
+func (w *RotatingWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(b)) > w.maxSize {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.files[w.cur].Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotateLocked switches to the next file and discards its previous contents.
+//
+// Precondition: w.mu must be locked.
+func (w *RotatingWriter) rotateLocked() error {
+	next := (w.cur + 1) % len(w.files)
+	f := w.files[next]
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("truncating %q: %v", f.Name(), err)
+	}
+	// Files opened with O_APPEND ignore the offset, but others don't.
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking %q: %v", f.Name(), err)
+	}
+	w.cur = next
+	w.size = 0
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	var files []*os.File
+	for _, name := range []string{"log", "log.1", "log.2"} {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile(%q): %v", name, err)
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	w, err := NewRotatingWriter(files, 10)
+	if err != nil {
+		t.Fatalf("NewRotatingWriter: %v", err)
+	}
+	// Each write fills up a file, so every write after the first rotates.
+	for _, line := range []string{"line0\n", "line1\n", "line2\n", "line3\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q): %v", line, err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"log":   "line3\n", // Reused after wrapping around.
+		"log.1": "line1\n",
+		"log.2": "line2\n",
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile(%q): %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s got: %q, want: %q", name, got, want)
+		}
+	}
+}
+
+func TestRotatingWriterInvalid(t *testing.T) {
+	if _, err := NewRotatingWriter(nil, 10); err == nil {
+		t.Errorf("NewRotatingWriter with no files should have failed")
+	}
+	f, err := ioutil.TempFile(t.TempDir(), "log")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer f.Close()
+	if _, err := NewRotatingWriter([]*os.File{f}, 0); err == nil {
+		t.Errorf("NewRotatingWriter with zero size should have failed")
+	}
+}
@@ -299,20 +299,103 @@ func ProcessListToTable(pl []*Process) string {
 	tw := tabwriter.NewWriter(&buf, 10, 1, 3, ' ', 0)
 	fmt.Fprint(tw, "UID\tPID\tPPID\tC\tTTY\tSTIME\tTIME\tCMD")
 	for _, d := range pl {
-		fmt.Fprintf(tw, "\n%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s",
-			d.UID,
-			d.PID,
-			d.PPID,
-			d.C,
-			d.TTY,
-			d.STime,
-			d.Time,
-			d.Cmd)
+		writeProcessRow(tw, d, d.Cmd)
 	}
 	tw.Flush()
 	return buf.String()
 }
 
+// writeProcessRow writes a row of the ProcessListToTable format for d, with
+// cmd in the CMD column.
+func writeProcessRow(tw *tabwriter.Writer, d *Process, cmd string) {
+	fmt.Fprintf(tw, "\n%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s",
+		d.UID,
+		d.PID,
+		d.PPID,
+		d.C,
+		d.TTY,
+		d.STime,
+		d.Time,
+		cmd)
+}
+
+// ProcessTree is a process and its children, as built by ProcessListToTree.
+type ProcessTree struct {
+	*Process
+	Children []*ProcessTree `json:"children,omitempty"`
+}
+
+// ProcessListToTree arranges pl into a tree following the PPID of each
+// process. The root is PID 1, or the process with the lowest PID if PID 1 isn't
+// in pl, e.g. for a container other than the root container. Processes whose
+// parent isn't in pl, like orphans that haven't been reparented yet, are
+// attached under the root. Children are sorted by PID. It returns nil if pl is
+// empty.
+func ProcessListToTree(pl []*Process) *ProcessTree {
+	if len(pl) == 0 {
+		return nil
+	}
+	nodes := make(map[kernel.ThreadID]*ProcessTree, len(pl))
+	var root *ProcessTree
+	for _, p := range pl {
+		n := &ProcessTree{Process: p}
+		nodes[p.PID] = n
+		if root == nil || p.PID == 1 || (root.PID != 1 && p.PID < root.PID) {
+			root = n
+		}
+	}
+	for _, p := range pl {
+		n := nodes[p.PID]
+		if n == root {
+			continue
+		}
+		parent, ok := nodes[p.PPID]
+		if !ok || parent == n {
+			parent = root
+		}
+		parent.Children = append(parent.Children, n)
+	}
+	for _, n := range nodes {
+		sort.Slice(n.Children, func(i, j int) bool {
+			return n.Children[i].PID < n.Children[j].PID
+		})
+	}
+	return root
+}
+
+// ProcessTreeToTable prints the processes of pt in the ProcessListToTable
+// format, parents before their children, with commands indented by depth.
+func ProcessTreeToTable(pt *ProcessTree) string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 10, 1, 3, ' ', 0)
+	fmt.Fprint(tw, "UID\tPID\tPPID\tC\tTTY\tSTIME\tTIME\tCMD")
+	var walk func(n *ProcessTree, depth int)
+	walk = func(n *ProcessTree, depth int) {
+		cmd := n.Cmd
+		if depth > 0 {
+			cmd = strings.Repeat("    ", depth-1) + " \\_ " + cmd
+		}
+		writeProcessRow(tw, n.Process, cmd)
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	if pt != nil {
+		walk(pt, 0)
+	}
+	tw.Flush()
+	return buf.String()
+}
+
+// ProcessTreeToJSON will return the JSON representation of pt.
+func ProcessTreeToJSON(pt *ProcessTree) (string, error) {
+	b, err := json.MarshalIndent(pt, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("couldn't marshal process tree: %v", err)
+	}
+	return string(b), nil
+}
+
 // ProcessListToJSON will return the JSON representation of ps.
 func ProcessListToJSON(pl []*Process) (string, error) {
 	b, err := json.MarshalIndent(pl, "", "  ")
@@ -428,3 +511,34 @@ func ContainerUsage(kr *kernel.Kernel) map[string]uint64 {
 	}
 	return cusage
 }
+
+// MemoryUsage contains memory usage of a container.
+type MemoryUsage struct {
+	// RSS is the sum of the resident set sizes of the container's processes.
+	RSS uint64 `json:"rss"`
+
+	// MaxRSS is the sum of the maximum resident set sizes of the container's
+	// processes, including their reaped children.
+	MaxRSS uint64 `json:"maxRSS"`
+}
+
+// ContainerMemoryUsage retrieves per-container memory usage. Memory shared
+// between processes is counted once for each process that maps it.
+func ContainerMemoryUsage(kr *kernel.Kernel) map[string]MemoryUsage {
+	musage := make(map[string]MemoryUsage)
+	for _, tg := range kr.TaskSet().Root.ThreadGroups() {
+		leader := tg.Leader()
+		if leader == nil {
+			continue
+		}
+		u := musage[leader.ContainerID()]
+		leader.WithMuLocked(func(t *kernel.Task) {
+			if mm := t.MemoryManager(); mm != nil {
+				u.RSS += mm.ResidentSetSize()
+			}
+		})
+		u.MaxRSS += leader.MaxRSS(linux.RUSAGE_BOTH)
+		musage[leader.ContainerID()] = u
+	}
+	return musage
+}
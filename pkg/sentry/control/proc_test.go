@@ -15,6 +15,9 @@
 package control
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	"gvisor.dev/gvisor/pkg/log"
@@ -120,6 +123,94 @@ func TestProcessListJSON(t *testing.T) {
 	}
 }
 
+func TestProcessListToTree(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pl   []*Process
+		want string
+	}{
+		{
+			name: "empty",
+			want: "null",
+		},
+		{
+			name: "nested",
+			pl: []*Process{
+				{PID: 3, PPID: 2, Cmd: "c"},
+				{PID: 1, PPID: 0, Cmd: "init"},
+				{PID: 4, PPID: 1, Cmd: "d"},
+				{PID: 2, PPID: 1, Cmd: "b"},
+			},
+			want: "1(2(3),4)",
+		},
+		{
+			name: "orphans",
+			pl: []*Process{
+				{PID: 1, PPID: 0, Cmd: "init"},
+				{PID: 5, PPID: 3, Cmd: "orphan"},
+				{PID: 6, PPID: 5, Cmd: "child"},
+			},
+			want: "1(5(6))",
+		},
+		{
+			name: "no init",
+			pl: []*Process{
+				{PID: 9, PPID: 7, Cmd: "b"},
+				{PID: 7, PPID: 0, Cmd: "a"},
+				{PID: 8, PPID: 2, Cmd: "orphan"},
+			},
+			want: "7(8,9)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := treeString(ProcessListToTree(tc.pl)); got != tc.want {
+				t.Errorf("got ProcessListToTree() = %s, want = %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// treeString returns the PIDs of pt, with the children of each process in
+// parentheses.
+func treeString(pt *ProcessTree) string {
+	if pt == nil {
+		return "null"
+	}
+	s := fmt.Sprint(pt.PID)
+	if len(pt.Children) == 0 {
+		return s
+	}
+	var children []string
+	for _, c := range pt.Children {
+		children = append(children, treeString(c))
+	}
+	return s + "(" + strings.Join(children, ",") + ")"
+}
+
+func TestProcessTreeToJSON(t *testing.T) {
+	pt := ProcessListToTree([]*Process{
+		{PID: 1, Cmd: "init"},
+		{PID: 2, PPID: 1, Cmd: "sh"},
+	})
+	out, err := ProcessTreeToJSON(pt)
+	if err != nil {
+		t.Fatalf("ProcessTreeToJSON(): %v", err)
+	}
+	var got struct {
+		PID      int `json:"pid"`
+		Children []struct {
+			PID int    `json:"pid"`
+			Cmd string `json:"cmd"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%q): %v", out, err)
+	}
+	if got.PID != 1 || len(got.Children) != 1 || got.Children[0].PID != 2 || got.Children[0].Cmd != "sh" {
+		t.Errorf("got ProcessTreeToJSON() = %s, want init with a single sh child", out)
+	}
+}
+
 func TestPercentCPU(t *testing.T) {
 	testCases := []struct {
 		stats     usage.CPUStats
@@ -43,6 +43,10 @@ type SaveOpts struct {
 	// Metadata is the set of metadata to prepend to the state file.
 	Metadata map[string]string `json:"metadata"`
 
+	// Resume indicates that the sandbox keeps running once the state has
+	// been saved, instead of exiting.
+	Resume bool `json:"resume"`
+
 	// FilePayload contains the destination for the state.
 	urpc.FilePayload
 }
@@ -61,6 +65,14 @@ func (s *State) Save(o *SaveOpts, _ *struct{}) error {
 		Key:         o.Key,
 		Metadata:    o.Metadata,
 		Callback: func(err error) {
+			if o.Resume {
+				if err == nil {
+					log.Infof("Save succeeded: resuming...")
+				} else {
+					log.Warningf("Save failed: resuming...")
+				}
+				return
+			}
 			if err == nil {
 				log.Infof("Save succeeded: exiting...")
 				s.Kernel.SetSaveSuccess(false /* autosave */)
@@ -35,6 +35,10 @@ import (
 // Name is the filesystem name.
 const Name = "devpts"
 
+// DefaultMaxPTYs is the default maximum number of PTYs that can be allocated,
+// like the default of /proc/sys/kernel/pty/max on Linux.
+const DefaultMaxPTYs = 4096
+
 // FilesystemType implements vfs.FilesystemType.
 //
 // +stateify savable
@@ -46,6 +50,11 @@ type FilesystemType struct {
 	// are immutable.
 	fs   *vfs.Filesystem
 	root *vfs.Dentry
+
+	// MaxPTYs is the initial maximum number of PTYs that can be allocated.
+	// If zero, DefaultMaxPTYs is used. MaxPTYs must be set before the
+	// filesystem is first mounted.
+	MaxPTYs uint32
 }
 
 // Name implements vfs.FilesystemType.Name.
@@ -77,6 +86,38 @@ func (fstype *FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Vir
 	return fstype.fs, fstype.root, nil
 }
 
+// rootInode returns the root inode of the filesystem, or nil if it hasn't
+// been mounted yet.
+func (fstype *FilesystemType) rootInode() *rootInode {
+	if fstype.root == nil {
+		return nil
+	}
+	return fstype.root.Impl().(*kernfs.Dentry).Inode().(*rootInode)
+}
+
+// PTYs returns the number of allocated PTYs and the maximum number of PTYs
+// that can be allocated, like /proc/sys/kernel/pty/{nr,max} on Linux. Both
+// are zero if the filesystem hasn't been mounted yet.
+func (fstype *FilesystemType) PTYs() (nr, max uint32) {
+	root := fstype.rootInode()
+	if root == nil {
+		return 0, 0
+	}
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	return uint32(len(root.replicas)), root.maxPTYs
+}
+
+// Terminals returns the terminals that have open file descriptions, ordered
+// by index. It can be used to find PTYs that were never closed.
+func (fstype *FilesystemType) Terminals() []TerminalInfo {
+	root := fstype.rootInode()
+	if root == nil {
+		return nil
+	}
+	return root.terminalInfos()
+}
+
 // Release implements vfs.FilesystemType.Release.
 func (fstype *FilesystemType) Release(ctx context.Context) {
 	if fstype.fs != nil {
@@ -106,8 +147,14 @@ func (fstype *FilesystemType) newFilesystem(ctx context.Context, vfsObj *vfs.Vir
 	fs.Filesystem.VFSFilesystem().Init(vfsObj, fstype, fs)
 
 	// Construct the root directory. This is always inode id 1.
+	maxPTYs := fstype.MaxPTYs
+	if maxPTYs == 0 {
+		maxPTYs = DefaultMaxPTYs
+	}
 	root := &rootInode{
-		replicas: make(map[uint32]*replicaInode),
+		replicas:  make(map[uint32]*replicaInode),
+		terminals: make(map[uint32]*Terminal),
+		maxPTYs:   maxPTYs,
 	}
 	root.InodeAttrs.Init(ctx, creds, linux.UNNAMED_MAJOR, devMinor, 1, linux.ModeDirectory|0555)
 	root.OrderedChildren.Init(kernfs.OrderedChildrenOptions{})
@@ -167,10 +214,18 @@ type rootInode struct {
 	// replicas maps pty ids to replica inodes.
 	replicas map[uint32]*replicaInode
 
+	// terminals maps pty ids to the terminals that have open file
+	// descriptions. Unlike replicas, it keeps terminals whose master was
+	// closed while their replica is still open.
+	terminals map[uint32]*Terminal
+
 	// nextIdx is the next pty index to use. Must be accessed atomically.
 	//
 	// TODO(b/29356795): reuse indices when ptys are closed.
 	nextIdx uint32
+
+	// maxPTYs is the maximum number of replicas.
+	maxPTYs uint32
 }
 
 var _ kernfs.Inode = (*rootInode)(nil)
@@ -179,6 +234,10 @@ var _ kernfs.Inode = (*rootInode)(nil)
 func (i *rootInode) allocateTerminal(ctx context.Context, creds *auth.Credentials) (*Terminal, error) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
+	if uint32(len(i.replicas)) >= i.maxPTYs {
+		// Same as Linux, see fs/devpts/inode.c:devpts_new_index().
+		return nil, linuxerr.ENOSPC
+	}
 	if i.nextIdx == math.MaxUint32 {
 		return nil, syserror.ENOMEM
 	}
@@ -200,6 +259,8 @@ func (i *rootInode) allocateTerminal(ctx context.Context, creds *auth.Credential
 	// fs/devpts/inode.c:devpts_pty_new().
 	replica.InodeAttrs.Init(ctx, creds, i.InodeAttrs.DevMajor(), i.InodeAttrs.DevMinor(), uint64(idx+3), linux.ModeCharacterDevice|0600)
 	i.replicas[idx] = replica
+	t.masterFDs = 1
+	i.terminals[idx] = t
 
 	return t, nil
 }
@@ -218,6 +279,69 @@ func (i *rootInode) masterClose(ctx context.Context, t *Terminal) {
 	// Drop the ref on replica inode taken during rootInode.allocateTerminal.
 	ri.DecRef(ctx)
 	delete(i.replicas, t.n)
+
+	t.masterFDs--
+	i.maybeForgetTerminalLocked(t)
+}
+
+// replicaOpened is called when a file description is opened for the replica
+// end of t.
+func (i *rootInode) replicaOpened(t *Terminal) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	t.replicaFDs++
+	i.terminals[t.n] = t
+}
+
+// replicaClosed is called when a file description of the replica end of t is
+// released.
+func (i *rootInode) replicaClosed(t *Terminal) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	t.replicaFDs--
+	i.maybeForgetTerminalLocked(t)
+}
+
+// maybeForgetTerminalLocked stops tracking t once both of its ends are closed.
+//
+// Preconditions: i.mu is locked.
+func (i *rootInode) maybeForgetTerminalLocked(t *Terminal) {
+	if t.masterFDs == 0 && t.replicaFDs == 0 {
+		delete(i.terminals, t.n)
+	}
+}
+
+// TerminalInfo describes a terminal that has open file descriptions.
+type TerminalInfo struct {
+	// Index is the terminal index, N in /dev/pts/N.
+	Index uint32
+
+	// MasterFDs is the number of open file descriptions of the master end.
+	// The master is closed once it's zero, even if the replica is open.
+	MasterFDs int
+
+	// ReplicaFDs is the number of open file descriptions of the replica
+	// end.
+	ReplicaFDs int
+}
+
+// terminalInfos returns the terminals that have open file descriptions,
+// ordered by index.
+func (i *rootInode) terminalInfos() []TerminalInfo {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	infos := make([]TerminalInfo, 0, len(i.terminals))
+	for _, t := range i.terminals {
+		infos = append(infos, TerminalInfo{
+			Index:      t.n,
+			MasterFDs:  t.masterFDs,
+			ReplicaFDs: t.replicaFDs,
+		})
+	}
+	sort.Slice(infos, func(a, b int) bool {
+		return infos[a].Index < infos[b].Index
+	})
+	return infos
 }
 
 // Open implements kernfs.Inode.Open.
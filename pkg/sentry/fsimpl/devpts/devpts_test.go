@@ -15,10 +15,13 @@
 package devpts
 
 import (
+	"reflect"
 	"testing"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/sentry/contexttest"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/usermem"
 	"gvisor.dev/gvisor/pkg/waiter"
 )
@@ -88,3 +91,75 @@ func TestEchoDeadlock(t *testing.T) {
 		t.Fatalf("written and read strings do not match: got %q, want %q", outStr, inStr)
 	}
 }
+
+func TestMaxPTYs(t *testing.T) {
+	ctx := contexttest.Context(t)
+	creds := auth.CredentialsFromContext(ctx)
+	const max = 3
+	root := &rootInode{
+		replicas:  make(map[uint32]*replicaInode),
+		terminals: make(map[uint32]*Terminal),
+		maxPTYs:   max,
+	}
+
+	var terms []*Terminal
+	for i := 0; i < max; i++ {
+		term, err := root.allocateTerminal(ctx, creds)
+		if err != nil {
+			t.Fatalf("allocateTerminal #%d failed: %v", i, err)
+		}
+		terms = append(terms, term)
+	}
+	if _, err := root.allocateTerminal(ctx, creds); err != linuxerr.ENOSPC {
+		t.Fatalf("got allocateTerminal() over the limit = %v, want %v", err, linuxerr.ENOSPC)
+	}
+
+	// Closing a PTY makes room for a new one.
+	root.masterClose(ctx, terms[0])
+	if _, err := root.allocateTerminal(ctx, creds); err != nil {
+		t.Errorf("allocateTerminal after closing a PTY failed: %v", err)
+	}
+}
+
+func TestTerminals(t *testing.T) {
+	ctx := contexttest.Context(t)
+	creds := auth.CredentialsFromContext(ctx)
+	root := &rootInode{
+		replicas:  make(map[uint32]*replicaInode),
+		terminals: make(map[uint32]*Terminal),
+		maxPTYs:   DefaultMaxPTYs,
+	}
+	check := func(want []TerminalInfo) {
+		t.Helper()
+		if got := root.terminalInfos(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got terminals %+v, want %+v", got, want)
+		}
+	}
+
+	t0, err := root.allocateTerminal(ctx, creds)
+	if err != nil {
+		t.Fatalf("allocateTerminal failed: %v", err)
+	}
+	t1, err := root.allocateTerminal(ctx, creds)
+	if err != nil {
+		t.Fatalf("allocateTerminal failed: %v", err)
+	}
+	root.replicaOpened(t1)
+	root.replicaOpened(t1)
+	check([]TerminalInfo{
+		{Index: 0, MasterFDs: 1},
+		{Index: 1, MasterFDs: 1, ReplicaFDs: 2},
+	})
+
+	// A terminal is still listed after its master is closed, as long as its
+	// replica is open.
+	root.masterClose(ctx, t0)
+	root.masterClose(ctx, t1)
+	check([]TerminalInfo{
+		{Index: 1, ReplicaFDs: 2},
+	})
+
+	root.replicaClosed(t1)
+	root.replicaClosed(t1)
+	check([]TerminalInfo{})
+}
@@ -15,9 +15,12 @@
 package devpts
 
 import (
+	"strconv"
+
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/fspath"
 	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
@@ -157,6 +160,9 @@ func (mfd *masterFileDescription) Ioctl(ctx context.Context, io usermem.IO, args
 	case linux.TIOCSPTLCK:
 		// TODO(b/29356795): Implement pty locking. For now just pretend we do.
 		return 0, nil
+	case linux.TIOCGPTPEER:
+		// Open the replica end and return its FD.
+		return mfd.openReplica(t, args[2].Int())
 	case linux.TIOCGWINSZ:
 		return 0, mfd.t.ld.windowSize(t, args)
 	case linux.TIOCSWINSZ:
@@ -181,6 +187,36 @@ func (mfd *masterFileDescription) Ioctl(ctx context.Context, io usermem.IO, args
 	}
 }
 
+// openReplica opens the replica end of the terminal in the devpts mount of
+// mfd, and installs it in t's FD table, for TIOCGPTPEER. It returns the new FD.
+func (mfd *masterFileDescription) openReplica(t *kernel.Task, flags int32) (uintptr, error) {
+	if flags&^(linux.O_ACCMODE|linux.O_NOCTTY|linux.O_NONBLOCK|linux.O_CLOEXEC) != 0 {
+		return 0, linuxerr.EINVAL
+	}
+
+	mnt := mfd.vfsfd.Mount()
+	root := vfs.MakeVirtualDentry(mnt, mnt.Root())
+	fd, err := t.Kernel().VFS().OpenAt(t, t.Credentials(), &vfs.PathOperation{
+		Root:  root,
+		Start: root,
+		Path:  fspath.Parse(strconv.FormatUint(uint64(mfd.t.n), 10)),
+	}, &vfs.OpenOptions{
+		Flags: uint32(flags) &^ linux.O_CLOEXEC,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer fd.DecRef(t)
+
+	newFD, err := t.NewFDFromVFS2(0, fd, kernel.FDFlags{
+		CloseOnExec: flags&linux.O_CLOEXEC != 0,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return uintptr(newFD), nil
+}
+
 // SetStat implements vfs.FileDescriptionImpl.SetStat.
 func (mfd *masterFileDescription) SetStat(ctx context.Context, opts vfs.SetStatOptions) error {
 	creds := auth.CredentialsFromContext(ctx)
@@ -224,8 +260,7 @@ func maybeEmitUnimplementedEvent(ctx context.Context, cmd uint32) {
 		linux.TIOCMBIS,
 		linux.TIOCGICOUNT,
 		linux.TCFLSH,
-		linux.TIOCSSERIAL,
-		linux.TIOCGPTPEER:
+		linux.TIOCSSERIAL:
 
 		unimpl.EmitUnimplementedEvent(ctx)
 	}
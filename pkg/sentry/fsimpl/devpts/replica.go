@@ -58,6 +58,7 @@ func (ri *replicaInode) Open(ctx context.Context, rp *vfs.ResolvingPath, d *kern
 	if err := fd.vfsfd.Init(fd, opts.Flags, rp.Mount(), d.VFSDentry(), &vfs.FileDescriptionOptions{}); err != nil {
 		return nil, err
 	}
+	ri.root.replicaOpened(ri.t)
 	if opts.Flags&linux.O_NOCTTY == 0 {
 		// Opening a replica sets the process' controlling TTY when
 		// possible. An error indicates it cannot be set, and is
@@ -109,7 +110,9 @@ type replicaFileDescription struct {
 var _ vfs.FileDescriptionImpl = (*replicaFileDescription)(nil)
 
 // Release implements fs.FileOperations.Release.
-func (rfd *replicaFileDescription) Release(ctx context.Context) {}
+func (rfd *replicaFileDescription) Release(ctx context.Context) {
+	rfd.inode.root.replicaClosed(rfd.inode.t)
+}
 
 // EventRegister implements waiter.Waitable.EventRegister.
 func (rfd *replicaFileDescription) EventRegister(e *waiter.Entry, mask waiter.EventMask) {
@@ -39,6 +39,12 @@ type Terminal struct {
 	// replicaKTTY contains the controlling process of the replica end of this
 	// terminal. This field is immutable.
 	replicaKTTY *kernel.TTY
+
+	// masterFDs and replicaFDs are the number of open file descriptions of
+	// the master and replica ends of the terminal. They are protected by
+	// rootInode.mu.
+	masterFDs  int
+	replicaFDs int
 }
 
 func newTerminal(n uint32) *Terminal {
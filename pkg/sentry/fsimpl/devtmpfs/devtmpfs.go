@@ -177,11 +177,18 @@ func (a *Accessor) CreateDeviceFile(ctx context.Context, pathname string, kind v
 	// thread in struct inode::i_private. Accessor doesn't yet support deletion
 	// of files at all, and probably won't as long as we don't need to support
 	// kernel modules, so this is moot for now.
-	return a.vfsObj.MknodAt(actx, a.creds, a.pathOperationAt(pathname), &vfs.MknodOptions{
+	if err := a.vfsObj.MknodAt(actx, a.creds, a.pathOperationAt(pathname), &vfs.MknodOptions{
 		Mode:     mode,
 		DevMajor: major,
 		DevMinor: minor,
+	}); err != nil {
+		return err
+	}
+	a.vfsObj.RecordDeviceFile(kind, major, minor, vfs.DeviceFile{
+		Pathname: pathname,
+		Perms:    perms,
 	})
+	return nil
 }
 
 // UserspaceInit creates symbolic links and mount points in the devtmpfs
@@ -0,0 +1,85 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pidfd provides process file descriptors, as returned by
+// pidfd_open(2).
+package pidfd
+
+import (
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// PIDFileDescription implements vfs.FileDescriptionImpl for pidfds. A pidfd
+// refers to a thread group and becomes readable once all of its tasks have
+// exited.
+//
+// +stateify savable
+type PIDFileDescription struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+
+	// tg is the thread group referred to by the pidfd. Immutable.
+	tg *kernel.ThreadGroup
+}
+
+var _ vfs.FileDescriptionImpl = (*PIDFileDescription)(nil)
+
+// New creates a new pidfd referring to tg.
+func New(ctx context.Context, vfsObj *vfs.VirtualFilesystem, tg *kernel.ThreadGroup, flags uint32) (*vfs.FileDescription, error) {
+	vd := vfsObj.NewAnonVirtualDentry("[pidfd]")
+	defer vd.DecRef(ctx)
+	pfd := &PIDFileDescription{
+		tg: tg,
+	}
+	if err := pfd.vfsfd.Init(pfd, flags, vd.Mount(), vd.Dentry(), &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+		DenyPRead:         true,
+		DenyPWrite:        true,
+	}); err != nil {
+		return nil, err
+	}
+	return &pfd.vfsfd, nil
+}
+
+// ThreadGroup returns the thread group referred to by the pidfd.
+func (pfd *PIDFileDescription) ThreadGroup() *kernel.ThreadGroup {
+	return pfd.tg
+}
+
+// Readiness implements waiter.Waitable.Readiness.
+func (pfd *PIDFileDescription) Readiness(mask waiter.EventMask) waiter.EventMask {
+	if mask&waiter.ReadableEvents != 0 && pfd.tg.Exited() {
+		return waiter.ReadableEvents
+	}
+	return 0
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (pfd *PIDFileDescription) EventRegister(entry *waiter.Entry, _ waiter.EventMask) {
+	// Exit is the only event, ignore the passed events.
+	pfd.tg.ExitRegister(entry)
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (pfd *PIDFileDescription) EventUnregister(entry *waiter.Entry) {
+	pfd.tg.ExitUnregister(entry)
+}
+
+// Release implements vfs.FileDescriptionImpl.Release.
+func (pfd *PIDFileDescription) Release(context.Context) {}
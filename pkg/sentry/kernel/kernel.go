@@ -38,6 +38,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"golang.org/x/time/rate"
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/cleanup"
 	"gvisor.dev/gvisor/pkg/context"
@@ -247,6 +248,17 @@ type Kernel struct {
 	// syscall.
 	unimplementedSyscallEmitter eventchannel.Emitter `state:"nosave"`
 
+	// forkRateLimit is the maximum number of new processes per second. Zero
+	// means no limit.
+	forkRateLimit int
+
+	// forkLimiterOnce is used in the initialization of forkLimiter.
+	forkLimiterOnce sync.Once `state:"nosave"`
+
+	// forkLimiter enforces forkRateLimit. This is initialized lazily on the
+	// first process creation.
+	forkLimiter *rate.Limiter `state:"nosave"`
+
 	// SpecialOpts contains special kernel options.
 	SpecialOpts
 
@@ -340,6 +352,11 @@ type InitKernelArgs struct {
 
 	// PIDNamespace is the root PID namespace.
 	PIDNamespace *PIDNamespace
+
+	// ForkRateLimit is the maximum number of new processes that can be
+	// created per second, across all tasks in the Kernel. Zero means no
+	// limit.
+	ForkRateLimit int
 }
 
 // Init initialize the Kernel with no tasks.
@@ -389,6 +406,7 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	}
 	k.extraAuxv = args.ExtraAuxv
 	k.vdso = args.Vdso
+	k.forkRateLimit = args.ForkRateLimit
 	k.futexes = futex.NewManager()
 	k.netlinkPorts = port.New()
 	k.ptraceExceptions = make(map[*Task]*Task)
@@ -1374,6 +1392,25 @@ func (k *Kernel) SendContainerSignal(cid string, info *linux.SignalInfo) error {
 	return lastErr
 }
 
+// RenameContainer changes the container ID of all tasks that belong to
+// container oldCID to newCID. It is used when restoring a checkpoint under a
+// different container ID than the one it was taken from, and when a running
+// container is renamed.
+func (k *Kernel) RenameContainer(oldCID, newCID string) {
+	k.extMu.Lock()
+	defer k.extMu.Unlock()
+	k.tasks.mu.Lock()
+	defer k.tasks.mu.Unlock()
+
+	for t := range k.tasks.Root.tids {
+		t.mu.Lock()
+		if t.containerID == oldCID {
+			t.containerID = newCID
+		}
+		t.mu.Unlock()
+	}
+}
+
 // RebuildTraceContexts rebuilds the trace context for all tasks.
 //
 // Unfortunately, if these are built while tracing is not enabled, then we will
@@ -1763,6 +1800,28 @@ func (k *Kernel) EmitUnimplementedEvent(ctx context.Context) {
 	})
 }
 
+// reserveFork reserves the creation of a new process under the Kernel's fork
+// rate limit, returning false if the limit is exceeded. If the process ends up
+// not being created, the caller must call release to return the reservation,
+// so that failed clones don't count against the limit.
+func (k *Kernel) reserveFork() (release func(), ok bool) {
+	if k.forkRateLimit == 0 {
+		return func() {}, true
+	}
+	k.forkLimiterOnce.Do(func() {
+		k.forkLimiter = rate.NewLimiter(rate.Limit(k.forkRateLimit), k.forkRateLimit)
+	})
+	r := k.forkLimiter.Reserve()
+	if !r.OK() {
+		return nil, false
+	}
+	if r.Delay() > 0 {
+		r.Cancel()
+		return nil, false
+	}
+	return r.Cancel, true
+}
+
 // VFS returns the virtual filesystem for the kernel.
 func (k *Kernel) VFS() *vfs.VirtualFilesystem {
 	return &k.vfs
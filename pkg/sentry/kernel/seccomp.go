@@ -196,6 +196,65 @@ func (t *Task) AppendSyscallFilter(p bpf.Program, syncAll bool) error {
 	return nil
 }
 
+// AppendContainerSyscallFilter adds BPF program p as a system call filter to
+// every task in container cid. Since the least permissive result of all
+// filters is used, this can only further restrict the container. If replace is
+// true, p instead replaces all existing filters of the tasks, including those
+// installed by the application itself.
+//
+// Either all tasks are updated or, on error, none are.
+//
+// Preconditions: The kernel must be paused.
+func (k *Kernel) AppendContainerSyscallFilter(cid string, p bpf.Program, replace bool) error {
+	k.extMu.Lock()
+	defer k.extMu.Unlock()
+	k.tasks.mu.RLock()
+	defer k.tasks.mu.RUnlock()
+
+	var tgs []*ThreadGroup
+	for tg := range k.tasks.Root.tgids {
+		if tg.leader.ContainerID() == cid {
+			tgs = append(tgs, tg)
+		}
+	}
+
+	// Check the instruction limit for all tasks before updating any. Since
+	// the kernel is paused, no task can install filters of its own meanwhile.
+	filtersOf := func(t *Task) ([]bpf.Program, error) {
+		totalLength := p.Length()
+		var filters []bpf.Program
+		if sf := t.syscallFilters.Load(); sf != nil && !replace {
+			for _, f := range sf.([]bpf.Program) {
+				totalLength += f.Length() + 4
+			}
+			filters = append(filters, sf.([]bpf.Program)...)
+		}
+		if totalLength > maxSyscallFilterInstructions {
+			return nil, syserror.ENOMEM
+		}
+		return append(filters, p), nil
+	}
+	for _, tg := range tgs {
+		tg.signalHandlers.mu.Lock()
+		for t := tg.tasks.Front(); t != nil; t = t.Next() {
+			if _, err := filtersOf(t); err != nil {
+				tg.signalHandlers.mu.Unlock()
+				return err
+			}
+		}
+		tg.signalHandlers.mu.Unlock()
+	}
+	for _, tg := range tgs {
+		tg.signalHandlers.mu.Lock()
+		for t := tg.tasks.Front(); t != nil; t = t.Next() {
+			filters, _ := filtersOf(t)
+			t.syscallFilters.Store(filters)
+		}
+		tg.signalHandlers.mu.Unlock()
+	}
+	return nil
+}
+
 // SeccompMode returns a SECCOMP_MODE_* constant indicating the task's current
 // seccomp syscall filtering mode, appropriate for both prctl(PR_GET_SECCOMP)
 // and /proc/[pid]/status.
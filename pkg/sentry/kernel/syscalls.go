@@ -101,6 +101,10 @@ const (
 
 	// ExternalAfterEnable enables the external hook after syscall execution.
 	ExternalAfterEnable
+
+	// SyscallDenyEnable makes the syscall fail with ENOSYS without executing
+	// it.
+	SyscallDenyEnable
 )
 
 // StraceEnableBits combines both strace log and event flags.
@@ -247,16 +247,19 @@ type Task struct {
 	// k is the Kernel that this task belongs to. The k pointer is immutable.
 	k *Kernel
 
+	// mu protects some of the following fields.
+	mu sync.Mutex `state:"nosave"`
+
 	// containerID has no equivalent in Linux; it's used by runsc to track all
 	// tasks that belong to a given containers since cgroups aren't implemented.
-	// It's inherited by the children, is immutable, and may be empty.
+	// It's inherited by the children, only changes through
+	// Kernel.RenameContainer, and may be empty.
 	//
 	// NOTE: cgroups can be used to track this when implemented.
+	//
+	// containerID is protected by mu.
 	containerID string
 
-	// mu protects some of the following fields.
-	mu sync.Mutex `state:"nosave"`
-
 	// image holds task data provided by the ELF loader.
 	//
 	// image is protected by mu, and is owned by the task goroutine.
@@ -834,6 +837,8 @@ func (t *Task) AbstractSockets() *AbstractSocketNamespace {
 
 // ContainerID returns t's container ID.
 func (t *Task) ContainerID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	return t.containerID
 }
 
@@ -245,6 +245,18 @@ func (t *Task) Clone(opts *CloneOptions) (ThreadID, *SyscallControl, error) {
 		}
 	}
 
+	// Creating a new process is subject to the kernel's fork rate limit. Use
+	// the same error as exceeding RLIMIT_NPROC. This is only checked once the
+	// clone is known to be valid, and the reservation is returned if the task
+	// isn't created, so that failed clones don't count against the limit.
+	releaseFork := func() {}
+	if opts.NewThreadGroup {
+		var ok bool
+		if releaseFork, ok = t.k.reserveFork(); !ok {
+			return 0, nil, linuxerr.EAGAIN
+		}
+	}
+
 	var fsContext *FSContext
 	if opts.NewFSContext {
 		fsContext = t.fsContext.Fork()
@@ -314,6 +326,7 @@ func (t *Task) Clone(opts *CloneOptions) (ThreadID, *SyscallControl, error) {
 	// the cleanup for us.
 	cu.Release()
 	if err != nil {
+		releaseFork()
 		return 0, nil, err
 	}
 
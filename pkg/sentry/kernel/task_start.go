@@ -163,6 +163,13 @@ func (ts *TaskSet) newTask(cfg *TaskConfig) (*Task, error) {
 	// the system atomically.
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
+	// Inherit the container ID again now that ts.mu is locked, in case the
+	// cloning task was renamed by Kernel.RenameContainer in the meantime.
+	if cloner := cfg.InheritParent; cloner != nil {
+		t.containerID = cloner.ContainerID()
+	} else if cloner := cfg.Parent; cloner != nil {
+		t.containerID = cloner.ContainerID()
+	}
 	tg.signalHandlers.mu.Lock()
 	defer tg.signalHandlers.mu.Unlock()
 	if tg.exiting || tg.execing != nil {
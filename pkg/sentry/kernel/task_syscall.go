@@ -89,7 +89,11 @@ func (t *Task) executeSyscall(sysno uintptr, args arch.SyscallArguments) (rval u
 		straceContext = s.Stracer.SyscallEnter(t, sysno, args, fe)
 	}
 
-	if bits.IsOn32(fe, ExternalBeforeEnable) && (s.ExternalFilterBefore == nil || s.ExternalFilterBefore(t, sysno, args)) {
+	if bits.IsOn32(fe, SyscallDenyEnable) {
+		// The syscall was disabled by the administrator, make it look
+		// unimplemented.
+		err = linuxerr.ENOSYS
+	} else if bits.IsOn32(fe, ExternalBeforeEnable) && (s.ExternalFilterBefore == nil || s.ExternalFilterBefore(t, sysno, args)) {
 		t.invokeExternal()
 		// Ensure we check for stops, then invoke the syscall again.
 		ctrl = ctrlStopAndReinvokeSyscall
@@ -330,6 +330,10 @@ type threadGroupNode struct {
 	// to the wait sourced from Exec().
 	eventQueue waiter.Queue `state:"nosave"`
 
+	// exitQueue is notified with waiter.ReadableEvents when the last task in
+	// the thread group exits. It's used to implement pidfds.
+	exitQueue waiter.Queue `state:"nosave"`
+
 	// leader is the thread group's leader, which is the oldest task in the
 	// thread group; usually the last task in the thread group to call
 	// execve(), or if no such task exists then the first task in the thread
@@ -410,6 +414,24 @@ func (tg *ThreadGroup) Count() int {
 	return count
 }
 
+// Exited returns true if all tasks in tg have exited, even if they have not yet
+// been reaped.
+func (tg *ThreadGroup) Exited() bool {
+	tg.pidns.owner.mu.RLock()
+	defer tg.pidns.owner.mu.RUnlock()
+	return tg.liveTasks == 0
+}
+
+// ExitRegister registers e to be notified when all tasks in tg have exited.
+func (tg *ThreadGroup) ExitRegister(e *waiter.Entry) {
+	tg.exitQueue.EventRegister(e, waiter.ReadableEvents)
+}
+
+// ExitUnregister unregisters e, previously registered by ExitRegister.
+func (tg *ThreadGroup) ExitUnregister(e *waiter.Entry) {
+	tg.exitQueue.EventUnregister(e)
+}
+
 // MemberIDs returns a snapshot of the ThreadIDs (in PID namespace pidns) for
 // all tasks in tg.
 func (tg *ThreadGroup) MemberIDs(pidns *PIDNamespace) []ThreadID {
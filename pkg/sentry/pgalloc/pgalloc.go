@@ -155,6 +155,19 @@ type MemoryFile struct {
 	// evictable is protected by mu.
 	evictable map[EvictableMemoryUser]*evictableMemoryUserInfo
 
+	// evictableLRU contains the users in evictable that have no eviction
+	// goroutine running on their behalf, from least to most recently marked
+	// evictable.
+	//
+	// evictableLRU is protected by mu.
+	evictableLRU evictableUserList
+
+	// evictableLRUBytes is the total length of the evictable ranges of the
+	// users in evictableLRU.
+	//
+	// evictableLRUBytes is protected by mu.
+	evictableLRUBytes uint64
+
 	// evictionWG counts the number of goroutines currently performing evictions.
 	evictionWG sync.WaitGroup
 
@@ -179,6 +192,14 @@ type MemoryFileOpts struct {
 	// obtained from the host are zero-filled, such that MemoryFile must manually
 	// zero newly-allocated pages.
 	ManualZeroing bool
+
+	// If EvictableLimit is non-zero, the MemoryFile starts evicting evictable
+	// allocations, regardless of DelayedEviction, whenever their total size
+	// exceeds EvictableLimit bytes. Users are evicted in their entirety, from
+	// least to most recently marked evictable, until the total size is back
+	// within the limit. With DelayedEvictionEnabled, evictable allocations
+	// are otherwise retained; see DelayedEvictionEnabled.
+	EvictableLimit uint64
 }
 
 // DelayedEvictionType is the type of MemoryFileOpts.DelayedEviction.
@@ -202,6 +223,9 @@ const (
 	// - If UseHostMemcgPressure is true, evictions are delayed until memory
 	// pressure is indicated.
 	//
+	// - Otherwise, if EvictableLimit is non-zero, evictions are delayed until
+	// the limit is exceeded.
+	//
 	// - Otherwise, evictions are only delayed until the reclaimer goroutine
 	// is out of work (pages to reclaim).
 	DelayedEvictionEnabled
@@ -276,6 +300,17 @@ type evictableMemoryUserInfo struct {
 	// If evicting is true, there is a goroutine currently evicting all
 	// evictable ranges for this user.
 	evicting bool
+
+	// user is the EvictableMemoryUser that info belongs to. user is
+	// immutable.
+	user EvictableMemoryUser
+
+	// bytes is the total length of ranges.
+	bytes uint64
+
+	// evictableUserEntry links info into MemoryFile.evictableLRU while
+	// evicting is false.
+	evictableUserEntry
 }
 
 const (
@@ -755,8 +790,14 @@ func (f *MemoryFile) MarkEvictable(user EvictableMemoryUser, er EvictableRange)
 	defer f.mu.Unlock()
 	info, ok := f.evictable[user]
 	if !ok {
-		info = &evictableMemoryUserInfo{}
+		info = &evictableMemoryUserInfo{user: user}
 		f.evictable[user] = info
+	} else if !info.evicting {
+		// Move info to the most recently used end of evictableLRU.
+		f.evictableLRU.Remove(info)
+	}
+	if !info.evicting {
+		f.evictableLRU.PushBack(info)
 	}
 	gap := info.ranges.LowerBoundGap(er.Start)
 	for gap.Ok() && gap.Start() < er.End {
@@ -766,6 +807,10 @@ func (f *MemoryFile) MarkEvictable(user EvictableMemoryUser, er EvictableRange)
 			continue
 		}
 		gap = info.ranges.Insert(gap, gapER, evictableRangeSetValue{}).NextGap()
+		f.addEvictableBytesLocked(info, gapER.Length())
+	}
+	if f.opts.EvictableLimit != 0 && f.evictableLRUBytes > f.opts.EvictableLimit {
+		f.startLimitEvictionsLocked()
 	}
 	if !info.evicting {
 		switch f.opts.DelayedEviction {
@@ -773,7 +818,7 @@ func (f *MemoryFile) MarkEvictable(user EvictableMemoryUser, er EvictableRange)
 			// Kick off eviction immediately.
 			f.startEvictionGoroutineLocked(user, info)
 		case DelayedEvictionEnabled:
-			if !f.opts.UseHostMemcgPressure {
+			if !f.opts.UseHostMemcgPressure && f.opts.EvictableLimit == 0 {
 				// Ensure that the reclaimer goroutine is running, so that it
 				// can start eviction when necessary.
 				f.reclaimCond.Signal()
@@ -800,11 +845,13 @@ func (f *MemoryFile) MarkUnevictable(user EvictableMemoryUser, er EvictableRange
 	seg := info.ranges.LowerBoundSegment(er.Start)
 	for seg.Ok() && seg.Start() < er.End {
 		seg = info.ranges.Isolate(seg, er)
+		f.subEvictableBytesLocked(info, seg.Range().Length())
 		seg = info.ranges.Remove(seg).NextSegment()
 	}
 	// We can only remove info if there's no eviction goroutine running on its
 	// behalf.
 	if !info.evicting && info.ranges.IsEmpty() {
+		f.evictableLRU.Remove(info)
 		delete(f.evictable, user)
 	}
 }
@@ -818,20 +865,51 @@ func (f *MemoryFile) MarkAllUnevictable(user EvictableMemoryUser) {
 	if !ok {
 		return
 	}
+	f.subEvictableBytesLocked(info, info.bytes)
 	info.ranges.RemoveAll()
 	// We can only remove info if there's no eviction goroutine running on its
 	// behalf.
 	if !info.evicting {
+		f.evictableLRU.Remove(info)
 		delete(f.evictable, user)
 	}
 }
 
+// addEvictableBytesLocked accounts for n bytes newly marked evictable for
+// info.
+//
+// Preconditions: f.mu must be locked.
+func (f *MemoryFile) addEvictableBytesLocked(info *evictableMemoryUserInfo, n uint64) {
+	info.bytes += n
+	if !info.evicting {
+		f.evictableLRUBytes += n
+	}
+}
+
+// subEvictableBytesLocked accounts for n bytes of info that are no longer
+// evictable.
+//
+// Preconditions: f.mu must be locked.
+func (f *MemoryFile) subEvictableBytesLocked(info *evictableMemoryUserInfo, n uint64) {
+	info.bytes -= n
+	if !info.evicting {
+		f.evictableLRUBytes -= n
+	}
+}
+
 // ShouldCacheEvictable returns true if f is meaningfully delaying evictions of
 // evictable memory, such that it may be advantageous to cache data in
 // evictable memory. The value returned by ShouldCacheEvictable may change
 // between calls.
 func (f *MemoryFile) ShouldCacheEvictable() bool {
-	return f.opts.DelayedEviction == DelayedEvictionManual || f.opts.UseHostMemcgPressure
+	switch f.opts.DelayedEviction {
+	case DelayedEvictionManual:
+		return true
+	case DelayedEvictionEnabled:
+		return f.opts.UseHostMemcgPressure || f.opts.EvictableLimit != 0
+	default:
+		return false
+	}
 }
 
 // UpdateUsage ensures that the memory usage statistics in
@@ -1138,7 +1216,7 @@ func (f *MemoryFile) findReclaimable() (memmap.FileRange, bool) {
 			if f.reclaimable {
 				break
 			}
-			if f.opts.DelayedEviction == DelayedEvictionEnabled && !f.opts.UseHostMemcgPressure {
+			if f.opts.DelayedEviction == DelayedEvictionEnabled && !f.opts.UseHostMemcgPressure && f.opts.EvictableLimit == 0 {
 				// No work to do. Evict any pending evictable allocations to
 				// get more reclaimable pages before going to sleep.
 				f.startEvictionsLocked()
@@ -1204,12 +1282,26 @@ func (f *MemoryFile) startEvictionsLocked() bool {
 	return startedAny
 }
 
+// startLimitEvictionsLocked starts evicting the least recently used users
+// until the evictable allocations that remain fit within opts.EvictableLimit.
+//
+// Preconditions: f.mu must be locked.
+func (f *MemoryFile) startLimitEvictionsLocked() {
+	for f.evictableLRUBytes > f.opts.EvictableLimit {
+		// evictableLRUBytes is non-zero, so evictableLRU isn't empty.
+		info := f.evictableLRU.Front()
+		f.startEvictionGoroutineLocked(info.user, info)
+	}
+}
+
 // Preconditions:
 // * info == f.evictable[user].
 // * !info.evicting.
 // * f.mu must be locked.
 func (f *MemoryFile) startEvictionGoroutineLocked(user EvictableMemoryUser, info *evictableMemoryUserInfo) {
 	info.evicting = true
+	f.evictableLRU.Remove(info)
+	f.evictableLRUBytes -= info.bytes
 	f.evictionWG.Add(1)
 	go func() { // S/R-SAFE: f.evictionWG
 		defer f.evictionWG.Done()
@@ -1234,6 +1326,7 @@ func (f *MemoryFile) startEvictionGoroutineLocked(user EvictableMemoryUser, info
 			seg := info.ranges.LastSegment()
 			er := seg.Range()
 			info.ranges.Remove(seg)
+			f.subEvictableBytesLocked(info, er.Length())
 			// user.Evict() must be called without holding f.mu to avoid
 			// circular lock ordering.
 			f.mu.Unlock()
@@ -15,9 +15,13 @@
 package pgalloc
 
 import (
+	"os"
 	"testing"
 
+	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/memutil"
+	"gvisor.dev/gvisor/pkg/sync"
 )
 
 const (
@@ -244,3 +248,71 @@ func TestFindUnallocatedRange(t *testing.T) {
 		})
 	}
 }
+
+type testEvictableUser struct {
+	mu      sync.Mutex
+	evicted uint64
+}
+
+// Evict implements EvictableMemoryUser.Evict.
+func (u *testEvictableUser) Evict(ctx context.Context, er EvictableRange) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.evicted += er.Length()
+}
+
+func (u *testEvictableUser) evictedBytes() uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.evicted
+}
+
+func TestEvictableLimit(t *testing.T) {
+	memfd, err := memutil.CreateMemFD("pgalloc_test", 0)
+	if err != nil {
+		t.Fatalf("CreateMemFD failed: %v", err)
+	}
+	f, err := NewMemoryFile(os.NewFile(uintptr(memfd), "pgalloc_test"), MemoryFileOpts{
+		DelayedEviction: DelayedEvictionManual,
+		EvictableLimit:  2 * page,
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryFile failed: %v", err)
+	}
+	defer f.Destroy()
+
+	var a, b, c testEvictableUser
+	f.MarkEvictable(&a, EvictableRange{0, page})
+	f.MarkEvictable(&b, EvictableRange{0, page})
+	f.WaitForEvictions()
+	if got := a.evictedBytes() + b.evictedBytes(); got != 0 {
+		t.Errorf("evicted %d bytes within the limit, want 0", got)
+	}
+
+	// Exceeding the limit evicts the least recently used user, a.
+	f.MarkEvictable(&c, EvictableRange{0, page})
+	f.WaitForEvictions()
+	for _, tc := range []struct {
+		name string
+		user *testEvictableUser
+		want uint64
+	}{
+		{"a", &a, page},
+		{"b", &b, 0},
+		{"c", &c, 0},
+	} {
+		if got := tc.user.evictedBytes(); got != tc.want {
+			t.Errorf("user %s evicted %d bytes, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	// Using b again makes c the least recently used user.
+	f.MarkEvictable(&b, EvictableRange{page, 2 * page})
+	f.WaitForEvictions()
+	if got := c.evictedBytes(); got != page {
+		t.Errorf("user c evicted %d bytes, want %d", got, page)
+	}
+	if got := b.evictedBytes(); got != 0 {
+		t.Errorf("user b evicted %d bytes, want 0", got)
+	}
+}
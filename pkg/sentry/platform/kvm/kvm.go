@@ -18,6 +18,8 @@ package kvm
 import (
 	"fmt"
 	"os"
+	"strings"
+	"sync/atomic"
 
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/hostarch"
@@ -75,13 +77,70 @@ var (
 	globalErr  error
 )
 
-// OpenDevice opens the KVM device at /dev/kvm and returns the File.
-func OpenDevice() (*os.File, error) {
-	f, err := os.OpenFile("/dev/kvm", unix.O_RDWR, 0)
-	if err != nil {
-		return nil, fmt.Errorf("error opening /dev/kvm: %v", err)
+// defaultDevicePath is the path of the KVM device used when no candidate paths
+// are given to OpenDevice.
+const defaultDevicePath = "/dev/kvm"
+
+// DeviceOpenError is returned by OpenDevice when none of the candidate paths
+// could be opened.
+type DeviceOpenError struct {
+	// Paths are the paths that were tried, in order.
+	Paths []string
+
+	// Errs are the errors returned for each of Paths.
+	Errs []error
+}
+
+// Error implements error.Error.
+func (e *DeviceOpenError) Error() string {
+	var b strings.Builder
+	b.WriteString("error opening KVM device")
+	for i, path := range e.Paths {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%s: %s", path, deviceOpenReason(e.Errs[i]))
+	}
+	return b.String()
+}
+
+// deviceOpenReason describes why a device path couldn't be opened.
+func deviceOpenReason(err error) string {
+	if pathErr, ok := err.(*os.PathError); ok {
+		switch pathErr.Err {
+		case unix.ENOENT:
+			return "device does not exist (ENOENT)"
+		case unix.EACCES:
+			return "permission denied (EACCES), check the device's owner and group"
+		case unix.EPERM:
+			return "operation not permitted (EPERM), check the device cgroup"
+		}
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// OpenDevice opens the KVM device and returns the File. The candidate paths
+// are tried in order, and the first one that can be opened is used. If no
+// paths are given, /dev/kvm is used.
+//
+// If none of the paths can be opened, a *DeviceOpenError is returned.
+func OpenDevice(paths ...string) (*os.File, error) {
+	if len(paths) == 0 {
+		paths = []string{defaultDevicePath}
+	}
+	openErr := &DeviceOpenError{}
+	for _, path := range paths {
+		f, err := os.OpenFile(path, unix.O_RDWR, 0)
+		if err == nil {
+			return f, nil
+		}
+		openErr.Paths = append(openErr.Paths, path)
+		openErr.Errs = append(openErr.Errs, err)
 	}
-	return f, nil
+	return nil, openErr
 }
 
 // New returns a new KVM-based implementation of the platform interface.
@@ -175,6 +234,44 @@ func (k *KVM) NewContext() platform.Context {
 	}
 }
 
+// KVMStats are counters aggregated across all vCPUs of the machine.
+type KVMStats struct {
+	// VCPUs is the number of vCPUs created so far.
+	VCPUs int
+
+	// UserExits is the number of returns from application mode.
+	UserExits uint64
+
+	// GuestExits is the number of guest to host world switches.
+	GuestExits uint64
+
+	// Bounces is the number of signals sent to force a vCPU out of guest
+	// mode.
+	Bounces uint64
+
+	// Migrations is the number of times a vCPU was taken over by a
+	// different thread. Each of these can force a world switch when the
+	// previous thread is still in guest mode on that vCPU.
+	Migrations uint64
+}
+
+// Stats returns the current counters of the machine.
+//
+// The counters are read atomically without locking the machine, so they are
+// not a consistent snapshot across vCPUs.
+func (k *KVM) Stats() KVMStats {
+	m := k.machine
+	n := int(atomic.LoadUint32(&m.numVCPUs))
+	stats := KVMStats{VCPUs: n}
+	for _, c := range m.vCPUsByID[:n] {
+		stats.UserExits += atomic.LoadUint64(&c.userExits)
+		stats.GuestExits += atomic.LoadUint64(&c.guestExits)
+		stats.Bounces += atomic.LoadUint64(&c.bounces)
+		stats.Migrations += atomic.LoadUint64(&c.migrations)
+	}
+	return stats
+}
+
 type constructor struct{}
 
 func (*constructor) New(f *os.File) (platform.Platform, error) {
@@ -17,6 +17,7 @@ package kvm
 import (
 	"math/rand"
 	"reflect"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -446,6 +447,23 @@ func TestWrongVCPU(t *testing.T) {
 	})
 }
 
+func TestStats(t *testing.T) {
+	var k *KVM
+	kvmTest(t, func(kvm *KVM) { k = kvm }, func(c *vCPU) bool {
+		before := k.Stats()
+		bluepill(c)
+		redpill()
+		after := k.Stats()
+		if after.VCPUs == 0 {
+			t.Errorf("Stats() reports no vCPUs: %+v", after)
+		}
+		if after.GuestExits <= before.GuestExits {
+			t.Errorf("Stats() guest exits didn't increase: before=%+v, after=%+v", before, after)
+		}
+		return false
+	})
+}
+
 func TestRdtsc(t *testing.T) {
 	var i int // Iteration count.
 	kvmTest(t, nil, func(c *vCPU) bool {
@@ -527,3 +545,22 @@ func BenchmarkWorldSwitchToUserRoundtrip(b *testing.B) {
 		b.Logf("ErrContextInterrupt occurred %d times (in %d iterations).", a, a+i)
 	}
 }
+
+func TestOpenDeviceBogusPath(t *testing.T) {
+	const bogus = "/dev/this-is-not-kvm"
+	f, err := OpenDevice(bogus)
+	if err == nil {
+		f.Close()
+		t.Fatalf("OpenDevice(%q) succeeded, want error", bogus)
+	}
+	openErr, ok := err.(*DeviceOpenError)
+	if !ok {
+		t.Fatalf("OpenDevice(%q) = %T, want *DeviceOpenError", bogus, err)
+	}
+	if len(openErr.Paths) != 1 || openErr.Paths[0] != bogus {
+		t.Errorf("got Paths = %v, want [%s]", openErr.Paths, bogus)
+	}
+	if msg := err.Error(); !strings.Contains(msg, bogus) || !strings.Contains(msg, "ENOENT") {
+		t.Errorf("got error %q, want it to mention %q and ENOENT", msg, bogus)
+	}
+}
@@ -76,6 +76,11 @@ type machine struct {
 	// nextID is the next vCPU ID.
 	nextID uint32
 
+	// numVCPUs is the number of vCPUs published in vCPUsByID. It is
+	// stored atomically after a new vCPU is added, so that vCPUsByID can be
+	// scanned up to numVCPUs without holding mu.
+	numVCPUs uint32
+
 	// machineArchState is the architecture-specific state.
 	machineArchState
 }
@@ -122,6 +127,14 @@ type vCPU struct {
 	// faults is a count of world faults (informational only).
 	faults uint32
 
+	// bounces is the count of signals sent to force this vCPU out of
+	// guest mode.
+	bounces uint64
+
+	// migrations is the count of times this vCPU was taken over by a
+	// different thread in machine.Get.
+	migrations uint64
+
 	// state is the vCPU state.
 	//
 	// This is a bitmask of the three fields (vCPU*) described above.
@@ -171,6 +184,7 @@ func (m *machine) newVCPU() *vCPU {
 	}
 	c.CPU.Init(&m.kernel, c.id, c)
 	m.vCPUsByID[c.id] = c
+	atomic.StoreUint32(&m.numVCPUs, uint32(c.id+1))
 
 	// Ensure the signal mask is correct.
 	if err := c.setSignalMask(); err != nil {
@@ -419,6 +433,7 @@ func (m *machine) Get() *vCPU {
 			if atomic.CompareAndSwapUint32(&c.state, vCPUReady, vCPUUser) {
 				delete(m.vCPUsByTID, origTID)
 				m.vCPUsByTID[tid] = c
+				atomic.AddUint64(&c.migrations, 1)
 				m.mu.Unlock()
 				c.loadSegments(tid)
 				return c
@@ -455,6 +470,7 @@ func (m *machine) Get() *vCPU {
 			// Steal the vCPU.
 			delete(m.vCPUsByTID, origTID)
 			m.vCPUsByTID[tid] = c
+			atomic.AddUint64(&c.migrations, 1)
 			m.mu.Unlock()
 			c.loadSegments(tid)
 			return c
@@ -594,6 +610,7 @@ func (c *vCPU) bounce(forceGuestExit bool) {
 				// marked ourselves as a waiter, we need to
 				// ensure that a signal is actually delivered.
 				if err := unix.Tgkill(pid, int(atomic.LoadUint64(&c.tid)), bounceSignal); err == nil {
+					atomic.AddUint64(&c.bounces, 1)
 					break
 				} else if err.(unix.Errno) == unix.EAGAIN {
 					continue
@@ -24,6 +24,7 @@ import (
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/marshal"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/syserr"
 	"gvisor.dev/gvisor/pkg/tcpip"
@@ -153,6 +154,55 @@ func GetEntries6(t *kernel.Task, stack *stack.Stack, outPtr hostarch.Addr, outLe
 	return entries, nil
 }
 
+// GetReplace returns the rules of the table called name in the format of an
+// IPT_SO_SET_REPLACE (or IP6T_SO_SET_REPLACE) payload, as the iptables tool
+// would pass to setsockopt(2). Passing the result to SetEntries recreates the
+// table.
+func GetReplace(stk *stack.Stack, name string, ipv6 bool) ([]byte, error) {
+	var tablename linux.TableName
+	if len(name) >= len(tablename) {
+		return nil, fmt.Errorf("table name %q too long", name)
+	}
+	copy(tablename[:], name)
+
+	var entries []marshal.Marshallable
+	var info linux.IPTGetinfo
+	if ipv6 {
+		kentries, kinfo, err := convertNetstackToBinary6(stk, tablename)
+		if err != nil {
+			return nil, err
+		}
+		for i := range kentries.Entrytable {
+			entries = append(entries, &kentries.Entrytable[i])
+		}
+		info = kinfo
+	} else {
+		kentries, kinfo, err := convertNetstackToBinary4(stk, tablename)
+		if err != nil {
+			return nil, err
+		}
+		for i := range kentries.Entrytable {
+			entries = append(entries, &kentries.Entrytable[i])
+		}
+		info = kinfo
+	}
+
+	replace := linux.IPTReplace{
+		Name:        info.Name,
+		ValidHooks:  info.ValidHooks,
+		NumEntries:  info.NumEntries,
+		Size:        info.Size,
+		HookEntry:   info.HookEntry,
+		Underflow:   info.Underflow,
+		NumCounters: info.NumEntries,
+	}
+	buf := marshal.Marshal(&replace)
+	for _, entry := range entries {
+		buf = append(buf, marshal.Marshal(entry)...)
+	}
+	return buf, nil
+}
+
 // setHooksAndUnderflow checks whether the rule at ruleIdx is a hook entrypoint
 // or underflow, in which case it fills in info.HookEntry and info.Underflows.
 func setHooksAndUnderflow(info *linux.IPTGetinfo, table stack.Table, offset uint32, ruleIdx int) {
@@ -65,6 +65,12 @@ func mayKill(t *kernel.Task, target *kernel.Task, sig linux.Signal) bool {
 	return false
 }
 
+// MayKill returns true if task t may send signal sig to target. It's exported
+// for syscalls implemented in other packages.
+func MayKill(t *kernel.Task, target *kernel.Task, sig linux.Signal) bool {
+	return mayKill(t, target, sig)
+}
+
 // Kill implements linux syscall kill(2).
 func Kill(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
 	pid := kernel.ThreadID(args[0].Int())
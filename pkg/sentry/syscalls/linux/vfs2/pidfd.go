@@ -0,0 +1,121 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfs2
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/pidfd"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	slinux "gvisor.dev/gvisor/pkg/sentry/syscalls/linux"
+)
+
+// PidfdOpen implements linux syscall pidfd_open(2).
+func PidfdOpen(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	pid := kernel.ThreadID(args[0].Int())
+	flags := args[1].Uint()
+
+	// PIDFD_NONBLOCK has the same value as O_NONBLOCK.
+	if flags&^linux.O_NONBLOCK != 0 || pid <= 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	target := t.PIDNamespace().TaskWithID(pid)
+	if target == nil {
+		return 0, nil, linuxerr.ESRCH
+	}
+	// Only thread group leaders can be referred to by a pidfd.
+	tg := target.ThreadGroup()
+	if tg.Leader() != target {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	pfd, err := pidfd.New(t, t.Kernel().VFS(), tg, linux.O_RDWR|flags)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer pfd.DecRef(t)
+
+	// "The close-on-exec flag is set on the file descriptor." - pidfd_open(2)
+	fd, err := t.NewFDFromVFS2(0, pfd, kernel.FDFlags{
+		CloseOnExec: true,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return uintptr(fd), nil, nil
+}
+
+// PidfdSendSignal implements linux syscall pidfd_send_signal(2).
+func PidfdSendSignal(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fd := args[0].Int()
+	sig := linux.Signal(args[1].Int())
+	infoAddr := args[2].Pointer()
+	flags := args[3].Uint()
+
+	if flags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if sig != 0 && !sig.IsValid() {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	file := t.GetFileVFS2(fd)
+	if file == nil {
+		return 0, nil, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+	pfd, ok := file.Impl().(*pidfd.PIDFileDescription)
+	if !ok {
+		return 0, nil, linuxerr.EBADF
+	}
+	tg := pfd.ThreadGroup()
+	// The target must be visible in the caller's PID namespace.
+	if t.PIDNamespace().IDOfThreadGroup(tg) == 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	target := tg.Leader()
+	if target == nil {
+		return 0, nil, linuxerr.ESRCH
+	}
+
+	var info linux.SignalInfo
+	if infoAddr != 0 {
+		if _, err := info.CopyIn(t, infoAddr); err != nil {
+			return 0, nil, err
+		}
+		if info.Signo != int32(sig) {
+			return 0, nil, linuxerr.EINVAL
+		}
+		// If the sender is not the receiver, it can't use si_codes used by
+		// the kernel or SI_TKILL. See rt_sigqueueinfo(2).
+		if (info.Code >= 0 || info.Code == linux.SI_TKILL) && tg != t.ThreadGroup() {
+			return 0, nil, linuxerr.EPERM
+		}
+	} else {
+		// Equivalent to kill(2).
+		info = linux.SignalInfo{
+			Signo: int32(sig),
+			Code:  linux.SI_USER,
+		}
+		info.SetPID(int32(tg.PIDNamespace().IDOfTask(t)))
+		info.SetUID(int32(t.Credentials().RealKUID.In(target.UserNamespace()).OrOverflow()))
+	}
+
+	if !slinux.MayKill(t, target, sig) {
+		return 0, nil, linuxerr.EPERM
+	}
+	return 0, nil, tg.SendSignal(&info)
+}
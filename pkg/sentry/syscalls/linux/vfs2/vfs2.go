@@ -159,6 +159,8 @@ func Override() {
 	s.Table[327] = syscalls.Supported("preadv2", Preadv2)
 	s.Table[328] = syscalls.Supported("pwritev2", Pwritev2)
 	s.Table[332] = syscalls.Supported("statx", Statx)
+	s.Table[424] = syscalls.Supported("pidfd_send_signal", PidfdSendSignal)
+	s.Table[434] = syscalls.Supported("pidfd_open", PidfdOpen)
 	s.Table[441] = syscalls.Supported("epoll_pwait2", EpollPwait2)
 	s.Init()
 
@@ -270,6 +272,8 @@ func Override() {
 	s.Table[286] = syscalls.Supported("preadv2", Preadv2)
 	s.Table[287] = syscalls.Supported("pwritev2", Pwritev2)
 	s.Table[291] = syscalls.Supported("statx", Statx)
+	s.Table[424] = syscalls.Supported("pidfd_send_signal", PidfdSendSignal)
+	s.Table[434] = syscalls.Supported("pidfd_open", PidfdOpen)
 	s.Table[441] = syscalls.Supported("epoll_pwait2", EpollPwait2)
 
 	s.Init()
@@ -254,16 +254,23 @@ func (m *MemoryLocked) Copy() (MemoryStats, uint64) {
 
 // These options control how much total memory the is reported to the application.
 // They may only be set before the application starts executing, and must not
-// be modified.
+// be modified, except through SetMaximumTotalMemoryBytes.
 var (
 	// MinimumTotalMemoryBytes is the minimum reported total system memory.
 	MinimumTotalMemoryBytes uint64 = 2 << 30 // 2 GB
 
 	// MaximumTotalMemoryBytes is the maximum reported total system memory.
-	// The 0 value indicates no maximum.
+	// The 0 value indicates no maximum. It is accessed atomically.
 	MaximumTotalMemoryBytes uint64
 )
 
+// SetMaximumTotalMemoryBytes changes MaximumTotalMemoryBytes while the
+// application may be running, e.g. when the memory limit of the sandbox is
+// updated.
+func SetMaximumTotalMemoryBytes(max uint64) {
+	atomic.StoreUint64(&MaximumTotalMemoryBytes, max)
+}
+
 // TotalMemory returns the "total usable memory" available.
 //
 // This number doesn't really have a true value so it's based on the following
@@ -284,8 +291,8 @@ func TotalMemory(memSize, used uint64) uint64 {
 			memSize = uint64(1) << (uint(msb) + 1)
 		}
 	}
-	if MaximumTotalMemoryBytes > 0 && memSize > MaximumTotalMemoryBytes {
-		memSize = MaximumTotalMemoryBytes
+	if max := atomic.LoadUint64(&MaximumTotalMemoryBytes); max > 0 && memSize > max {
+		memSize = max
 	}
 	return memSize
 }
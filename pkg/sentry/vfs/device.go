@@ -16,6 +16,7 @@ package vfs
 
 import (
 	"fmt"
+	"sort"
 
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
@@ -63,6 +64,22 @@ type Device interface {
 type registeredDevice struct {
 	dev  Device
 	opts RegisterDeviceOptions
+
+	// files are the device special files recorded with RecordDeviceFile.
+	files []DeviceFile
+}
+
+// DeviceFile describes a device special file created for a registered device.
+//
+// +stateify savable
+type DeviceFile struct {
+	// Pathname is the path of the file relative to the root of the devtmpfs
+	// it was created in, which is usually mounted at /dev.
+	Pathname string
+
+	// Perms are the permission bits the file was created with. They don't
+	// reflect later changes, e.g. by chmod.
+	Perms uint16
 }
 
 // RegisterDeviceOptions contains options to
@@ -92,6 +109,64 @@ func (vfs *VirtualFilesystem) RegisterDevice(kind DeviceKind, major, minor uint3
 	return nil
 }
 
+// DeviceInfo describes a device registered with RegisterDevice.
+type DeviceInfo struct {
+	Kind  DeviceKind
+	Major uint32
+	Minor uint32
+
+	// Type is the Go type of the Device implementation.
+	Type string
+
+	// GroupName is RegisterDeviceOptions.GroupName.
+	GroupName string
+
+	// Files are the device special files created for the device. A device
+	// may be registered without any file being created for it.
+	Files []DeviceFile
+}
+
+// RecordDeviceFile records that a device special file described by f was
+// created for the device with the given kind and numbers, so that it's
+// listed by Devices. It's a no-op if no such device is registered.
+func (vfs *VirtualFilesystem) RecordDeviceFile(kind DeviceKind, major, minor uint32, f DeviceFile) {
+	tup := devTuple{kind, major, minor}
+	vfs.devicesMu.Lock()
+	defer vfs.devicesMu.Unlock()
+	if rd, ok := vfs.devices[tup]; ok {
+		rd.files = append(rd.files, f)
+	}
+}
+
+// Devices returns all registered devices, sorted by kind and device number.
+func (vfs *VirtualFilesystem) Devices() []DeviceInfo {
+	vfs.devicesMu.RLock()
+	infos := make([]DeviceInfo, 0, len(vfs.devices))
+	for tup, rd := range vfs.devices {
+		infos = append(infos, DeviceInfo{
+			Kind:      tup.kind,
+			Major:     tup.major,
+			Minor:     tup.minor,
+			Type:      fmt.Sprintf("%T", rd.dev),
+			GroupName: rd.opts.GroupName,
+			Files:     append([]DeviceFile(nil), rd.files...),
+		})
+	}
+	vfs.devicesMu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Major != b.Major {
+			return a.Major < b.Major
+		}
+		return a.Minor < b.Minor
+	})
+	return infos
+}
+
 // OpenDeviceSpecialFile returns a FileDescription representing the given
 // device.
 func (vfs *VirtualFilesystem) OpenDeviceSpecialFile(ctx context.Context, mnt *Mount, d *Dentry, kind DeviceKind, major, minor uint32, opts *OpenOptions) (*FileDescription, error) {
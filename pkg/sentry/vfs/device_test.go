@@ -0,0 +1,83 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfs
+
+import (
+	"reflect"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/contexttest"
+)
+
+type testDevice struct{}
+
+// Open implements Device.Open.
+func (testDevice) Open(ctx context.Context, mnt *Mount, d *Dentry, opts OpenOptions) (*FileDescription, error) {
+	return nil, linuxerr.ENXIO
+}
+
+func TestDevices(t *testing.T) {
+	ctx := contexttest.Context(t)
+
+	vfsObj := &VirtualFilesystem{}
+	if err := vfsObj.Init(ctx); err != nil {
+		t.Fatalf("VFS init: %v", err)
+	}
+	defer vfsObj.Release(ctx)
+
+	if got := vfsObj.Devices(); len(got) != 0 {
+		t.Fatalf("Devices() before registration: got %+v, wanted none", got)
+	}
+
+	for _, dev := range []struct {
+		kind      DeviceKind
+		major     uint32
+		minor     uint32
+		groupName string
+	}{
+		{CharDevice, 5, 0, "tty"},
+		{CharDevice, 1, 3, "mem"},
+		{BlockDevice, 8, 0, ""},
+		{CharDevice, 1, 5, "mem"},
+	} {
+		if err := vfsObj.RegisterDevice(dev.kind, dev.major, dev.minor, testDevice{}, &RegisterDeviceOptions{
+			GroupName: dev.groupName,
+		}); err != nil {
+			t.Fatalf("RegisterDevice(%v, %d, %d): %v", dev.kind, dev.major, dev.minor, err)
+		}
+	}
+
+	// Registering the same device number twice fails.
+	if err := vfsObj.RegisterDevice(CharDevice, 1, 3, testDevice{}, &RegisterDeviceOptions{}); err == nil {
+		t.Errorf("RegisterDevice(%v, 1, 3) for the second time succeeded, wanted error", CharDevice)
+	}
+
+	vfsObj.RecordDeviceFile(CharDevice, 1, 3, DeviceFile{Pathname: "null", Perms: 0666})
+	vfsObj.RecordDeviceFile(CharDevice, 5, 0, DeviceFile{Pathname: "tty", Perms: 0620})
+	// Files for unregistered devices aren't listed.
+	vfsObj.RecordDeviceFile(CharDevice, 10, 229, DeviceFile{Pathname: "fuse", Perms: 0666})
+
+	want := []DeviceInfo{
+		{Kind: BlockDevice, Major: 8, Minor: 0, Type: "vfs.testDevice"},
+		{Kind: CharDevice, Major: 1, Minor: 3, Type: "vfs.testDevice", GroupName: "mem", Files: []DeviceFile{{Pathname: "null", Perms: 0666}}},
+		{Kind: CharDevice, Major: 1, Minor: 5, Type: "vfs.testDevice", GroupName: "mem"},
+		{Kind: CharDevice, Major: 5, Minor: 0, Type: "vfs.testDevice", GroupName: "tty", Files: []DeviceFile{{Pathname: "tty", Perms: 0620}}},
+	}
+	if got := vfsObj.Devices(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Devices(): got %+v, wanted %+v", got, want)
+	}
+}
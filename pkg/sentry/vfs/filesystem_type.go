@@ -99,6 +99,16 @@ func (vfs *VirtualFilesystem) MustRegisterFilesystemType(name string, fsType Fil
 	}
 }
 
+// FilesystemType returns the filesystem type registered under name, or nil if
+// there is none.
+func (vfs *VirtualFilesystem) FilesystemType(name string) FilesystemType {
+	rft := vfs.getFilesystemType(name)
+	if rft == nil {
+		return nil
+	}
+	return rft.fsType
+}
+
 func (vfs *VirtualFilesystem) getFilesystemType(name string) *registeredFilesystemType {
 	vfs.fsTypesMu.RLock()
 	defer vfs.fsTypesMu.RUnlock()
@@ -339,6 +339,114 @@ func (r *Runsc) Run(context context.Context, id, bundle string, opts *CreateOpts
 	return Monitor.Wait(cmd, ec)
 }
 
+// CheckpointOpts is a set of options to Runsc.Checkpoint().
+type CheckpointOpts struct {
+	// ImagePath is the directory where the checkpoint image is saved.
+	ImagePath string
+
+	// LeaveRunning restarts the container after it's checkpointed, instead
+	// of leaving it stopped.
+	LeaveRunning bool
+}
+
+func (o *CheckpointOpts) args() (out []string, err error) {
+	if o.ImagePath == "" {
+		return nil, fmt.Errorf("image path must be provided")
+	}
+	abs, err := filepath.Abs(o.ImagePath)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, "--image-path", abs)
+	if o.LeaveRunning {
+		out = append(out, "--leave-running")
+	}
+	return out, nil
+}
+
+// Checkpoint saves the state of a running container to opts.ImagePath.
+func (r *Runsc) Checkpoint(context context.Context, id string, opts *CheckpointOpts) error {
+	args := []string{"checkpoint"}
+	oargs, err := opts.args()
+	if err != nil {
+		return err
+	}
+	args = append(args, oargs...)
+	if out, _, err := cmdOutput(r.command(context, append(args, id)...), true); err != nil {
+		return fmt.Errorf("unable to checkpoint: %w: %s", err, out)
+	}
+	return nil
+}
+
+// RestoreOpts is a set of options to Runsc.Restore().
+type RestoreOpts struct {
+	CreateOpts
+
+	// ImagePath is the directory of the checkpoint image. It can also be "-"
+	// to stream the state file from Image, or "fd://N" to read it from file
+	// descriptor N of runsc.
+	ImagePath string
+
+	// Image is the state file to restore when ImagePath is "-". It's passed
+	// as stdin, so IO must not set stdin.
+	Image io.Reader
+
+	// Detach makes runsc exit once the container is restored instead of
+	// waiting for it.
+	Detach bool
+}
+
+func (o *RestoreOpts) args() (out []string, err error) {
+	if o.ImagePath == "" {
+		return nil, fmt.Errorf("image path must be provided")
+	}
+	if o.ImagePath == "-" && o.Image == nil {
+		return nil, fmt.Errorf("image path %q requires an image reader", o.ImagePath)
+	}
+	out, err = o.CreateOpts.args()
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, "--image-path", o.ImagePath)
+	if o.Detach {
+		out = append(out, "--detach")
+	}
+	return out, nil
+}
+
+// Restore restores a container from a checkpoint image and returns the exit
+// status of runsc, which is the container's unless opts.Detach is set.
+func (r *Runsc) Restore(context context.Context, id, bundle string, opts *RestoreOpts) (int, error) {
+	args := []string{"restore", "--bundle", bundle}
+	oargs, err := opts.args()
+	if err != nil {
+		return -1, err
+	}
+	args = append(args, oargs...)
+	cmd := r.command(context, append(args, id)...)
+	if opts.IO != nil {
+		opts.Set(cmd)
+	}
+	if opts.ImagePath == "-" {
+		if cmd.Stdin != nil {
+			return -1, fmt.Errorf("image path %q reads the image from stdin, which is already used by the container IO", opts.ImagePath)
+		}
+		cmd.Stdin = opts.Image
+	}
+	ec, err := Monitor.Start(cmd)
+	if err != nil {
+		return -1, err
+	}
+	if opts.IO != nil {
+		if c, ok := opts.IO.(runc.StartCloser); ok {
+			if err := c.CloseAfterStart(); err != nil {
+				return -1, err
+			}
+		}
+	}
+	return Monitor.Wait(cmd, ec)
+}
+
 // DeleteOpts is a set of options to runsc.Delete().
 type DeleteOpts struct {
 	Force bool
@@ -448,6 +556,33 @@ func (r *Runsc) Events(context context.Context, id string, interval time.Duratio
 	return c, nil
 }
 
+// EventsRaw writes the JSON event stream of runsc events to w, without decoding
+// it, until the context is done or runsc exits. It returns nil if it stopped
+// because the context is done.
+func (r *Runsc) EventsRaw(context context.Context, id string, interval time.Duration, w io.Writer) error {
+	cmd := r.command(context, "events", fmt.Sprintf("--interval=%ds", int(interval.Seconds())), id)
+	cmd.Stdout = w
+	stderr := getBuf()
+	defer putBuf(stderr)
+	cmd.Stderr = stderr
+
+	ec, err := Monitor.Start(cmd)
+	if err != nil {
+		return err
+	}
+	status, err := Monitor.Wait(cmd, ec)
+	if context.Err() != nil {
+		return nil
+	}
+	if err == nil && status != 0 {
+		err = fmt.Errorf("%q did not terminate sucessfully", cmd.Args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, stderr)
+	}
+	return nil
+}
+
 // Ps lists all the processes inside the container returning their pids.
 func (r *Runsc) Ps(context context.Context, id string) ([]int, error) {
 	data, stderr, err := cmdOutput(r.command(context, "ps", "--format", "json", id), false)
@@ -0,0 +1,313 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runsc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	runc "github.com/containerd/go-runc"
+)
+
+// fakeMonitor is a runc.ProcessMonitor that records commands instead of
+// running them.
+type fakeMonitor struct {
+	// stdout and stderr are written to the command's stdout and stderr.
+	stdout string
+	stderr string
+
+	// status is the exit status of all commands.
+	status int
+
+	// args are the arguments of the started commands.
+	args [][]string
+
+	// stdin is what the last started command read from its stdin.
+	stdin string
+}
+
+// Start implements runc.ProcessMonitor.Start.
+func (m *fakeMonitor) Start(cmd *exec.Cmd) (chan runc.Exit, error) {
+	m.args = append(m.args, cmd.Args)
+	m.stdin = ""
+	if cmd.Stdin != nil {
+		b, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		m.stdin = string(b)
+	}
+	if cmd.Stdout != nil {
+		io.WriteString(cmd.Stdout, m.stdout)
+	}
+	if cmd.Stderr != nil {
+		io.WriteString(cmd.Stderr, m.stderr)
+	}
+	ec := make(chan runc.Exit, 1)
+	ec <- runc.Exit{Status: m.status}
+	return ec, nil
+}
+
+// Wait implements runc.ProcessMonitor.Wait.
+func (*fakeMonitor) Wait(_ *exec.Cmd, ec chan runc.Exit) (int, error) {
+	e := <-ec
+	return e.Status, nil
+}
+
+// setMonitor replaces Monitor with m for the duration of the test.
+func setMonitor(t *testing.T, m runc.ProcessMonitor) {
+	old := Monitor
+	Monitor = m
+	t.Cleanup(func() {
+		Monitor = old
+	})
+}
+
+// stdinIO is a runc.IO that only sets the command's stdin.
+type stdinIO struct {
+	runc.IO
+}
+
+// Set implements runc.IO.Set.
+func (stdinIO) Set(cmd *exec.Cmd) {
+	cmd.Stdin = strings.NewReader("container stdin")
+}
+
+func TestCheckpoint(t *testing.T) {
+	relPath, err := filepath.Abs("image")
+	if err != nil {
+		t.Fatalf("filepath.Abs: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		opts    CheckpointOpts
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "simple",
+			opts: CheckpointOpts{ImagePath: "/tmp/image"},
+			want: []string{"runsc", "--root=/run/runsc", "checkpoint", "--image-path", "/tmp/image", "id"},
+		},
+		{
+			name: "relative",
+			opts: CheckpointOpts{ImagePath: "image"},
+			want: []string{"runsc", "--root=/run/runsc", "checkpoint", "--image-path", relPath, "id"},
+		},
+		{
+			name: "leave-running",
+			opts: CheckpointOpts{ImagePath: "/tmp/image", LeaveRunning: true},
+			want: []string{"runsc", "--root=/run/runsc", "checkpoint", "--image-path", "/tmp/image", "--leave-running", "id"},
+		},
+		{
+			name:    "no-image-path",
+			opts:    CheckpointOpts{},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &fakeMonitor{}
+			setMonitor(t, m)
+
+			r := &Runsc{Root: "/run/runsc"}
+			err := r.Checkpoint(context.Background(), "id", &tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Checkpoint(%+v) succeeded, want error", tc.opts)
+				}
+				if len(m.args) != 0 {
+					t.Errorf("Checkpoint(%+v) ran %v, want no command", tc.opts, m.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Checkpoint(%+v): %v", tc.opts, err)
+			}
+			if want := [][]string{tc.want}; !reflect.DeepEqual(m.args, want) {
+				t.Errorf("Checkpoint(%+v) ran %v, want %v", tc.opts, m.args, want)
+			}
+		})
+	}
+}
+
+func TestCheckpointFailure(t *testing.T) {
+	setMonitor(t, &fakeMonitor{stdout: "checkpoint failed", status: 1})
+
+	r := &Runsc{}
+	err := r.Checkpoint(context.Background(), "id", &CheckpointOpts{ImagePath: "/tmp/image"})
+	if err == nil || !strings.Contains(err.Error(), "checkpoint failed") {
+		t.Errorf("Checkpoint() = %v, want error with the command output", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		opts      RestoreOpts
+		want      []string
+		wantStdin string
+		wantErr   bool
+	}{
+		{
+			name: "simple",
+			opts: RestoreOpts{ImagePath: "/tmp/image"},
+			want: []string{"runsc", "--root=/run/runsc", "restore", "--bundle", "/bundle", "--image-path", "/tmp/image", "id"},
+		},
+		{
+			name: "detach",
+			opts: RestoreOpts{
+				CreateOpts: CreateOpts{PidFile: "/tmp/pid"},
+				ImagePath:  "/tmp/image",
+				Detach:     true,
+			},
+			want: []string{"runsc", "--root=/run/runsc", "restore", "--bundle", "/bundle", "--pid-file", "/tmp/pid", "--image-path", "/tmp/image", "--detach", "id"},
+		},
+		{
+			name: "fd",
+			opts: RestoreOpts{ImagePath: "fd://3"},
+			want: []string{"runsc", "--root=/run/runsc", "restore", "--bundle", "/bundle", "--image-path", "fd://3", "id"},
+		},
+		{
+			name: "stdin",
+			opts: RestoreOpts{
+				ImagePath: "-",
+				Image:     strings.NewReader("state file"),
+			},
+			want:      []string{"runsc", "--root=/run/runsc", "restore", "--bundle", "/bundle", "--image-path", "-", "id"},
+			wantStdin: "state file",
+		},
+		{
+			name:    "stdin-without-image",
+			opts:    RestoreOpts{ImagePath: "-"},
+			wantErr: true,
+		},
+		{
+			name: "stdin-used-by-io",
+			opts: RestoreOpts{
+				CreateOpts: CreateOpts{IO: stdinIO{}},
+				ImagePath:  "-",
+				Image:      strings.NewReader("state file"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no-image-path",
+			opts:    RestoreOpts{},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &fakeMonitor{}
+			setMonitor(t, m)
+
+			r := &Runsc{Root: "/run/runsc"}
+			status, err := r.Restore(context.Background(), "id", "/bundle", &tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Restore(%+v) succeeded, want error", tc.opts)
+				}
+				if len(m.args) != 0 {
+					t.Errorf("Restore(%+v) ran %v, want no command", tc.opts, m.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Restore(%+v): %v", tc.opts, err)
+			}
+			if status != 0 {
+				t.Errorf("Restore(%+v) = %d, want 0", tc.opts, status)
+			}
+			if want := [][]string{tc.want}; !reflect.DeepEqual(m.args, want) {
+				t.Errorf("Restore(%+v) ran %v, want %v", tc.opts, m.args, want)
+			}
+			if m.stdin != tc.wantStdin {
+				t.Errorf("Restore(%+v) stdin = %q, want %q", tc.opts, m.stdin, tc.wantStdin)
+			}
+		})
+	}
+}
+
+func TestEventsRaw(t *testing.T) {
+	const events = `{"type":"stats","id":"id","data":{"memory":{"usage":{"usage":1024}}}}
+{"type":"oom","id":"id"}
+`
+	m := &fakeMonitor{stdout: events}
+	setMonitor(t, m)
+
+	r := &Runsc{Root: "/run/runsc"}
+	var buf bytes.Buffer
+	if err := r.EventsRaw(context.Background(), "id", 5*time.Second, &buf); err != nil {
+		t.Fatalf("EventsRaw(): %v", err)
+	}
+	if want := [][]string{{"runsc", "--root=/run/runsc", "events", "--interval=5s", "id"}}; !reflect.DeepEqual(m.args, want) {
+		t.Errorf("EventsRaw() ran %v, want %v", m.args, want)
+	}
+
+	// The stream is forwarded as is, so it decodes to the events runsc sent.
+	if got := buf.String(); got != events {
+		t.Errorf("EventsRaw() wrote %q, want %q", got, events)
+	}
+	dec := json.NewDecoder(&buf)
+	var got []runc.Event
+	for {
+		var e runc.Event
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("decoding events: %v", err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(got), got)
+	}
+	if got[0].Type != "stats" || got[0].ID != "id" || got[0].Stats == nil || got[0].Stats.Memory.Usage.Usage != 1024 {
+		t.Errorf("got first event %+v, want stats event with memory usage 1024", got[0])
+	}
+	if got[1].Type != "oom" || got[1].ID != "id" {
+		t.Errorf("got second event %+v, want oom event", got[1])
+	}
+}
+
+func TestEventsRawFailure(t *testing.T) {
+	setMonitor(t, &fakeMonitor{stderr: "container not found", status: 1})
+
+	r := &Runsc{}
+	err := r.EventsRaw(context.Background(), "id", time.Second, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "container not found") {
+		t.Errorf("EventsRaw() = %v, want error with the command stderr", err)
+	}
+}
+
+func TestEventsRawContextDone(t *testing.T) {
+	setMonitor(t, &fakeMonitor{status: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &Runsc{}
+	if err := r.EventsRaw(ctx, "id", time.Second, io.Discard); err != nil {
+		t.Errorf("EventsRaw() after the context is done = %v, want nil", err)
+	}
+}
@@ -19,13 +19,17 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
 	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
 	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
@@ -51,6 +55,54 @@ type TCPListener struct {
 	ep     tcpip.Endpoint
 	wq     *waiter.Queue
 	cancel chan struct{}
+
+	// shutdownOnce makes Shutdown idempotent, as it's also called by Drain.
+	shutdownOnce sync.Once
+
+	// filterMu protects filter and synFilter.
+	filterMu  sync.Mutex
+	filter    SourceFilter
+	synFilter func(peer tcpip.FullAddress) bool
+
+	// connsMu protects the fields below.
+	connsMu sync.Mutex
+
+	// conns is the number of connections returned by Accept that aren't
+	// closed yet.
+	conns int
+
+	// drained, if not nil, is closed once conns drops to zero.
+	drained chan struct{}
+}
+
+// A SourceFilter restricts the source addresses from which a TCPListener
+// accepts connections.
+type SourceFilter struct {
+	// Allow, if not empty, lists the only subnets that connections are
+	// accepted from.
+	Allow []tcpip.Subnet
+
+	// Deny lists subnets that connections are never accepted from. Deny takes
+	// precedence over Allow.
+	Deny []tcpip.Subnet
+}
+
+// permits returns whether f lets connections from addr through.
+func (f *SourceFilter) permits(addr tcpip.Address) bool {
+	for i := range f.Deny {
+		if f.Deny[i].Contains(addr) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for i := range f.Allow {
+		if f.Allow[i].Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
 
 // NewTCPListener creates a new TCPListener from a listening tcpip.Endpoint.
@@ -63,8 +115,30 @@ func NewTCPListener(s *stack.Stack, wq *waiter.Queue, ep tcpip.Endpoint) *TCPLis
 	}
 }
 
+// A ListenTCPOption configures a TCPListener created by ListenTCP. Options
+// are applied before the endpoint starts listening.
+type ListenTCPOption func(l *TCPListener) error
+
+// WithSourceFilter returns a ListenTCPOption that sets f as the listener's
+// SourceFilter, so that no connection from a filtered source is accepted,
+// even right after the listener is created.
+func WithSourceFilter(f SourceFilter) ListenTCPOption {
+	return func(l *TCPListener) error {
+		return l.SetSourceFilter(f)
+	}
+}
+
 // ListenTCP creates a new TCPListener.
-func ListenTCP(s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProtocolNumber) (*TCPListener, error) {
+func ListenTCP(s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProtocolNumber, opts ...ListenTCPOption) (*TCPListener, error) {
+	return listenTCP(s, addr, network, defaultBacklog, nil, opts...)
+}
+
+// defaultBacklog is the listen backlog used by ListenTCP.
+const defaultBacklog = 10
+
+// listenTCP creates a new TCPListener with the given backlog. If control is
+// not nil, it is called with the endpoint before it is bound.
+func listenTCP(s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProtocolNumber, backlog int, control func(tcpip.Endpoint) error, opts ...ListenTCPOption) (*TCPListener, error) {
 	// Create a TCP endpoint, bind it, then start listening.
 	var wq waiter.Queue
 	ep, err := s.NewEndpoint(tcp.ProtocolNumber, network, &wq)
@@ -72,6 +146,13 @@ func ListenTCP(s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProt
 		return nil, errors.New(err.String())
 	}
 
+	if control != nil {
+		if err := control(ep); err != nil {
+			ep.Close()
+			return nil, err
+		}
+	}
+
 	if err := ep.Bind(addr); err != nil {
 		ep.Close()
 		return nil, &net.OpError{
@@ -82,7 +163,15 @@ func ListenTCP(s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProt
 		}
 	}
 
-	if err := ep.Listen(10); err != nil {
+	l := NewTCPListener(s, &wq, ep)
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			ep.Close()
+			return nil, err
+		}
+	}
+
+	if err := ep.Listen(backlog); err != nil {
 		ep.Close()
 		return nil, &net.OpError{
 			Op:   "listen",
@@ -92,7 +181,194 @@ func ListenTCP(s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProt
 		}
 	}
 
-	return NewTCPListener(s, &wq, ep), nil
+	return l, nil
+}
+
+// A ListenConfig contains options for listening on a netstack stack. It
+// mirrors net.ListenConfig, so that stacks can be used with libraries that
+// accept one.
+type ListenConfig struct {
+	// Stack is the stack to listen on.
+	Stack *stack.Stack
+
+	// ReusePort sets SO_REUSEPORT on the endpoint, allowing several
+	// listeners to bind to the same address.
+	ReusePort bool
+
+	// Backlog is the maximum number of pending connections of TCP
+	// listeners. Zero means the ListenTCP default.
+	Backlog int
+
+	// InitialReceiveWindow, if not zero, is the receive window in bytes
+	// advertised in the SYN-ACK of connections accepted by TCP listeners.
+	// See tcpip.TCPInitialReceiveWindowOption.
+	//
+	// It doesn't change the receive buffer size, so buffer auto-tuning
+	// stays enabled and grows the window after the handshake as usual. The
+	// window can't exceed the receive buffer, so a large window may also
+	// need a larger buffer, which disables auto-tuning.
+	InitialReceiveWindow int
+
+	// DisableTimestamps disables the negotiation of the TCP timestamp option
+	// for connections accepted by TCP listeners. See
+	// tcpip.TCPTimestampsOption for the impact on RTT estimation and PAWS.
+	DisableTimestamps bool
+
+	// Control, if not nil, is called after creating the endpoint and before
+	// binding it, e.g. to set socket options. network and address are the
+	// arguments passed to Listen or ListenPacket.
+	Control func(network, address string, ep tcpip.Endpoint) error
+}
+
+// Listen announces on the local network address, which must be an IP literal
+// and port as accepted by net.Listen. Host names are not resolved. network
+// must be "tcp", "tcp4" or "tcp6".
+//
+// ctx is only checked before creating the listener; cancelling it afterwards
+// has no effect.
+func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (net.Listener, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	addr, proto, err := lc.resolve(network, address, "tcp")
+	if err != nil {
+		return nil, err
+	}
+	backlog := lc.Backlog
+	if backlog == 0 {
+		backlog = defaultBacklog
+	}
+	l, err := listenTCP(lc.Stack, addr, proto, backlog, lc.control(network, address))
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ListenPacket announces on the local network address, like Listen. network
+// must be "udp", "udp4" or "udp6".
+func (lc *ListenConfig) ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	addr, proto, err := lc.resolve(network, address, "udp")
+	if err != nil {
+		return nil, err
+	}
+
+	var wq waiter.Queue
+	ep, tcpipErr := lc.Stack.NewEndpoint(udp.ProtocolNumber, proto, &wq)
+	if tcpipErr != nil {
+		return nil, errors.New(tcpipErr.String())
+	}
+	if control := lc.control(network, address); control != nil {
+		if err := control(ep); err != nil {
+			ep.Close()
+			return nil, err
+		}
+	}
+	if err := ep.Bind(addr); err != nil {
+		ep.Close()
+		return nil, &net.OpError{
+			Op:   "bind",
+			Net:  "udp",
+			Addr: fullToUDPAddr(addr),
+			Err:  errors.New(err.String()),
+		}
+	}
+	return NewUDPConn(lc.Stack, &wq, ep), nil
+}
+
+// control returns the function to call with a new endpoint before binding
+// it, or nil if there is nothing to do.
+func (lc *ListenConfig) control(network, address string) func(tcpip.Endpoint) error {
+	rcvWnd := 0
+	noTimestamps := false
+	if strings.HasPrefix(network, "tcp") {
+		rcvWnd = lc.InitialReceiveWindow
+		noTimestamps = lc.DisableTimestamps
+	}
+	if !lc.ReusePort && lc.Control == nil && rcvWnd == 0 && !noTimestamps {
+		return nil
+	}
+	return func(ep tcpip.Endpoint) error {
+		if lc.ReusePort {
+			ep.SocketOptions().SetReusePort(true)
+		}
+		if rcvWnd != 0 {
+			if err := setInitialReceiveWindow(ep, rcvWnd); err != nil {
+				return err
+			}
+		}
+		if noTimestamps {
+			if err := disableTimestamps(ep); err != nil {
+				return err
+			}
+		}
+		if lc.Control != nil {
+			return lc.Control(network, address, ep)
+		}
+		return nil
+	}
+}
+
+// resolve parses address into a full address and the network protocol to
+// use for it. transport is the network without its IP version suffix, e.g.
+// "tcp".
+func (lc *ListenConfig) resolve(network, address, transport string) (tcpip.FullAddress, tcpip.NetworkProtocolNumber, error) {
+	opError := func(err error) error {
+		return &net.OpError{Op: "listen", Net: network, Err: err}
+	}
+
+	var proto tcpip.NetworkProtocolNumber
+	switch network {
+	case transport:
+	case transport + "4":
+		proto = header.IPv4ProtocolNumber
+	case transport + "6":
+		proto = header.IPv6ProtocolNumber
+	default:
+		return tcpip.FullAddress{}, 0, opError(net.UnknownNetworkError(network))
+	}
+
+	host, service, err := net.SplitHostPort(address)
+	if err != nil {
+		return tcpip.FullAddress{}, 0, opError(err)
+	}
+	port, err := net.LookupPort(network, service)
+	if err != nil {
+		return tcpip.FullAddress{}, 0, opError(err)
+	}
+	full := tcpip.FullAddress{Port: uint16(port)}
+
+	if host != "" {
+		var zone string
+		if i := strings.LastIndexByte(host, '%'); i >= 0 {
+			host, zone = host[:i], host[i+1:]
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return tcpip.FullAddress{}, 0, opError(&net.AddrError{Err: "host names are not supported", Addr: address})
+		}
+		if ip4 := ip.To4(); ip4 != nil && proto != header.IPv6ProtocolNumber {
+			full.Addr = tcpip.Address(ip4)
+			proto = header.IPv4ProtocolNumber
+		} else {
+			full.Addr = tcpip.Address(ip.To16())
+			proto = header.IPv6ProtocolNumber
+		}
+		full.NIC = zoneToNIC(zone)
+	}
+
+	if proto == 0 {
+		// Listen on all addresses. A dual-stack IPv6 endpoint also accepts
+		// IPv4 traffic, so prefer it when available.
+		proto = header.IPv4ProtocolNumber
+		if lc.Stack.CheckNetworkProtocol(header.IPv6ProtocolNumber) {
+			proto = header.IPv6ProtocolNumber
+		}
+	}
+	return full, proto, nil
 }
 
 // Close implements net.Listener.Close.
@@ -103,8 +379,110 @@ func (l *TCPListener) Close() error {
 
 // Shutdown stops the HTTP server.
 func (l *TCPListener) Shutdown() {
-	l.ep.Shutdown(tcpip.ShutdownWrite | tcpip.ShutdownRead)
-	close(l.cancel) // broadcast cancellation
+	l.shutdownOnce.Do(func() {
+		l.ep.Shutdown(tcpip.ShutdownWrite | tcpip.ShutdownRead)
+		close(l.cancel) // broadcast cancellation
+	})
+}
+
+// Drain stops accepting connections, like Shutdown, and waits until all the
+// connections returned by Accept are closed. It returns ctx's error if ctx is
+// done first, in which case the remaining connections are left open.
+func (l *TCPListener) Drain(ctx context.Context) error {
+	l.Shutdown()
+
+	l.connsMu.Lock()
+	if l.conns == 0 {
+		l.connsMu.Unlock()
+		return nil
+	}
+	if l.drained == nil {
+		l.drained = make(chan struct{})
+	}
+	drained := l.drained
+	l.connsMu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track counts c as open until it's closed, for Drain.
+func (l *TCPListener) track(c *TCPConn) {
+	l.connsMu.Lock()
+	l.conns++
+	l.connsMu.Unlock()
+	c.onClose = l.untrack
+}
+
+// untrack is called when a connection counted by track is closed.
+func (l *TCPListener) untrack() {
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+	l.conns--
+	if l.conns == 0 && l.drained != nil {
+		close(l.drained)
+		l.drained = nil
+	}
+}
+
+// SetSourceFilter restricts the source addresses that l accepts connections
+// from. SYNs from other addresses are answered with a RST, so they never
+// occupy the accept queue. Connections that were already established when
+// the filter was set are reset by Accept without being returned. The zero
+// SourceFilter accepts connections from any address.
+//
+// To filter connections from the moment the listener is created, use the
+// WithSourceFilter option of ListenTCP.
+func (l *TCPListener) SetSourceFilter(f SourceFilter) error {
+	l.filterMu.Lock()
+	l.filter = f
+	l.filterMu.Unlock()
+	return l.installSynFilter()
+}
+
+// SetSynFilter installs f to be consulted for each SYN received by l, before
+// any endpoint is created for the connection. SYNs from peers for which f
+// returns false are answered with a RST. f is only consulted for peers that
+// the SourceFilter permits. f is called from the stack's processing
+// goroutine and must not block. A nil f removes the filter.
+func (l *TCPListener) SetSynFilter(f func(peer tcpip.FullAddress) bool) error {
+	l.filterMu.Lock()
+	l.synFilter = f
+	l.filterMu.Unlock()
+	return l.installSynFilter()
+}
+
+// installSynFilter makes the endpoint consult permitsSyn for each SYN.
+func (l *TCPListener) installSynFilter() error {
+	if err := l.ep.SetSockOpt(&tcpip.TCPSynFilterOption{Filter: l.permitsSyn}); err != nil {
+		return &net.OpError{
+			Op:   "setsockopt",
+			Net:  "tcp",
+			Addr: l.Addr(),
+			Err:  errors.New(err.String()),
+		}
+	}
+	return nil
+}
+
+// permitsSyn returns whether a SYN from peer is let through by both the
+// SourceFilter and the filter set with SetSynFilter.
+func (l *TCPListener) permitsSyn(peer tcpip.FullAddress) bool {
+	l.filterMu.Lock()
+	permitted := l.filter.permits(peer.Addr)
+	synFilter := l.synFilter
+	l.filterMu.Unlock()
+	return permitted && (synFilter == nil || synFilter(peer))
+}
+
+func (l *TCPListener) permits(addr tcpip.Address) bool {
+	l.filterMu.Lock()
+	defer l.filterMu.Unlock()
+	return l.filter.permits(addr)
 }
 
 // Addr implements net.Listener.Addr.
@@ -120,17 +498,35 @@ type deadlineTimer struct {
 	// mu protects the fields below.
 	mu sync.Mutex
 
-	readTimer     *time.Timer
+	// clock measures deadlines. It's the stack's clock for TCP connections
+	// created by Dial and Accept, and the wall clock otherwise, unless
+	// replaced with SetClock.
+	clock tcpip.Clock
+
+	readTimer     tcpip.Timer
 	readCancelCh  chan struct{}
-	writeTimer    *time.Timer
+	writeTimer    tcpip.Timer
 	writeCancelCh chan struct{}
 }
 
 func (d *deadlineTimer) init() {
+	d.clock = tcpip.NewStdClock()
 	d.readCancelCh = make(chan struct{})
 	d.writeCancelCh = make(chan struct{})
 }
 
+// SetClock replaces the clock used to measure deadlines and timeouts, e.g.
+// with a faketime.ManualClock so that tests of deadlines don't depend on wall
+// time.
+//
+// Deadlines that are already set keep using the previous clock, so SetClock
+// should be called before setting any deadline.
+func (d *deadlineTimer) SetClock(clock tcpip.Clock) {
+	d.mu.Lock()
+	d.clock = clock
+	d.mu.Unlock()
+}
+
 func (d *deadlineTimer) readCancel() <-chan struct{} {
 	d.mu.Lock()
 	c := d.readCancelCh
@@ -151,7 +547,7 @@ func (d *deadlineTimer) writeCancel() <-chan struct{} {
 // deadlineTimer.writeTimer.
 //
 // setDeadline must only be called while holding d.mu.
-func (d *deadlineTimer) setDeadline(cancelCh *chan struct{}, timer **time.Timer, t time.Time) {
+func (d *deadlineTimer) setDeadline(cancelCh *chan struct{}, timer *tcpip.Timer, t time.Time) {
 	if *timer != nil && !(*timer).Stop() {
 		*cancelCh = make(chan struct{})
 	}
@@ -171,7 +567,7 @@ func (d *deadlineTimer) setDeadline(cancelCh *chan struct{}, timer **time.Timer,
 		return
 	}
 
-	timeout := t.Sub(time.Now())
+	timeout := t.Sub(d.clock.Now())
 	if timeout <= 0 {
 		close(*cancelCh)
 		return
@@ -182,7 +578,7 @@ func (d *deadlineTimer) setDeadline(cancelCh *chan struct{}, timer **time.Timer,
 	// the cancel channel to prevent this code from racing with the next
 	// call of setDeadline replacing *cancelCh.
 	ch := *cancelCh
-	*timer = time.AfterFunc(timeout, func() {
+	*timer = d.clock.AfterFunc(timeout, func() {
 		close(ch)
 	})
 }
@@ -232,6 +628,10 @@ type TCPConn struct {
 	// read contains bytes that have been read from the endpoint,
 	// but haven't yet been returned.
 	read buffer.View
+
+	// onClose, if not nil, is called once when the connection is closed.
+	onClose   func()
+	closeOnce sync.Once
 }
 
 // NewTCPConn creates a new TCPConn.
@@ -246,7 +646,30 @@ func NewTCPConn(wq *waiter.Queue, ep tcpip.Endpoint) *TCPConn {
 
 // Accept implements net.Conn.Accept.
 func (l *TCPListener) Accept() (net.Conn, error) {
-	n, wq, err := l.ep.Accept(nil)
+	for {
+		var peer tcpip.FullAddress
+		n, wq, err := l.accept(&peer)
+		if err != nil {
+			return nil, err
+		}
+		if l.permits(peer.Addr) {
+			c := NewTCPConn(wq, n)
+			if l.stack != nil {
+				c.SetClock(l.stack.Clock())
+			}
+			l.track(c)
+			return c, nil
+		}
+		// Reset connections from filtered sources, as if nothing was
+		// listening.
+		n.SocketOptions().SetLinger(tcpip.LingerOption{Enabled: true})
+		n.Close()
+	}
+}
+
+// accept blocks until a connection is accepted or l is shut down.
+func (l *TCPListener) accept(peer *tcpip.FullAddress) (tcpip.Endpoint, *waiter.Queue, error) {
+	n, wq, err := l.ep.Accept(peer)
 
 	if _, ok := err.(*tcpip.ErrWouldBlock); ok {
 		// Create wait queue entry that notifies a channel.
@@ -255,7 +678,7 @@ func (l *TCPListener) Accept() (net.Conn, error) {
 		defer l.wq.EventUnregister(&waitEntry)
 
 		for {
-			n, wq, err = l.ep.Accept(nil)
+			n, wq, err = l.ep.Accept(peer)
 
 			if _, ok := err.(*tcpip.ErrWouldBlock); !ok {
 				break
@@ -263,14 +686,14 @@ func (l *TCPListener) Accept() (net.Conn, error) {
 
 			select {
 			case <-l.cancel:
-				return nil, errCanceled
+				return nil, nil, errCanceled
 			case <-notifyCh:
 			}
 		}
 	}
 
 	if err != nil {
-		return nil, &net.OpError{
+		return nil, nil, &net.OpError{
 			Op:   "accept",
 			Net:  "tcp",
 			Addr: l.Addr(),
@@ -278,7 +701,7 @@ func (l *TCPListener) Accept() (net.Conn, error) {
 		}
 	}
 
-	return NewTCPConn(wq, n), nil
+	return n, wq, nil
 }
 
 type opErrorer interface {
@@ -287,15 +710,21 @@ type opErrorer interface {
 
 // commonRead implements the common logic between net.Conn.Read and
 // net.PacketConn.ReadFrom.
-func commonRead(b []byte, ep tcpip.Endpoint, wq *waiter.Queue, deadline <-chan struct{}, addr *tcpip.FullAddress, errorer opErrorer) (int, error) {
+//
+// If needAddr is true, the result includes the remote address. If peek is
+// true, the data is not consumed from the endpoint.
+func commonRead(b []byte, ep tcpip.Endpoint, wq *waiter.Queue, deadline <-chan struct{}, needAddr, peek bool, errorer opErrorer) (tcpip.ReadResult, error) {
 	select {
 	case <-deadline:
-		return 0, errorer.newOpError("read", &timeoutError{})
+		return tcpip.ReadResult{}, errorer.newOpError("read", &timeoutError{})
 	default:
 	}
 
 	w := tcpip.SliceWriter(b)
-	opts := tcpip.ReadOptions{NeedRemoteAddr: addr != nil}
+	opts := tcpip.ReadOptions{
+		Peek:           peek,
+		NeedRemoteAddr: needAddr,
+	}
 	res, err := ep.Read(&w, opts)
 
 	if _, ok := err.(*tcpip.ErrWouldBlock); ok {
@@ -310,42 +739,104 @@ func commonRead(b []byte, ep tcpip.Endpoint, wq *waiter.Queue, deadline <-chan s
 			}
 			select {
 			case <-deadline:
-				return 0, errorer.newOpError("read", &timeoutError{})
+				return tcpip.ReadResult{}, errorer.newOpError("read", &timeoutError{})
 			case <-notifyCh:
 			}
 		}
 	}
 
 	if _, ok := err.(*tcpip.ErrClosedForReceive); ok {
-		return 0, io.EOF
+		return tcpip.ReadResult{}, io.EOF
 	}
 
 	if err != nil {
-		return 0, errorer.newOpError("read", errors.New(err.String()))
-	}
-
-	if addr != nil {
-		*addr = res.RemoteAddr
+		return tcpip.ReadResult{}, errorer.newOpError("read", errors.New(err.String()))
 	}
-	return res.Count, nil
+	return res, nil
 }
 
 // Read implements net.Conn.Read.
+//
+// Data received before the peer shut down or closed its side of the
+// connection stays readable; io.EOF is only returned once it has all been
+// read.
 func (c *TCPConn) Read(b []byte) (int, error) {
 	c.readMu.Lock()
 	defer c.readMu.Unlock()
 
 	deadline := c.readCancel()
 
-	n, err := commonRead(b, c.ep, c.wq, deadline, nil, c)
-	if n != 0 {
-		c.ep.ModerateRecvBuf(n)
+	res, err := commonRead(b, c.ep, c.wq, deadline, false /* needAddr */, false /* peek */, c)
+	if res.Count != 0 {
+		c.ep.ModerateRecvBuf(res.Count)
+	}
+	return res.Count, err
+}
+
+// PeekFirstByte blocks until at least one byte is available to be read from
+// the connection, without consuming it, or until timeout elapses. It returns
+// a timeout error if no data arrived in time and io.EOF if the peer closed the
+// connection without sending anything.
+//
+// The connection's read deadline is not consulted, but timeout is measured by
+// the same clock. See SetClock.
+func (c *TCPConn) PeekFirstByte(timeout time.Duration) error {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	c.deadlineTimer.mu.Lock()
+	clock := c.deadlineTimer.clock
+	c.deadlineTimer.mu.Unlock()
+
+	deadline := make(chan struct{})
+	t := clock.AfterFunc(timeout, func() {
+		close(deadline)
+	})
+	defer t.Stop()
+
+	var b [1]byte
+	_, err := commonRead(b[:], c.ep, c.wq, deadline, false /* needAddr */, true /* peek */, c)
+	return err
+}
+
+// Available returns the number of bytes that can be read from the connection
+// without blocking, like ioctl(FIONREAD).
+func (c *TCPConn) Available() (int, error) {
+	v, err := c.ep.GetSockOptInt(tcpip.ReceiveQueueSizeOption)
+	if err != nil {
+		return 0, c.newOpError("getsockopt", errors.New(err.String()))
 	}
-	return n, err
+	return v, nil
 }
 
 // Write implements net.Conn.Write.
 func (c *TCPConn) Write(b []byte) (int, error) {
+	var r bytes.Reader
+	return c.write(len(b), func(off int) tcpip.Payloader {
+		r.Reset(b[off:])
+		return &r
+	})
+}
+
+// Writev writes the contents of buffers to the connection, in order, as if
+// they had been concatenated, but without copying them into a single buffer
+// first.
+func (c *TCPConn) Writev(buffers [][]byte) (int, error) {
+	total := 0
+	for _, b := range buffers {
+		total += len(b)
+	}
+	var r buffersReader
+	return c.write(total, func(off int) tcpip.Payloader {
+		r.reset(buffers, off)
+		return &r
+	})
+}
+
+// write contains the common logic between Write and Writev. It writes total
+// bytes to the endpoint; payload must return a tcpip.Payloader over the data
+// remaining after the first off bytes.
+func (c *TCPConn) write(total int, payload func(off int) tcpip.Payloader) (int, error) {
 	deadline := c.writeCancel()
 
 	// Check if deadlineTimer has already expired.
@@ -367,14 +858,12 @@ func (c *TCPConn) Write(b []byte) (int, error) {
 	// There is no guarantee that all of the condition #1s will occur before
 	// all of the condition #2s or visa-versa.
 	var (
-		r      bytes.Reader
 		nbytes int
 		entry  waiter.Entry
 		ch     <-chan struct{}
 	)
-	for nbytes != len(b) {
-		r.Reset(b[nbytes:])
-		n, err := c.ep.Write(&r, tcpip.WriteOptions{})
+	for nbytes != total {
+		n, err := c.ep.Write(payload(nbytes), tcpip.WriteOptions{})
 		nbytes += int(n)
 		switch err.(type) {
 		case nil:
@@ -402,12 +891,102 @@ func (c *TCPConn) Write(b []byte) (int, error) {
 	return nbytes, nil
 }
 
+// buffersReader is a tcpip.Payloader over a sequence of byte slices. It
+// never modifies the slices it reads from.
+type buffersReader struct {
+	bufs [][]byte
+
+	// idx is the index in bufs of the next byte to read, and off is its
+	// offset in bufs[idx].
+	idx int
+	off int
+
+	// len is the number of unread bytes.
+	len int
+}
+
+// reset makes r read from bufs, skipping the first skip bytes.
+func (r *buffersReader) reset(bufs [][]byte, skip int) {
+	*r = buffersReader{bufs: bufs}
+	for _, b := range bufs {
+		r.len += len(b)
+	}
+	r.len -= skip
+	for skip > 0 {
+		if l := len(bufs[r.idx]); skip >= l {
+			skip -= l
+			r.idx++
+		} else {
+			r.off = skip
+			skip = 0
+		}
+	}
+}
+
+// Read implements io.Reader.Read.
+func (r *buffersReader) Read(p []byte) (int, error) {
+	if r.len == 0 {
+		return 0, io.EOF
+	}
+	var n int
+	for n < len(p) && r.idx < len(r.bufs) {
+		c := copy(p[n:], r.bufs[r.idx][r.off:])
+		n += c
+		r.off += c
+		if r.off == len(r.bufs[r.idx]) {
+			r.idx++
+			r.off = 0
+		}
+	}
+	r.len -= n
+	return n, nil
+}
+
+// Len implements tcpip.Payloader.Len.
+func (r *buffersReader) Len() int {
+	return r.len
+}
+
 // Close implements net.Conn.Close.
 func (c *TCPConn) Close() error {
 	c.ep.Close()
+	c.closed()
 	return nil
 }
 
+// closed runs c.onClose the first time the connection is closed.
+func (c *TCPConn) closed() {
+	c.closeOnce.Do(func() {
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+}
+
+// Abort closes the connection immediately by sending a RST to the peer rather
+// than performing a graceful shutdown. Any unsent or unread data is discarded.
+func (c *TCPConn) Abort() error {
+	// A zero linger timeout makes Close reset the connection synchronously.
+	c.ep.SocketOptions().SetLinger(tcpip.LingerOption{Enabled: true})
+	c.ep.Close()
+	c.closed()
+	return nil
+}
+
+// CloseWithError aborts the connection like Abort, recording err as the reason
+// for diagnostics. The reason isn't sent to the peer, which only sees a RST,
+// but it's logged and reported in the endpoint's state. A nil err is the same
+// as calling Abort.
+func (c *TCPConn) CloseWithError(err error) error {
+	if err != nil {
+		reason := tcpip.TCPAbortReasonOption(err.Error())
+		if terr := c.ep.SetSockOpt(&reason); terr != nil {
+			return c.newOpError("close", errors.New(terr.String()))
+		}
+	}
+	return c.Abort()
+}
+
 // CloseRead shuts down the reading side of the TCP connection. Most callers
 // should just use Close.
 //
@@ -448,6 +1027,147 @@ func (c *TCPConn) RemoteAddr() net.Addr {
 	return fullToTCPAddr(a)
 }
 
+// SetKeepAlive implements net.TCPConn.SetKeepAlive.
+func (c *TCPConn) SetKeepAlive(keepalive bool) error {
+	c.ep.SocketOptions().SetKeepAlive(keepalive)
+	return nil
+}
+
+// SetKeepAlivePeriod implements net.TCPConn.SetKeepAlivePeriod. Like on Linux,
+// d is used both as the idle time before the first keepalive probe and as the
+// interval between probes.
+func (c *TCPConn) SetKeepAlivePeriod(d time.Duration) error {
+	if d <= 0 {
+		return c.newOpError("set", errors.New("non-positive keepalive period"))
+	}
+	idle := tcpip.KeepaliveIdleOption(d)
+	if err := c.ep.SetSockOpt(&idle); err != nil {
+		return c.newOpError("set", errors.New(err.String()))
+	}
+	interval := tcpip.KeepaliveIntervalOption(d)
+	if err := c.ep.SetSockOpt(&interval); err != nil {
+		return c.newOpError("set", errors.New(err.String()))
+	}
+	return nil
+}
+
+// SetNoDelay implements net.TCPConn.SetNoDelay. If noDelay is true, Nagle's
+// algorithm is disabled and small writes are sent without waiting for
+// outstanding data to be acknowledged.
+func (c *TCPConn) SetNoDelay(noDelay bool) error {
+	c.ep.SocketOptions().SetDelayOption(!noDelay)
+	return nil
+}
+
+// NoDelay returns whether Nagle's algorithm is disabled, i.e. the value of
+// TCP_NODELAY. It defaults to the stack's tcpip.TCPDelayEnabled option.
+func (c *TCPConn) NoDelay() bool {
+	return !c.ep.SocketOptions().GetDelayOption()
+}
+
+// QuickAck returns whether delayed acknowledgements are disabled, i.e. the
+// value of TCP_QUICKACK.
+func (c *TCPConn) QuickAck() bool {
+	return c.ep.SocketOptions().GetQuickAck()
+}
+
+// SetQuickAck sets TCP_QUICKACK, which disables delayed acknowledgements when
+// true.
+func (c *TCPConn) SetQuickAck(quickAck bool) error {
+	c.ep.SocketOptions().SetQuickAck(quickAck)
+	return nil
+}
+
+// CongestionControl returns the name of the congestion control algorithm used
+// by the connection.
+func (c *TCPConn) CongestionControl() (string, error) {
+	var cc tcpip.CongestionControlOption
+	if err := c.ep.GetSockOpt(&cc); err != nil {
+		return "", c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return string(cc), nil
+}
+
+// SetCongestionControl changes the congestion control algorithm used by the
+// connection. The algorithm must be available in the stack, see
+// tcpip.TCPAvailableCongestionControlOption.
+func (c *TCPConn) SetCongestionControl(name string) error {
+	cc := tcpip.CongestionControlOption(name)
+	switch err := c.ep.SetSockOpt(&cc); err.(type) {
+	case nil:
+		return nil
+	case *tcpip.ErrNoSuchFile:
+		return c.newOpError("setsockopt", fmt.Errorf("congestion control algorithm %q is not available in the stack", name))
+	default:
+		return c.newOpError("setsockopt", errors.New(err.String()))
+	}
+}
+
+// MSS returns the maximum segment size currently used by the connection.
+//
+// Connections that are not yet established report the default MSS.
+func (c *TCPConn) MSS() (int, error) {
+	v, err := c.ep.GetSockOptInt(tcpip.MaxSegOption)
+	if err != nil {
+		return 0, c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return v, nil
+}
+
+// PathMTU returns the MTU of the route used by the connection, excluding the
+// network layer header.
+func (c *TCPConn) PathMTU() (int, error) {
+	v, err := c.ep.GetSockOptInt(tcpip.PathMTUOption)
+	if err != nil {
+		return 0, c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return v, nil
+}
+
+// SACKPermitted returns whether SACK was negotiated for the connection.
+func (c *TCPConn) SACKPermitted() (bool, error) {
+	var info tcpip.TCPInfoOption
+	if err := c.ep.GetSockOpt(&info); err != nil {
+		return false, c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return info.SACKPermitted, nil
+}
+
+// Timestamps returns whether the TCP timestamp option was negotiated for the
+// connection.
+func (c *TCPConn) Timestamps() (bool, error) {
+	var info tcpip.TCPInfoOption
+	if err := c.ep.GetSockOpt(&info); err != nil {
+		return false, c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return info.Timestamps, nil
+}
+
+// WindowScale returns the send and receive window scale factors negotiated
+// for the connection. ok is false if window scaling was not negotiated, in
+// which case both factors are zero.
+func (c *TCPConn) WindowScale() (snd, rcv uint8, ok bool, err error) {
+	var info tcpip.TCPInfoOption
+	if err := c.ep.GetSockOpt(&info); err != nil {
+		return 0, 0, false, c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return info.SndWndScale, info.RcvWndScale, info.WindowScaling, nil
+}
+
+// OriginalDestination returns the destination address of the connection
+// before it was redirected by the stack's iptables rules, e.g. a REDIRECT or
+// DNAT rule. This is the equivalent of SO_ORIGINAL_DST.
+//
+// An error is returned if the connection isn't tracked or its destination
+// wasn't rewritten.
+func (c *TCPConn) OriginalDestination() (tcpip.FullAddress, error) {
+	var dst tcpip.OriginalDestinationOption
+	if err := c.ep.GetSockOpt(&dst); err != nil {
+		return tcpip.FullAddress{}, c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return tcpip.FullAddress(dst), nil
+}
+
 func (c *TCPConn) newOpError(op string, err error) *net.OpError {
 	return &net.OpError{
 		Op:     op,
@@ -459,11 +1179,35 @@ func (c *TCPConn) newOpError(op string, err error) *net.OpError {
 }
 
 func fullToTCPAddr(addr tcpip.FullAddress) *net.TCPAddr {
-	return &net.TCPAddr{IP: net.IP(addr.Addr), Port: int(addr.Port)}
+	return &net.TCPAddr{IP: net.IP(addr.Addr), Port: int(addr.Port), Zone: fullToZone(addr)}
 }
 
 func fullToUDPAddr(addr tcpip.FullAddress) *net.UDPAddr {
-	return &net.UDPAddr{IP: net.IP(addr.Addr), Port: int(addr.Port)}
+	return &net.UDPAddr{IP: net.IP(addr.Addr), Port: int(addr.Port), Zone: fullToZone(addr)}
+}
+
+// fullToZone returns the zone of addr as reported by the net package. Only
+// IPv6 link-local addresses have a zone, which is the decimal ID of the NIC
+// the address is scoped to (e.g. "fe80::1%1"), as NICs are not necessarily
+// backed by a named host interface.
+func fullToZone(addr tcpip.FullAddress) string {
+	if addr.NIC == 0 {
+		return ""
+	}
+	if !header.IsV6LinkLocalUnicastAddress(addr.Addr) && !header.IsV6LinkLocalMulticastAddress(addr.Addr) {
+		return ""
+	}
+	return strconv.Itoa(int(addr.NIC))
+}
+
+// zoneToNIC returns the NIC identified by a zone returned by fullToZone, or
+// zero if zone does not identify a NIC.
+func zoneToNIC(zone string) tcpip.NICID {
+	id, err := strconv.ParseInt(zone, 10, 32)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return tcpip.NICID(id)
 }
 
 // DialTCP creates a new TCPConn connected to the specified address.
@@ -471,9 +1215,135 @@ func DialTCP(s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProtoc
 	return DialContextTCP(context.Background(), s, addr, network)
 }
 
+// DialTCPFrom creates a new TCPConn bound to laddr and connected to raddr.
+//
+// It fails if laddr is not a local address or its port is already in use.
+func DialTCPFrom(s *stack.Stack, laddr, raddr tcpip.FullAddress, network tcpip.NetworkProtocolNumber) (*TCPConn, error) {
+	return dialContextTCP(context.Background(), s, &laddr, raddr, network, nil)
+}
+
 // DialContextTCP creates a new TCPConn connected to the specified address
 // with the option of adding cancellation and timeouts.
 func DialContextTCP(ctx context.Context, s *stack.Stack, addr tcpip.FullAddress, network tcpip.NetworkProtocolNumber) (*TCPConn, error) {
+	return dialContextTCP(ctx, s, nil, addr, network, nil)
+}
+
+// A Dialer contains options for connecting to an address on a netstack stack.
+// It mirrors the parts of net.Dialer that apply to netstack.
+type Dialer struct {
+	// Stack is the stack to dial on.
+	Stack *stack.Stack
+
+	// Timeout is the maximum amount of time a dial will wait for a connect
+	// to complete. Zero means no timeout.
+	Timeout time.Duration
+
+	// Deadline is the absolute point in time after which dials will fail.
+	// Zero means no deadline.
+	//
+	// If Timeout is also set, or the dial context has a deadline, the
+	// earliest of them is used.
+	Deadline time.Time
+
+	// LocalAddr is the local address to use when dialing. If nil, a local
+	// address is chosen automatically.
+	LocalAddr *tcpip.FullAddress
+
+	// InitialReceiveWindow, if not zero, is the receive window in bytes
+	// advertised in the SYN. See tcpip.TCPInitialReceiveWindowOption.
+	//
+	// It doesn't change the receive buffer size, so buffer auto-tuning
+	// stays enabled and grows the window after the handshake as usual. The
+	// window can't exceed the receive buffer, so a large window may also
+	// need a larger buffer, which disables auto-tuning.
+	InitialReceiveWindow int
+
+	// DisableTimestamps disables the negotiation of the TCP timestamp
+	// option. See tcpip.TCPTimestampsOption for the impact on RTT estimation
+	// and PAWS.
+	DisableTimestamps bool
+}
+
+// deadline returns the earliest of the dialer's Timeout and Deadline, and
+// ctx's deadline. It returns the zero time if none is set.
+func (d *Dialer) deadline(ctx context.Context, now time.Time) time.Time {
+	var earliest time.Time
+	if d.Timeout != 0 {
+		earliest = now.Add(d.Timeout)
+	}
+	if !d.Deadline.IsZero() && (earliest.IsZero() || d.Deadline.Before(earliest)) {
+		earliest = d.Deadline
+	}
+	if dl, ok := ctx.Deadline(); ok && (earliest.IsZero() || dl.Before(earliest)) {
+		earliest = dl
+	}
+	return earliest
+}
+
+// DialTCP creates a new TCPConn connected to the specified address.
+func (d *Dialer) DialTCP(addr tcpip.FullAddress, network tcpip.NetworkProtocolNumber) (*TCPConn, error) {
+	return d.DialContextTCP(context.Background(), addr, network)
+}
+
+// DialContextTCP creates a new TCPConn connected to the specified address.
+// The connect fails once ctx is done or the dialer's deadline is reached,
+// whichever comes first, in which case the partially connected endpoint is
+// closed.
+func (d *Dialer) DialContextTCP(ctx context.Context, addr tcpip.FullAddress, network tcpip.NetworkProtocolNumber) (*TCPConn, error) {
+	if deadline := d.deadline(ctx, time.Now()); !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	var control func(tcpip.Endpoint) error
+	if d.InitialReceiveWindow != 0 || d.DisableTimestamps {
+		control = func(ep tcpip.Endpoint) error {
+			if d.InitialReceiveWindow != 0 {
+				if err := setInitialReceiveWindow(ep, d.InitialReceiveWindow); err != nil {
+					return err
+				}
+			}
+			if d.DisableTimestamps {
+				return disableTimestamps(ep)
+			}
+			return nil
+		}
+	}
+	return dialContextTCP(ctx, d.Stack, d.LocalAddr, addr, network, control)
+}
+
+// disableTimestamps disables the negotiation of the timestamp option by TCP
+// endpoint ep.
+func disableTimestamps(ep tcpip.Endpoint) error {
+	opt := tcpip.TCPTimestampsOption(false)
+	if err := ep.SetSockOpt(&opt); err != nil {
+		return &net.OpError{
+			Op:  "setsockopt",
+			Net: "tcp",
+			Err: errors.New(err.String()),
+		}
+	}
+	return nil
+}
+
+// setInitialReceiveWindow sets the receive window advertised in the SYN or
+// SYN-ACK of TCP endpoint ep.
+func setInitialReceiveWindow(ep tcpip.Endpoint, n int) error {
+	opt := tcpip.TCPInitialReceiveWindowOption(n)
+	if err := ep.SetSockOpt(&opt); err != nil {
+		return &net.OpError{
+			Op:  "setsockopt",
+			Net: "tcp",
+			Err: errors.New(err.String()),
+		}
+	}
+	return nil
+}
+
+// dialContextTCP creates a new TCPConn connected to addr. If laddr is not
+// nil, the endpoint is bound to it before connecting. If control is not nil,
+// it's called with the endpoint before binding and connecting it.
+func dialContextTCP(ctx context.Context, s *stack.Stack, laddr *tcpip.FullAddress, addr tcpip.FullAddress, network tcpip.NetworkProtocolNumber, control func(tcpip.Endpoint) error) (*TCPConn, error) {
 	// Create TCP endpoint, then connect.
 	var wq waiter.Queue
 	ep, err := s.NewEndpoint(tcp.ProtocolNumber, network, &wq)
@@ -481,6 +1351,25 @@ func DialContextTCP(ctx context.Context, s *stack.Stack, addr tcpip.FullAddress,
 		return nil, errors.New(err.String())
 	}
 
+	if control != nil {
+		if err := control(ep); err != nil {
+			ep.Close()
+			return nil, err
+		}
+	}
+
+	if laddr != nil {
+		if err := ep.Bind(*laddr); err != nil {
+			ep.Close()
+			return nil, &net.OpError{
+				Op:   "bind",
+				Net:  "tcp",
+				Addr: fullToTCPAddr(*laddr),
+				Err:  errors.New(err.String()),
+			}
+		}
+	}
+
 	// Create wait queue entry that notifies a channel.
 	//
 	// We do this unconditionally as Connect will always return an error.
@@ -490,6 +1379,7 @@ func DialContextTCP(ctx context.Context, s *stack.Stack, addr tcpip.FullAddress,
 
 	select {
 	case <-ctx.Done():
+		ep.Close()
 		return nil, ctx.Err()
 	default:
 	}
@@ -515,7 +1405,9 @@ func DialContextTCP(ctx context.Context, s *stack.Stack, addr tcpip.FullAddress,
 		}
 	}
 
-	return NewTCPConn(&wq, ep), nil
+	c := NewTCPConn(&wq, ep)
+	c.SetClock(s.Clock())
+	return c, nil
 }
 
 // A UDPConn is a wrapper around a UDP tcpip.Endpoint that implements
@@ -528,7 +1420,12 @@ type UDPConn struct {
 	wq    *waiter.Queue
 }
 
-// NewUDPConn creates a new UDPConn.
+// NewUDPConn creates a new UDPConn wrapping ep, which must have been created
+// with wq as its waiter queue.
+//
+// ep may be bound and/or connected. If it is connected, Write and Read use
+// the connected peer; otherwise WriteTo must be used to specify the
+// destination.
 func NewUDPConn(s *stack.Stack, wq *waiter.Queue, ep tcpip.Endpoint) *UDPConn {
 	c := &UDPConn{
 		stack: s,
@@ -539,12 +1436,29 @@ func NewUDPConn(s *stack.Stack, wq *waiter.Queue, ep tcpip.Endpoint) *UDPConn {
 	return c
 }
 
+// NewUDPConnFromEndpoint creates a new UDPConn from a UDP endpoint that the
+// caller created and configured itself, e.g. by setting socket options or
+// binding it to a device. See NewUDPConn for the requirements on ep and wq.
+func NewUDPConnFromEndpoint(ep tcpip.Endpoint, wq *waiter.Queue) *UDPConn {
+	return NewUDPConn(nil, wq, ep)
+}
+
 // DialUDP creates a new UDPConn.
 //
 // If laddr is nil, a local address is automatically chosen.
 //
 // If raddr is nil, the UDPConn is left unconnected.
 func DialUDP(s *stack.Stack, laddr, raddr *tcpip.FullAddress, network tcpip.NetworkProtocolNumber) (*UDPConn, error) {
+	return DialContextUDP(context.Background(), s, laddr, raddr, network)
+}
+
+// DialContextUDP is like DialUDP, but fails with ctx's error if ctx is done
+// before the UDPConn is bound and connected.
+func DialContextUDP(ctx context.Context, s *stack.Stack, laddr, raddr *tcpip.FullAddress, network tcpip.NetworkProtocolNumber) (*UDPConn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var wq waiter.Queue
 	ep, err := s.NewEndpoint(udp.ProtocolNumber, network, &wq)
 	if err != nil {
@@ -566,6 +1480,10 @@ func DialUDP(s *stack.Stack, laddr, raddr *tcpip.FullAddress, network tcpip.Netw
 	c := NewUDPConn(s, &wq, ep)
 
 	if raddr != nil {
+		if err := ctx.Err(); err != nil {
+			c.ep.Close()
+			return nil, err
+		}
 		if err := c.ep.Connect(*raddr); err != nil {
 			c.ep.Close()
 			return nil, &net.OpError{
@@ -609,42 +1527,93 @@ func (c *UDPConn) Read(b []byte) (int, error) {
 	return bytesRead, err
 }
 
+// JoinGroup joins the multicast group 'group' on NIC 'nicID', after which
+// datagrams sent to the group and to the port c is bound to are returned by
+// ReadFrom. If nicID is zero, the NIC is chosen based on the route to the
+// group.
+func (c *UDPConn) JoinGroup(nicID tcpip.NICID, group tcpip.Address) error {
+	opt := tcpip.AddMembershipOption{NIC: nicID, MulticastAddr: group}
+	if err := c.ep.SetSockOpt(&opt); err != nil {
+		return c.newOpError("join group", errors.New(err.String()))
+	}
+	return nil
+}
+
+// LeaveGroup leaves a multicast group previously joined with JoinGroup.
+func (c *UDPConn) LeaveGroup(nicID tcpip.NICID, group tcpip.Address) error {
+	opt := tcpip.RemoveMembershipOption{NIC: nicID, MulticastAddr: group}
+	if err := c.ep.SetSockOpt(&opt); err != nil {
+		return c.newOpError("leave group", errors.New(err.String()))
+	}
+	return nil
+}
+
 // ReadFrom implements net.PacketConn.ReadFrom.
 func (c *UDPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, _, _, addr, err := c.ReadMsgUDP(b, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, addr, nil
+}
+
+// MsgTrunc is set in the flags returned by ReadMsgUDP when the datagram didn't
+// fit in the read buffer. It has the value of MSG_TRUNC on Linux.
+const MsgTrunc = 0x20
+
+// ReadMsgUDP reads a datagram like ReadFrom, mirroring net.UDPConn.ReadMsgUDP.
+// If the datagram is larger than b, the excess is discarded and flags has
+// MsgTrunc set. Control messages aren't supported, so oobn is always zero.
+func (c *UDPConn) ReadMsgUDP(b, oob []byte) (n, oobn, flags int, addr *net.UDPAddr, err error) {
 	deadline := c.readCancel()
 
-	var addr tcpip.FullAddress
-	n, err := commonRead(b, c.ep, c.wq, deadline, &addr, c)
+	res, err := commonRead(b, c.ep, c.wq, deadline, true /* needAddr */, false /* peek */, c)
 	if err != nil {
-		return 0, nil, err
+		return 0, 0, 0, nil, err
+	}
+	if res.Total > res.Count {
+		flags |= MsgTrunc
 	}
-	return n, fullToUDPAddr(addr), nil
+	return res.Count, 0, flags, fullToUDPAddr(res.RemoteAddr), nil
 }
 
+// Write implements net.Conn.Write.
 func (c *UDPConn) Write(b []byte) (int, error) {
 	return c.WriteTo(b, nil)
 }
 
 // WriteTo implements net.PacketConn.WriteTo.
 func (c *UDPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	// If we're being called by Write, there is no addr
+	if addr == nil {
+		return c.write(b, nil)
+	}
+	ua := addr.(*net.UDPAddr)
+	return c.write(b, &tcpip.FullAddress{
+		NIC:  zoneToNIC(ua.Zone),
+		Addr: tcpip.Address(ua.IP),
+		Port: uint16(ua.Port),
+	})
+}
+
+// WriteToFull is like WriteTo, but takes the destination as a
+// tcpip.FullAddress, avoiding the conversion from a net.Addr.
+func (c *UDPConn) WriteToFull(b []byte, addr tcpip.FullAddress) (int, error) {
+	return c.write(b, &addr)
+}
+
+// write writes b to the endpoint, sending it to addr if it is not nil.
+func (c *UDPConn) write(b []byte, addr *tcpip.FullAddress) (int, error) {
 	deadline := c.writeCancel()
 
 	// Check if deadline has already expired.
 	select {
 	case <-deadline:
-		return 0, c.newRemoteOpError("write", addr, &timeoutError{})
+		return 0, c.newRemoteOpError("write", udpAddrOrNil(addr), &timeoutError{})
 	default:
 	}
 
-	// If we're being called by Write, there is no addr
-	writeOptions := tcpip.WriteOptions{}
-	if addr != nil {
-		ua := addr.(*net.UDPAddr)
-		writeOptions.To = &tcpip.FullAddress{
-			Addr: tcpip.Address(ua.IP),
-			Port: uint16(ua.Port),
-		}
-	}
+	writeOptions := tcpip.WriteOptions{To: addr}
 
 	var r bytes.Reader
 	r.Reset(b)
@@ -657,7 +1626,7 @@ func (c *UDPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
 		for {
 			select {
 			case <-deadline:
-				return int(n), c.newRemoteOpError("write", addr, &timeoutError{})
+				return int(n), c.newRemoteOpError("write", udpAddrOrNil(addr), &timeoutError{})
 			case <-notifyCh:
 			}
 
@@ -672,7 +1641,25 @@ func (c *UDPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
 		return int(n), nil
 	}
 
-	return int(n), c.newRemoteOpError("write", addr, errors.New(err.String()))
+	return int(n), c.newRemoteOpError("write", udpAddrOrNil(addr), errors.New(err.String()))
+}
+
+// udpAddrOrNil converts addr to a net.Addr, keeping nil as a nil interface.
+func udpAddrOrNil(addr *tcpip.FullAddress) net.Addr {
+	if addr == nil {
+		return nil
+	}
+	return fullToUDPAddr(*addr)
+}
+
+// Available returns the size of the next datagram to be read, or zero if
+// none is queued, like ioctl(FIONREAD).
+func (c *UDPConn) Available() (int, error) {
+	v, err := c.ep.GetSockOptInt(tcpip.ReceiveQueueSizeOption)
+	if err != nil {
+		return 0, c.newOpError("getsockopt", errors.New(err.String()))
+	}
+	return v, nil
 }
 
 // Close implements net.PacketConn.Close.
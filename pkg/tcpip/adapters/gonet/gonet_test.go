@@ -15,17 +15,22 @@
 package gonet
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/net/nettest"
+	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/faketime"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
 	"gvisor.dev/gvisor/pkg/tcpip/link/loopback"
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
@@ -509,7 +514,7 @@ func TestPacketConnTransfer(t *testing.T) {
 	}
 }
 
-func TestConnectedPacketConnTransfer(t *testing.T) {
+func TestUDPConnWriteToFull(t *testing.T) {
 	s, e := newLoopbackStack()
 	if e != nil {
 		t.Fatalf("newLoopbackStack() = %v", e)
@@ -519,18 +524,61 @@ func TestConnectedPacketConnTransfer(t *testing.T) {
 		s.Wait()
 	}()
 
-	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
-	addr := tcpip.FullAddress{NICID, ip, 11211}
-	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+	ip1 := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	addr1 := tcpip.FullAddress{NICID, ip1, 11211}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip1)
+	ip2 := tcpip.Address(net.IPv4(169, 254, 10, 2).To4())
+	addr2 := tcpip.FullAddress{NICID, ip2, 11311}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip2)
 
-	c1, err := DialUDP(s, &addr, nil, ipv4.ProtocolNumber)
+	c1, err := DialUDP(s, &addr1, nil, ipv4.ProtocolNumber)
 	if err != nil {
 		t.Fatal("DialUDP(bind port 4):", err)
 	}
-	c2, err := DialUDP(s, nil, &addr, ipv4.ProtocolNumber)
+	defer c1.Close()
+	c2, err := DialUDP(s, &addr2, nil, ipv4.ProtocolNumber)
 	if err != nil {
 		t.Fatal("DialUDP(bind port 5):", err)
 	}
+	defer c2.Close()
+
+	c1.SetDeadline(time.Now().Add(time.Second))
+	c2.SetDeadline(time.Now().Add(time.Second))
+
+	sent := "abc123"
+	if n, err := c1.WriteToFull([]byte(sent), addr2); err != nil || n != len(sent) {
+		t.Errorf("got c1.WriteToFull(%q, %v) = %d, %v, want = %d, %v", sent, addr2, n, err, len(sent), nil)
+	}
+	recv := make([]byte, len(sent))
+	n, recvAddr, err := c2.ReadFrom(recv)
+	if err != nil || n != len(recv) {
+		t.Errorf("got c2.ReadFrom() = %d, %v, want = %d, %v", n, err, len(recv), nil)
+	}
+	if recv := string(recv); recv != sent {
+		t.Errorf("got recv = %q, want = %q", recv, sent)
+	}
+	if want := fullToUDPAddr(addr1); !reflect.DeepEqual(recvAddr, want) {
+		t.Errorf("got recvAddr = %v, want = %v", recvAddr, want)
+	}
+
+	// Errors report the destination as a net.Addr.
+	c1.SetWriteDeadline(time.Now().Add(-time.Second))
+	_, err = c1.WriteToFull([]byte(sent), addr2)
+	opErr, ok := err.(*net.OpError)
+	if !ok || !opErr.Timeout() {
+		t.Fatalf("got c1.WriteToFull() after the deadline = %v, want timeout", err)
+	}
+	if want := fullToUDPAddr(addr2); !reflect.DeepEqual(opErr.Addr, want) {
+		t.Errorf("got error address = %v, want = %v", opErr.Addr, want)
+	}
+}
+
+func TestConnectedPacketConnTransfer(t *testing.T) {
+	c1, c2, stop, err := udpPipe()
+	if err != nil {
+		t.Fatalf("udpPipe() = %v", err)
+	}
+	defer stop()
 
 	c1.SetDeadline(time.Now().Add(time.Second))
 	c2.SetDeadline(time.Now().Add(time.Second))
@@ -557,6 +605,204 @@ func TestConnectedPacketConnTransfer(t *testing.T) {
 	}
 }
 
+func TestUDPPipe(t *testing.T) {
+	c1, c2, stop, err := udpPipe()
+	if err != nil {
+		t.Fatalf("udpPipe() = %v", err)
+	}
+	defer stop()
+
+	c1.SetDeadline(time.Now().Add(time.Second))
+	c2.SetDeadline(time.Now().Add(time.Second))
+
+	for _, test := range []struct {
+		name     string
+		src, dst *UDPConn
+	}{
+		{"c1 to c2", c1, c2},
+		{"c2 to c1", c2, c1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sent := "abc123"
+			if n, err := test.src.Write([]byte(sent)); err != nil || n != len(sent) {
+				t.Fatalf("got src.Write(%q) = %d, %v, want = %d, %v", sent, n, err, len(sent), nil)
+			}
+			recv := make([]byte, len(sent))
+			n, err := test.dst.Read(recv)
+			if err != nil || n != len(recv) {
+				t.Fatalf("got dst.Read() = %d, %v, want = %d, %v", n, err, len(recv), nil)
+			}
+			if recv := string(recv); recv != sent {
+				t.Errorf("got recv = %q, want = %q", recv, sent)
+			}
+		})
+	}
+}
+
+func TestDeadlineTimerSetClock(t *testing.T) {
+	c1, _, stop, err := udpPipe()
+	if err != nil {
+		t.Fatalf("udpPipe() = %v", err)
+	}
+	defer stop()
+
+	clock := faketime.NewManualClock()
+	c1.SetClock(clock)
+	c1.SetReadDeadline(clock.Now().Add(time.Hour))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c1.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	// The wall clock doesn't expire the deadline.
+	select {
+	case err := <-done:
+		t.Fatalf("c1.Read() = %v before the deadline", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Hour)
+	err = <-done
+	if opErr, ok := err.(*net.OpError); !ok || !opErr.Timeout() {
+		t.Errorf("c1.Read() = %v, want timeout", err)
+	}
+
+	// A deadline that is already past according to the clock expires
+	// immediately.
+	c1.SetWriteDeadline(clock.Now())
+	if _, err := c1.Write([]byte("a")); err == nil {
+		t.Errorf("c1.Write() succeeded after the deadline")
+	} else if opErr, ok := err.(*net.OpError); !ok || !opErr.Timeout() {
+		t.Errorf("c1.Write() = %v, want timeout", err)
+	}
+}
+
+func TestUDPConnMulticast(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+	group := tcpip.Address(net.IPv4(224, 0, 0, 251).To4())
+	const port = 5353
+
+	recv, err := DialUDP(s, &tcpip.FullAddress{Port: port}, nil, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialUDP(recv) = %v", err)
+	}
+	defer recv.Close()
+	if err := recv.JoinGroup(NICID, group); err != nil {
+		t.Fatalf("got JoinGroup() = %v, want = nil", err)
+	}
+
+	send, err := DialUDP(s, &tcpip.FullAddress{NIC: NICID, Addr: ip}, nil, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialUDP(send) = %v", err)
+	}
+	defer send.Close()
+
+	recv.SetDeadline(time.Now().Add(time.Second))
+	send.SetDeadline(time.Now().Add(time.Second))
+
+	sent := "abc123"
+	to := &net.UDPAddr{IP: net.IP(group), Port: port}
+	if n, err := send.WriteTo([]byte(sent), to); err != nil || n != len(sent) {
+		t.Fatalf("got send.WriteTo(%q, %v) = %d, %v, want = %d, %v", sent, to, n, err, len(sent), nil)
+	}
+	buf := make([]byte, 100)
+	n, _, err := recv.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("got recv.ReadFrom() = %v, want = nil", err)
+	}
+	if got := string(buf[:n]); got != sent {
+		t.Errorf("got recv.ReadFrom() = %q, want = %q", got, sent)
+	}
+
+	if err := recv.LeaveGroup(NICID, group); err != nil {
+		t.Errorf("got LeaveGroup() = %v, want = nil", err)
+	}
+	if err := recv.LeaveGroup(NICID, group); err == nil {
+		t.Errorf("got second LeaveGroup() = nil, want error")
+	}
+}
+
+func TestUDPConnFromEndpoint(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+
+	// Unconnected endpoint bound by the caller.
+	var wq1 waiter.Queue
+	ep1, e := s.NewEndpoint(udp.ProtocolNumber, ipv4.ProtocolNumber, &wq1)
+	if e != nil {
+		t.Fatalf("NewEndpoint() = %v", e)
+	}
+	if e := ep1.Bind(addr); e != nil {
+		t.Fatalf("ep1.Bind(%v) = %v", addr, e)
+	}
+	c1 := NewUDPConnFromEndpoint(ep1, &wq1)
+	defer c1.Close()
+	if got := c1.RemoteAddr(); got != nil {
+		t.Errorf("got c1.RemoteAddr() = %v, want = nil", got)
+	}
+
+	// Connected endpoint.
+	var wq2 waiter.Queue
+	ep2, e := s.NewEndpoint(udp.ProtocolNumber, ipv4.ProtocolNumber, &wq2)
+	if e != nil {
+		t.Fatalf("NewEndpoint() = %v", e)
+	}
+	if e := ep2.Connect(addr); e != nil {
+		t.Fatalf("ep2.Connect(%v) = %v", addr, e)
+	}
+	c2 := NewUDPConnFromEndpoint(ep2, &wq2)
+	defer c2.Close()
+	if got, want := c2.RemoteAddr(), fullToUDPAddr(addr); !reflect.DeepEqual(got, want) {
+		t.Errorf("got c2.RemoteAddr() = %v, want = %v", got, want)
+	}
+
+	c1.SetDeadline(time.Now().Add(time.Second))
+	c2.SetDeadline(time.Now().Add(time.Second))
+
+	sent := "abc123"
+	if n, err := c2.Write([]byte(sent)); err != nil || n != len(sent) {
+		t.Errorf("got c2.Write(%q) = %d, %v, want = %d, %v", sent, n, err, len(sent), nil)
+	}
+	recv := make([]byte, len(sent))
+	n, recvAddr, err := c1.ReadFrom(recv)
+	if err != nil || n != len(recv) {
+		t.Errorf("got c1.ReadFrom() = %d, %v, want = %d, %v", n, err, len(recv), nil)
+	}
+	if recv := string(recv); recv != sent {
+		t.Errorf("got recv = %q, want = %q", recv, sent)
+	}
+
+	// Reply through the unconnected endpoint.
+	if n, err := c1.WriteTo([]byte(sent), recvAddr); err != nil || n != len(sent) {
+		t.Errorf("got c1.WriteTo(%q, %v) = %d, %v, want = %d, %v", sent, recvAddr, n, err, len(sent), nil)
+	}
+	if n, err := c2.Read(recv); err != nil || n != len(recv) {
+		t.Errorf("got c2.Read() = %d, %v, want = %d, %v", n, err, len(recv), nil)
+	}
+}
+
 func makePipe() (c1, c2 net.Conn, stop func(), err error) {
 	s, e := newLoopbackStack()
 	if e != nil {
@@ -647,79 +893,1254 @@ func TestTCPConnTransfer(t *testing.T) {
 	}
 }
 
-func TestTCPDialError(t *testing.T) {
-	s, e := newLoopbackStack()
-	if e != nil {
-		t.Fatalf("newLoopbackStack() = %v", e)
+func TestTCPConnMSSAndPathMTU(t *testing.T) {
+	c1, c2, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
 	}
-	defer func() {
-		s.Close()
-		s.Wait()
-	}()
+	defer stop()
 
-	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
-	addr := tcpip.FullAddress{NICID, ip, 11211}
-
-	switch _, err := DialTCP(s, addr, ipv4.ProtocolNumber); err := err.(type) {
-	case *net.OpError:
-		if err.Err.Error() != (&tcpip.ErrNoRoute{}).String() {
-			t.Errorf("got DialTCP() = %s, want = %s", err, &tcpip.ErrNoRoute{})
+	for _, c := range []net.Conn{c1, c2} {
+		tc := c.(*TCPConn)
+		mtu, err := tc.PathMTU()
+		if err != nil {
+			t.Fatalf("PathMTU() = %v", err)
+		}
+		if want := int(loopback.New().MTU()) - header.IPv4MinimumSize; mtu != want {
+			t.Errorf("got PathMTU() = %d, want = %d", mtu, want)
+		}
+		mss, err := tc.MSS()
+		if err != nil {
+			t.Fatalf("MSS() = %v", err)
+		}
+		if mss <= 0 || mss > mtu-header.TCPMinimumSize {
+			t.Errorf("got MSS() = %d, want in range (0, %d]", mss, mtu-header.TCPMinimumSize)
 		}
-	default:
-		t.Errorf("got DialTCP(...) = %v, want %s", err, &tcpip.ErrNoRoute{})
 	}
 }
 
-func TestDialContextTCPCanceled(t *testing.T) {
-	s, err := newLoopbackStack()
+func TestTCPConnPeekFirstByte(t *testing.T) {
+	c1, c2, stop, err := makePipe()
 	if err != nil {
-		t.Fatalf("newLoopbackStack() = %v", err)
+		t.Fatal(err)
 	}
-	defer func() {
-		s.Close()
-		s.Wait()
+	defer stop()
+
+	tc := c2.(*TCPConn)
+	err = tc.PeekFirstByte(10 * time.Millisecond)
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("got PeekFirstByte() = %v, want timeout error", err)
+	}
+
+	const sent = "abc123"
+	if _, err := c1.Write([]byte(sent)); err != nil {
+		t.Fatalf("c1.Write(%q) = %v", sent, err)
+	}
+	if err := tc.PeekFirstByte(time.Second); err != nil {
+		t.Fatalf("got PeekFirstByte() = %v, want = nil", err)
+	}
+
+	// The peeked data must still be readable.
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	recv := make([]byte, len(sent))
+	if _, err := io.ReadFull(c2, recv); err != nil {
+		t.Fatalf("io.ReadFull() = %v", err)
+	}
+	if got := string(recv); got != sent {
+		t.Errorf("got recv = %q, want = %q", got, sent)
+	}
+}
+
+func TestTCPConnPeekFirstByteClock(t *testing.T) {
+	_, c2, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	tc := c2.(*TCPConn)
+	clock := faketime.NewManualClock()
+	tc.SetClock(clock)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tc.PeekFirstByte(time.Hour)
 	}()
 
-	addr := tcpip.FullAddress{NICID, tcpip.Address(net.IPv4(169, 254, 10, 1).To4()), 11211}
-	s.AddAddress(NICID, ipv4.ProtocolNumber, addr.Addr)
+	// The wall clock doesn't expire the timeout.
+	select {
+	case err := <-done:
+		t.Fatalf("got PeekFirstByte() = %v before the timeout", err)
+	case <-time.After(10 * time.Millisecond):
+	}
 
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	cancel()
+	clock.Advance(time.Hour)
+	err = <-done
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Errorf("got PeekFirstByte() = %v, want timeout error", err)
+	}
+}
 
-	if _, err := DialContextTCP(ctx, s, addr, ipv4.ProtocolNumber); err != context.Canceled {
-		t.Errorf("got DialContextTCP(...) = %v, want = %v", err, context.Canceled)
+func TestTCPConnAbort(t *testing.T) {
+	c1, c2, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	if err := c1.(*TCPConn).Abort(); err != nil {
+		t.Fatalf("got Abort() = %v, want = nil", err)
+	}
+
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = c2.Read(make([]byte, 1))
+	want := (&tcpip.ErrConnectionReset{}).String()
+	if oerr, ok := err.(*net.OpError); !ok || oerr.Err.Error() != want {
+		t.Errorf("got c2.Read() = %v, want = %s", err, want)
 	}
 }
 
-func TestDialContextTCPTimeout(t *testing.T) {
-	s, err := newLoopbackStack()
+func TestTCPConnCloseWithError(t *testing.T) {
+	c1, c2, stop, err := makePipe()
 	if err != nil {
-		t.Fatalf("newLoopbackStack() = %v", err)
+		t.Fatal(err)
+	}
+	defer stop()
+
+	tc := c1.(*TCPConn)
+	if err := tc.CloseWithError(fmt.Errorf("protocol violation")); err != nil {
+		t.Fatalf("got CloseWithError() = %v, want = nil", err)
+	}
+
+	var reason tcpip.TCPAbortReasonOption
+	if err := tc.ep.GetSockOpt(&reason); err != nil {
+		t.Fatalf("GetSockOpt(&%T): %s", reason, err)
+	}
+	if want := tcpip.TCPAbortReasonOption("protocol violation"); reason != want {
+		t.Errorf("got abort reason = %q, want = %q", reason, want)
+	}
+
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = c2.Read(make([]byte, 1))
+	want := (&tcpip.ErrConnectionReset{}).String()
+	if oerr, ok := err.(*net.OpError); !ok || oerr.Err.Error() != want {
+		t.Errorf("got c2.Read() = %v, want = %s", err, want)
+	}
+}
+
+func TestTCPConnAvailable(t *testing.T) {
+	c1, c2, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	tc := c2.(*TCPConn)
+	if n, err := tc.Available(); err != nil || n != 0 {
+		t.Errorf("got Available() = (%d, %v), want = (0, nil)", n, err)
+	}
+
+	const sent = "abc123"
+	if _, err := c1.Write([]byte(sent)); err != nil {
+		t.Fatalf("c1.Write(): %v", err)
+	}
+	if err := tc.PeekFirstByte(time.Second); err != nil {
+		t.Fatalf("PeekFirstByte(): %v", err)
+	}
+	if n, err := tc.Available(); err != nil || n != len(sent) {
+		t.Errorf("got Available() = (%d, %v), want = (%d, nil)", n, err, len(sent))
+	}
+
+	if _, err := io.ReadFull(c2, make([]byte, 2)); err != nil {
+		t.Fatalf("c2.Read(): %v", err)
+	}
+	if n, err := tc.Available(); err != nil || n != len(sent)-2 {
+		t.Errorf("got Available() = (%d, %v), want = (%d, nil)", n, err, len(sent)-2)
+	}
+}
+
+func TestUDPConnAvailable(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
 	}
 	defer func() {
 		s.Close()
 		s.Wait()
 	}()
 
-	addr := tcpip.FullAddress{NICID, tcpip.Address(net.IPv4(169, 254, 10, 1).To4()), 11211}
-	s.AddAddress(NICID, ipv4.ProtocolNumber, addr.Addr)
-
-	fwd := tcp.NewForwarder(s, 30000, 10, func(r *tcp.ForwarderRequest) {
-		time.Sleep(time.Second)
-		r.Complete(true)
-	})
-	s.SetTransportProtocolHandler(tcp.ProtocolNumber, fwd.HandlePacket)
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	addr1 := tcpip.FullAddress{NICID, ip, 11211}
+	addr2 := tcpip.FullAddress{NICID, ip, 11311}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
 
-	ctx := context.Background()
-	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(100*time.Millisecond))
-	defer cancel()
+	c1, err := DialUDP(s, &addr1, &addr2, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatal("DialUDP:", err)
+	}
+	defer c1.Close()
+	c2, err := DialUDP(s, &addr2, &addr1, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatal("DialUDP:", err)
+	}
+	defer c2.Close()
+
+	// waitAvailable waits until a datagram is queued on c2 and returns its
+	// size.
+	waitAvailable := func() int {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			n, err := c2.Available()
+			if err != nil {
+				t.Fatalf("Available(): %v", err)
+			}
+			if n > 0 {
+				return n
+			}
+		}
+		t.Fatalf("timed out waiting for a datagram")
+		return 0
+	}
 
-	if _, err := DialContextTCP(ctx, s, addr, ipv4.ProtocolNumber); err != context.DeadlineExceeded {
-		t.Errorf("got DialContextTCP(...) = %v, want = %v", err, context.DeadlineExceeded)
+	if n, err := c2.Available(); err != nil || n != 0 {
+		t.Errorf("got Available() = (%d, %v), want = (0, nil)", n, err)
 	}
-}
 
-func TestNetTest(t *testing.T) {
-	nettest.TestConn(t, makePipe)
+	datagrams := []string{"abc", "12345"}
+	for _, d := range datagrams {
+		if _, err := c1.Write([]byte(d)); err != nil {
+			t.Fatalf("c1.Write(%q): %v", d, err)
+		}
+	}
+	buf := make([]byte, 16)
+	for _, d := range datagrams {
+		if n := waitAvailable(); n != len(d) {
+			t.Errorf("got Available() = %d, want = %d", n, len(d))
+		}
+		if _, err := c2.Read(buf); err != nil {
+			t.Fatalf("c2.Read(): %v", err)
+		}
+	}
+}
+
+func TestUDPConnReadMsgUDPTruncated(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	addr1 := tcpip.FullAddress{NICID, ip, 11211}
+	addr2 := tcpip.FullAddress{NICID, ip, 11311}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+
+	c1, err := DialUDP(s, &addr1, &addr2, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatal("DialUDP:", err)
+	}
+	defer c1.Close()
+	c2, err := DialUDP(s, &addr2, &addr1, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatal("DialUDP:", err)
+	}
+	defer c2.Close()
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+
+	for _, tc := range []struct {
+		data      string
+		bufSize   int
+		want      string
+		wantFlags int
+	}{
+		{data: "abc", bufSize: 16, want: "abc"},
+		{data: "abc", bufSize: 3, want: "abc"},
+		{data: "12345", bufSize: 2, want: "12", wantFlags: MsgTrunc},
+	} {
+		if _, err := c1.Write([]byte(tc.data)); err != nil {
+			t.Fatalf("c1.Write(%q): %v", tc.data, err)
+		}
+		buf := make([]byte, tc.bufSize)
+		n, oobn, flags, addr, err := c2.ReadMsgUDP(buf, nil)
+		if err != nil {
+			t.Fatalf("c2.ReadMsgUDP(): %v", err)
+		}
+		if got := string(buf[:n]); got != tc.want || oobn != 0 || flags != tc.wantFlags {
+			t.Errorf("got ReadMsgUDP() = %q, %d, %#x, want = %q, 0, %#x", got, oobn, flags, tc.want, tc.wantFlags)
+		}
+		if want := fullToUDPAddr(addr1); !reflect.DeepEqual(addr, want) {
+			t.Errorf("got ReadMsgUDP() addr = %v, want = %v", addr, want)
+		}
+	}
+}
+
+func TestTCPConnKeepAlive(t *testing.T) {
+	c1, _, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	tc := c1.(*TCPConn)
+	if err := tc.SetKeepAlive(true); err != nil {
+		t.Fatalf("got SetKeepAlive(true) = %v, want = nil", err)
+	}
+	if !tc.ep.SocketOptions().GetKeepAlive() {
+		t.Errorf("got GetKeepAlive() = false, want = true")
+	}
+
+	const period = 42 * time.Second
+	if err := tc.SetKeepAlivePeriod(period); err != nil {
+		t.Fatalf("got SetKeepAlivePeriod(%v) = %v, want = nil", period, err)
+	}
+	var idle tcpip.KeepaliveIdleOption
+	if err := tc.ep.GetSockOpt(&idle); err != nil {
+		t.Fatalf("GetSockOpt(&%T): %s", idle, err)
+	}
+	if time.Duration(idle) != period {
+		t.Errorf("got keepalive idle = %v, want = %v", time.Duration(idle), period)
+	}
+	var interval tcpip.KeepaliveIntervalOption
+	if err := tc.ep.GetSockOpt(&interval); err != nil {
+		t.Fatalf("GetSockOpt(&%T): %s", interval, err)
+	}
+	if time.Duration(interval) != period {
+		t.Errorf("got keepalive interval = %v, want = %v", time.Duration(interval), period)
+	}
+
+	if err := tc.SetKeepAlivePeriod(0); err == nil {
+		t.Errorf("got SetKeepAlivePeriod(0) = nil, want error")
+	}
+
+	if err := tc.SetKeepAlive(false); err != nil {
+		t.Fatalf("got SetKeepAlive(false) = %v, want = nil", err)
+	}
+	if tc.ep.SocketOptions().GetKeepAlive() {
+		t.Errorf("got GetKeepAlive() = true, want = false")
+	}
+}
+
+func TestTCPConnNoDelay(t *testing.T) {
+	c1, _, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	tc := c1.(*TCPConn)
+	opts := tc.ep.SocketOptions()
+	if err := tc.SetNoDelay(false); err != nil {
+		t.Fatalf("got SetNoDelay(false) = %v, want = nil", err)
+	}
+	if tc.NoDelay() {
+		t.Errorf("got NoDelay() = true, want = false")
+	}
+	// Nagle's algorithm is enabled on the endpoint.
+	if !opts.GetDelayOption() {
+		t.Errorf("got endpoint GetDelayOption() = false after SetNoDelay(false), want = true")
+	}
+
+	if err := tc.SetNoDelay(true); err != nil {
+		t.Fatalf("got SetNoDelay(true) = %v, want = nil", err)
+	}
+	if !tc.NoDelay() {
+		t.Errorf("got NoDelay() = false, want = true")
+	}
+	// TCP_NODELAY is set on the endpoint.
+	if opts.GetDelayOption() {
+		t.Errorf("got endpoint GetDelayOption() = true after SetNoDelay(true), want = false")
+	}
+
+	if err := tc.SetQuickAck(false); err != nil {
+		t.Fatalf("got SetQuickAck(false) = %v, want = nil", err)
+	}
+	if tc.QuickAck() {
+		t.Errorf("got QuickAck() = true, want = false")
+	}
+	if opts.GetQuickAck() {
+		t.Errorf("got endpoint GetQuickAck() = true after SetQuickAck(false), want = false")
+	}
+}
+
+func TestTCPConnCongestionControl(t *testing.T) {
+	c1, _, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	tc := c1.(*TCPConn)
+	if err := tc.SetCongestionControl("reno"); err != nil {
+		t.Fatalf("got SetCongestionControl(reno) = %v, want = nil", err)
+	}
+	if got, err := tc.CongestionControl(); err != nil || got != "reno" {
+		t.Errorf("got CongestionControl() = (%q, %v), want = (reno, nil)", got, err)
+	}
+
+	if err := tc.SetCongestionControl("bogus"); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("got SetCongestionControl(bogus) = %v, want = not available error", err)
+	}
+	if got, err := tc.CongestionControl(); err != nil || got != "reno" {
+		t.Errorf("got CongestionControl() = (%q, %v), want = (reno, nil)", got, err)
+	}
+}
+
+// TestTCPConnReadAfterPeerClose checks that data written right before the
+// peer closes the connection can be read in full before io.EOF.
+func TestTCPConnReadAfterPeerClose(t *testing.T) {
+	for _, size := range []int{1, 64<<10 + 1, 4 << 20} {
+		t.Run(fmt.Sprintf("%d bytes", size), func(t *testing.T) {
+			c1, c2, stop, err := makePipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stop()
+
+			sent := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+			writeErr := make(chan error, 1)
+			go func() {
+				_, err := c1.Write(sent)
+				c1.Close()
+				writeErr <- err
+			}()
+
+			c2.SetReadDeadline(time.Now().Add(10 * time.Second))
+			recv, err := ioutil.ReadAll(c2)
+			if err != nil {
+				t.Fatalf("got ioutil.ReadAll(c2) = %v, want = nil", err)
+			}
+			if !bytes.Equal(recv, sent) {
+				t.Errorf("got len(recv) = %d, want = %d (or contents differ)", len(recv), len(sent))
+			}
+			if err := <-writeErr; err != nil {
+				t.Errorf("got c1.Write() = %v, want = nil", err)
+			}
+		})
+	}
+}
+
+func TestDialTCPFrom(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+
+	l, err := ListenTCP(s, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("NewListener: %v", err)
+	}
+	defer l.Close()
+
+	laddr := tcpip.FullAddress{NICID, ip, 12345}
+	c, err := DialTCPFrom(s, laddr, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialTCPFrom(%v, %v) = %v", laddr, addr, err)
+	}
+	defer c.Close()
+	if got, want := c.LocalAddr(), fullToTCPAddr(laddr); !reflect.DeepEqual(got, want) {
+		t.Errorf("got c.LocalAddr() = %v, want = %v", got, want)
+	}
+
+	// The local port is now in use.
+	if c, err := DialTCPFrom(s, laddr, addr, ipv4.ProtocolNumber); err == nil {
+		c.Close()
+		t.Errorf("got DialTCPFrom(%v, %v) = nil, want error", laddr, addr)
+	}
+
+	// An address that isn't assigned to the stack can't be used.
+	badAddr := tcpip.FullAddress{NICID, tcpip.Address(net.IPv4(169, 254, 10, 2).To4()), 12345}
+	if c, err := DialTCPFrom(s, badAddr, addr, ipv4.ProtocolNumber); err == nil {
+		c.Close()
+		t.Errorf("got DialTCPFrom(%v, %v) = nil, want error", badAddr, addr)
+	}
+}
+
+func TestTCPListenerSourceFilter(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	allowedIP := tcpip.Address(net.IPv4(169, 254, 20, 1).To4())
+	deniedIP := tcpip.Address(net.IPv4(169, 254, 20, 2).To4())
+	for _, a := range []tcpip.Address{ip, allowedIP, deniedIP} {
+		s.AddAddress(NICID, ipv4.ProtocolNumber, a)
+	}
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+
+	l, err := ListenTCP(s, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("NewListener: %v", err)
+	}
+	defer l.Close()
+
+	allow, err := tcpip.NewSubnet(tcpip.Address(net.IPv4(169, 254, 20, 0).To4()), tcpip.AddressMask(net.CIDRMask(24, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deny, err := tcpip.NewSubnet(deniedIP, tcpip.AddressMask(net.CIDRMask(32, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetSourceFilter(SourceFilter{
+		Allow: []tcpip.Subnet{allow},
+		Deny:  []tcpip.Subnet{deny},
+	}); err != nil {
+		t.Fatalf("SetSourceFilter: %v", err)
+	}
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	// Connections from outside Allow and from inside Deny are reset during
+	// the handshake.
+	for _, src := range []tcpip.Address{ip, deniedIP} {
+		if c, err := DialTCPFrom(s, tcpip.FullAddress{NICID, src, 0}, addr, ipv4.ProtocolNumber); err == nil {
+			c.Close()
+			t.Errorf("DialTCPFrom(%v) succeeded, want error", src)
+		}
+	}
+
+	c, err := DialTCPFrom(s, tcpip.FullAddress{NICID, allowedIP, 0}, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialTCPFrom(%v) = %v", allowedIP, err)
+	}
+	defer c.Close()
+	select {
+	case ac := <-accepted:
+		defer ac.Close()
+		if got, want := ac.RemoteAddr(), c.LocalAddr(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got accepted RemoteAddr() = %v, want = %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection from %v not accepted", allowedIP)
+	}
+}
+
+func TestListenTCPWithSourceFilter(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	deniedIP := tcpip.Address(net.IPv4(169, 254, 20, 2).To4())
+	for _, a := range []tcpip.Address{ip, deniedIP} {
+		s.AddAddress(NICID, ipv4.ProtocolNumber, a)
+	}
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+
+	deny, err := tcpip.NewSubnet(deniedIP, tcpip.AddressMask(net.CIDRMask(32, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ListenTCP(s, addr, ipv4.ProtocolNumber, WithSourceFilter(SourceFilter{
+		Deny: []tcpip.Subnet{deny},
+	}))
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close()
+
+	if c, err := DialTCPFrom(s, tcpip.FullAddress{NICID, deniedIP, 0}, addr, ipv4.ProtocolNumber); err == nil {
+		c.Close()
+		t.Fatalf("DialTCPFrom(%v) succeeded, want error", deniedIP)
+	}
+
+	c, err := DialTCPFrom(s, tcpip.FullAddress{NICID, ip, 0}, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialTCPFrom(%v) = %v", ip, err)
+	}
+	defer c.Close()
+	ac, err := l.Accept()
+	if err != nil {
+		t.Fatalf("l.Accept() = %v", err)
+	}
+	defer ac.Close()
+	if got, want := ac.RemoteAddr(), c.LocalAddr(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got accepted RemoteAddr() = %v, want = %v", got, want)
+	}
+}
+
+func TestTCPListenerSynFilter(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	allowedIP := tcpip.Address(net.IPv4(169, 254, 20, 1).To4())
+	deniedIP := tcpip.Address(net.IPv4(169, 254, 20, 2).To4())
+	for _, a := range []tcpip.Address{ip, allowedIP, deniedIP} {
+		s.AddAddress(NICID, ipv4.ProtocolNumber, a)
+	}
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+
+	l, err := ListenTCP(s, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("NewListener: %v", err)
+	}
+	defer l.Close()
+
+	var mu sync.Mutex
+	var seen []tcpip.Address
+	if err := l.SetSynFilter(func(peer tcpip.FullAddress) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, peer.Addr)
+		return peer.Addr != deniedIP
+	}); err != nil {
+		t.Fatalf("SetSynFilter: %v", err)
+	}
+
+	// The denied peer is reset during the handshake.
+	if c, err := DialTCPFrom(s, tcpip.FullAddress{NICID, deniedIP, 0}, addr, ipv4.ProtocolNumber); err == nil {
+		c.Close()
+		t.Fatalf("DialTCPFrom(%v) succeeded, want error", deniedIP)
+	}
+
+	c, err := DialTCPFrom(s, tcpip.FullAddress{NICID, allowedIP, 0}, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialTCPFrom(%v) = %v", allowedIP, err)
+	}
+	defer c.Close()
+	ac, err := l.Accept()
+	if err != nil {
+		t.Fatalf("l.Accept() = %v", err)
+	}
+	defer ac.Close()
+	if got, want := ac.RemoteAddr(), c.LocalAddr(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got accepted RemoteAddr() = %v, want = %v", got, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []tcpip.Address{deniedIP, allowedIP}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("filter saw peers %v, want %v", seen, want)
+	}
+}
+
+func TestListenConfig(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+
+	var controlled []string
+	lc := ListenConfig{
+		Stack:     s,
+		ReusePort: true,
+		Control: func(network, address string, ep tcpip.Endpoint) error {
+			controlled = append(controlled, network)
+			return nil
+		},
+	}
+	ctx := context.Background()
+
+	// Two listeners can share the port with ReusePort.
+	for i := 0; i < 2; i++ {
+		l, err := lc.Listen(ctx, "tcp4", "169.254.10.1:11211")
+		if err != nil {
+			t.Fatalf("lc.Listen() #%d = %v", i, err)
+		}
+		defer l.Close()
+		if got, want := l.Addr(), fullToTCPAddr(addr); !reflect.DeepEqual(got, want) {
+			t.Errorf("got l.Addr() = %v, want = %v", got, want)
+		}
+	}
+
+	c, err := DialTCP(s, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialTCP() = %v", err)
+	}
+	c.Close()
+
+	pc, err := lc.ListenPacket(ctx, "udp", "169.254.10.1:11211")
+	if err != nil {
+		t.Fatalf("lc.ListenPacket() = %v", err)
+	}
+	defer pc.Close()
+	if got, want := pc.LocalAddr(), fullToUDPAddr(addr); !reflect.DeepEqual(got, want) {
+		t.Errorf("got pc.LocalAddr() = %v, want = %v", got, want)
+	}
+
+	if want := []string{"tcp4", "tcp4", "udp"}; !reflect.DeepEqual(controlled, want) {
+		t.Errorf("got Control() calls for %v, want = %v", controlled, want)
+	}
+
+	for _, tc := range []struct {
+		network string
+		address string
+	}{
+		{"unix", "169.254.10.1:0"},
+		{"udp", "169.254.10.1:0"},
+		{"tcp", "localhost:0"},
+		{"tcp", "169.254.10.1"},
+	} {
+		if l, err := lc.Listen(ctx, tc.network, tc.address); err == nil {
+			l.Close()
+			t.Errorf("lc.Listen(%q, %q) succeeded, want error", tc.network, tc.address)
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if l, err := lc.Listen(canceled, "tcp", "169.254.10.1:0"); err != context.Canceled {
+		if err == nil {
+			l.Close()
+		}
+		t.Errorf("got lc.Listen() with canceled context = %v, want = %v", err, context.Canceled)
+	}
+}
+
+func TestTCPConnNegotiatedOptions(t *testing.T) {
+	for _, sack := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sack=%t", sack), func(t *testing.T) {
+			s, e := newLoopbackStack()
+			if e != nil {
+				t.Fatalf("newLoopbackStack() = %v", e)
+			}
+			defer func() {
+				s.Close()
+				s.Wait()
+			}()
+			opt := tcpip.TCPSACKEnabled(sack)
+			if e := s.SetTransportProtocolOption(tcp.ProtocolNumber, &opt); e != nil {
+				t.Fatalf("SetTransportProtocolOption(%T) = %v", opt, e)
+			}
+
+			ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+			addr := tcpip.FullAddress{NICID, ip, 11211}
+			s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+
+			l, err := ListenTCP(s, addr, ipv4.ProtocolNumber)
+			if err != nil {
+				t.Fatalf("NewListener: %v", err)
+			}
+			defer l.Close()
+			c, err := DialTCP(s, addr, ipv4.ProtocolNumber)
+			if err != nil {
+				t.Fatalf("DialTCP: %v", err)
+			}
+			defer c.Close()
+
+			got, err := c.SACKPermitted()
+			if err != nil || got != sack {
+				t.Errorf("got SACKPermitted() = %t, %v, want = %t, nil", got, err, sack)
+			}
+			// Both ends of the connection use the same stack, so they
+			// advertise the same window scale.
+			snd, rcv, ok, err := c.WindowScale()
+			if err != nil || !ok || snd != rcv {
+				t.Errorf("got WindowScale() = %d, %d, %t, %v, want equal scales, true, nil", snd, rcv, ok, err)
+			}
+		})
+	}
+}
+
+func TestDialerDeadline(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+	// Nothing answers this address, so connecting to it hangs.
+	addr := tcpip.FullAddress{NICID, tcpip.Address(net.IPv4(169, 254, 10, 2).To4()), 11211}
+
+	for _, tc := range []struct {
+		name   string
+		dialer Dialer
+		ctx    func() (context.Context, context.CancelFunc)
+	}{
+		{
+			name:   "timeout",
+			dialer: Dialer{Stack: s, Timeout: 100 * time.Millisecond},
+		},
+		{
+			name:   "deadline",
+			dialer: Dialer{Stack: s, Deadline: time.Now().Add(100 * time.Millisecond)},
+		},
+		{
+			name:   "timeout before context",
+			dialer: Dialer{Stack: s, Timeout: 100 * time.Millisecond},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Hour)
+			},
+		},
+		{
+			name:   "context before timeout",
+			dialer: Dialer{Stack: s, Timeout: time.Hour},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.ctx != nil {
+				var cancel context.CancelFunc
+				ctx, cancel = tc.ctx()
+				defer cancel()
+			}
+			start := time.Now()
+			c, err := tc.dialer.DialContextTCP(ctx, addr, ipv4.ProtocolNumber)
+			if err == nil {
+				c.Close()
+				t.Fatalf("got DialContextTCP() = nil, want = %v", context.DeadlineExceeded)
+			}
+			if err != context.DeadlineExceeded {
+				t.Errorf("got DialContextTCP() = %v, want = %v", err, context.DeadlineExceeded)
+			}
+			if elapsed := time.Since(start); elapsed > time.Minute {
+				t.Errorf("got DialContextTCP() after %v, want deadline to be enforced", elapsed)
+			}
+		})
+	}
+}
+
+func TestTCPListenerDrain(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+
+	l, err := ListenTCP(s, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("ListenTCP() = %v", err)
+	}
+	defer l.Close()
+
+	var accepted []net.Conn
+	for i := 0; i < 2; i++ {
+		c, err := DialTCP(s, addr, ipv4.ProtocolNumber)
+		if err != nil {
+			t.Fatalf("DialTCP() = %v", err)
+		}
+		defer c.Close()
+		sc, err := l.Accept()
+		if err != nil {
+			t.Fatalf("l.Accept() = %v", err)
+		}
+		defer sc.Close()
+		accepted = append(accepted, sc)
+	}
+
+	// Drain times out while connections are open.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got Drain() = %v, want = %v", err, context.DeadlineExceeded)
+	}
+	if c, err := l.Accept(); err == nil {
+		c.Close()
+		t.Errorf("l.Accept() after Drain() succeeded, want error")
+	}
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- l.Drain(context.Background())
+	}()
+	accepted[0].Close()
+	// Closing a connection twice must not be counted twice.
+	accepted[0].Close()
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain() = %v before all connections were closed", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	accepted[1].(*TCPConn).Abort()
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("got Drain() = %v, want = nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Drain() didn't return after all connections were closed")
+	}
+}
+
+func TestInitialReceiveWindow(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+	ctx := context.Background()
+
+	lc := ListenConfig{Stack: s, InitialReceiveWindow: 1000}
+	l, err := lc.Listen(ctx, "tcp4", "169.254.10.1:11211")
+	if err != nil {
+		t.Fatalf("lc.Listen() = %v", err)
+	}
+	defer l.Close()
+
+	d := Dialer{Stack: s, InitialReceiveWindow: 2000}
+	c, err := d.DialContextTCP(ctx, addr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("DialContextTCP() = %v", err)
+	}
+	defer c.Close()
+	sc, err := l.Accept()
+	if err != nil {
+		t.Fatalf("l.Accept() = %v", err)
+	}
+	defer sc.Close()
+
+	for _, tc := range []struct {
+		name string
+		c    *TCPConn
+		want tcpip.TCPInitialReceiveWindowOption
+	}{
+		{"dialed", c, 2000},
+		{"accepted", sc.(*TCPConn), 1000},
+	} {
+		var got tcpip.TCPInitialReceiveWindowOption
+		if err := tc.c.ep.GetSockOpt(&got); err != nil || got != tc.want {
+			t.Errorf("got %s GetSockOpt(%T) = %d, %v, want = %d, nil", tc.name, got, got, err, tc.want)
+		}
+	}
+
+	d.InitialReceiveWindow = -1
+	if c, err := d.DialContextTCP(ctx, addr, ipv4.ProtocolNumber); err == nil {
+		c.Close()
+		t.Errorf("DialContextTCP() with a negative window succeeded, want error")
+	}
+}
+
+func TestTCPConnWritev(t *testing.T) {
+	c1, c2, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	c1.SetDeadline(time.Now().Add(time.Second))
+	c2.SetDeadline(time.Now().Add(time.Second))
+
+	bufs := [][]byte{[]byte("header:"), nil, []byte("body"), []byte("!")}
+	const want = "header:body!"
+	n, err := c1.(*TCPConn).Writev(bufs)
+	if err != nil || n != len(want) {
+		t.Fatalf("got Writev() = %d, %v, want = %d, %v", n, err, len(want), nil)
+	}
+	if got := string(bufs[0]); got != "header:" {
+		t.Errorf("Writev modified its input: got bufs[0] = %q", got)
+	}
+
+	recv := make([]byte, len(want))
+	if _, err := io.ReadFull(c2, recv); err != nil {
+		t.Fatalf("io.ReadFull() = %v", err)
+	}
+	if got := string(recv); got != want {
+		t.Errorf("got recv = %q, want = %q", got, want)
+	}
+}
+
+func TestBuffersReader(t *testing.T) {
+	bufs := [][]byte{[]byte("ab"), nil, []byte("cde"), []byte("f")}
+	for skip := 0; skip <= 6; skip++ {
+		var r buffersReader
+		r.reset(bufs, skip)
+		if got, want := r.Len(), 6-skip; got != want {
+			t.Errorf("skip=%d: got Len() = %d, want = %d", skip, got, want)
+		}
+		got := make([]byte, 10)
+		n, _ := r.Read(got)
+		if want := "abcdef"[skip:]; string(got[:n]) != want {
+			t.Errorf("skip=%d: got Read() = %q, want = %q", skip, got[:n], want)
+		}
+		if r.Len() != 0 {
+			t.Errorf("skip=%d: got Len() = %d after full read, want = 0", skip, r.Len())
+		}
+	}
+}
+
+func TestTCPDialError(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	addr := tcpip.FullAddress{NICID, ip, 11211}
+
+	switch _, err := DialTCP(s, addr, ipv4.ProtocolNumber); err := err.(type) {
+	case *net.OpError:
+		if err.Err.Error() != (&tcpip.ErrNoRoute{}).String() {
+			t.Errorf("got DialTCP() = %s, want = %s", err, &tcpip.ErrNoRoute{})
+		}
+	default:
+		t.Errorf("got DialTCP(...) = %v, want %s", err, &tcpip.ErrNoRoute{})
+	}
+}
+
+func TestDialContextTCPCanceled(t *testing.T) {
+	s, err := newLoopbackStack()
+	if err != nil {
+		t.Fatalf("newLoopbackStack() = %v", err)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	addr := tcpip.FullAddress{NICID, tcpip.Address(net.IPv4(169, 254, 10, 1).To4()), 11211}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, addr.Addr)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err := DialContextTCP(ctx, s, addr, ipv4.ProtocolNumber); err != context.Canceled {
+		t.Errorf("got DialContextTCP(...) = %v, want = %v", err, context.Canceled)
+	}
+}
+
+func TestDialContextUDPCanceled(t *testing.T) {
+	s, err := newLoopbackStack()
+	if err != nil {
+		t.Fatalf("newLoopbackStack() = %v", err)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	addr := tcpip.FullAddress{NICID, tcpip.Address(net.IPv4(169, 254, 10, 1).To4()), 11211}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, addr.Addr)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err := DialContextUDP(ctx, s, nil, &addr, ipv4.ProtocolNumber); err != context.Canceled {
+		t.Errorf("got DialContextUDP(...) = %v, want = %v", err, context.Canceled)
+	}
+}
+
+func TestDialContextTCPTimeout(t *testing.T) {
+	s, err := newLoopbackStack()
+	if err != nil {
+		t.Fatalf("newLoopbackStack() = %v", err)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	addr := tcpip.FullAddress{NICID, tcpip.Address(net.IPv4(169, 254, 10, 1).To4()), 11211}
+	s.AddAddress(NICID, ipv4.ProtocolNumber, addr.Addr)
+
+	fwd := tcp.NewForwarder(s, 30000, 10, func(r *tcp.ForwarderRequest) {
+		time.Sleep(time.Second)
+		r.Complete(true)
+	})
+	s.SetTransportProtocolHandler(tcp.ProtocolNumber, fwd.HandlePacket)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(100*time.Millisecond))
+	defer cancel()
+
+	if _, err := DialContextTCP(ctx, s, addr, ipv4.ProtocolNumber); err != context.DeadlineExceeded {
+		t.Errorf("got DialContextTCP(...) = %v, want = %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestLinkLocalZone(t *testing.T) {
+	s, err := newLoopbackStack()
+	if err != nil {
+		t.Fatalf("newLoopbackStack() = %v", err)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.ParseIP("fe80::1"))
+	if err := s.AddAddress(NICID, ipv6.ProtocolNumber, ip); err != nil {
+		t.Fatalf("AddAddress(%d, %d, %s) = %s", NICID, ipv6.ProtocolNumber, ip, err)
+	}
+	wantZone := strconv.Itoa(NICID)
+
+	l, e := ListenTCP(s, tcpip.FullAddress{NIC: NICID, Addr: ip, Port: 11211}, ipv6.ProtocolNumber)
+	if e != nil {
+		t.Fatalf("ListenTCP(...) = %v", e)
+	}
+	defer l.Close()
+	if got := l.Addr().(*net.TCPAddr).Zone; got != wantZone {
+		t.Errorf("got l.Addr().Zone = %q, want = %q", got, wantZone)
+	}
+
+	c1, e := DialUDP(s, &tcpip.FullAddress{NIC: NICID, Addr: ip, Port: 11311}, nil, ipv6.ProtocolNumber)
+	if e != nil {
+		t.Fatalf("DialUDP(...) = %v", e)
+	}
+	defer c1.Close()
+	c2, e := DialUDP(s, &tcpip.FullAddress{NIC: NICID, Addr: ip, Port: 11411}, nil, ipv6.ProtocolNumber)
+	if e != nil {
+		t.Fatalf("DialUDP(...) = %v", e)
+	}
+	defer c2.Close()
+
+	// Re-resolve the address from its string form, as a user of the net
+	// package would, and use it to send a datagram.
+	laddr := c1.LocalAddr().String()
+	if want := "[fe80::1%" + wantZone + "]:11311"; laddr != want {
+		t.Fatalf("got c1.LocalAddr() = %s, want = %s", laddr, want)
+	}
+	raddr, e := net.ResolveUDPAddr("udp6", laddr)
+	if e != nil {
+		t.Fatalf("net.ResolveUDPAddr(%q) = %v", laddr, e)
+	}
+	want := []byte("hello")
+	if _, e := c2.WriteTo(want, raddr); e != nil {
+		t.Fatalf("c2.WriteTo(_, %s) = %v", raddr, e)
+	}
+
+	buf := make([]byte, len(want))
+	n, from, e := c1.ReadFrom(buf)
+	if e != nil {
+		t.Fatalf("c1.ReadFrom(_) = %v", e)
+	}
+	if got := buf[:n]; !bytes.Equal(got, want) {
+		t.Errorf("got c1.ReadFrom(_) = %q, want = %q", got, want)
+	}
+	if got := from.(*net.UDPAddr).Zone; got != wantZone {
+		t.Errorf("got sender zone = %q, want = %q", got, wantZone)
+	}
+}
+
+func TestNetTest(t *testing.T) {
+	nettest.TestConn(t, makePipe)
+}
+
+func TestTCPConnOriginalDestinationNotRedirected(t *testing.T) {
+	c1, _, stop, err := makePipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	if dst, err := c1.(*TCPConn).OriginalDestination(); err == nil {
+		t.Errorf("got OriginalDestination() = %+v, nil, want error for a connection that wasn't redirected", dst)
+	}
+}
+
+func TestDisableTimestamps(t *testing.T) {
+	s, e := newLoopbackStack()
+	if e != nil {
+		t.Fatalf("newLoopbackStack() = %v", e)
+	}
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	ip := tcpip.Address(net.IPv4(169, 254, 10, 1).To4())
+	s.AddAddress(NICID, ipv4.ProtocolNumber, ip)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name           string
+		port           uint16
+		listenDisabled bool
+		dialDisabled   bool
+		want           bool
+	}{
+		{"enabled", 11211, false, false, true},
+		{"listener disabled", 11212, true, false, false},
+		{"dialer disabled", 11213, false, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lc := ListenConfig{Stack: s, DisableTimestamps: tc.listenDisabled}
+			l, err := lc.Listen(ctx, "tcp4", fmt.Sprintf("169.254.10.1:%d", tc.port))
+			if err != nil {
+				t.Fatalf("lc.Listen() = %v", err)
+			}
+			defer l.Close()
+
+			d := Dialer{Stack: s, DisableTimestamps: tc.dialDisabled}
+			c, err := d.DialContextTCP(ctx, tcpip.FullAddress{NICID, ip, tc.port}, ipv4.ProtocolNumber)
+			if err != nil {
+				t.Fatalf("DialContextTCP() = %v", err)
+			}
+			defer c.Close()
+			sc, err := l.Accept()
+			if err != nil {
+				t.Fatalf("l.Accept() = %v", err)
+			}
+			defer sc.Close()
+
+			for name, conn := range map[string]*TCPConn{"dialed": c, "accepted": sc.(*TCPConn)} {
+				if got, err := conn.Timestamps(); err != nil || got != tc.want {
+					t.Errorf("got %s Timestamps() = %t, %v, want = %t, nil", name, got, err, tc.want)
+				}
+			}
+		})
+	}
 }
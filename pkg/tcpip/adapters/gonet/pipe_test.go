@@ -0,0 +1,94 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gonet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/loopback"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+)
+
+// pipeNICID is the ID of the loopback NIC created by udpPipe.
+const pipeNICID tcpip.NICID = 1
+
+// udpPipe creates a pair of UDPConns connected to each other over a new
+// loopback stack.
+//
+// stop closes both connections and releases the stack.
+func udpPipe() (c1, c2 *UDPConn, stop func(), err error) {
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{udp.NewProtocol},
+	})
+	release := func() {
+		s.Close()
+		s.Wait()
+	}
+	if err := s.CreateNIC(pipeNICID, loopback.New()); err != nil {
+		release()
+		return nil, nil, nil, fmt.Errorf("creating NIC: %s", err)
+	}
+	addr := tcpip.Address(net.IPv4(127, 0, 0, 1).To4())
+	if err := s.AddAddress(pipeNICID, ipv4.ProtocolNumber, addr); err != nil {
+		release()
+		return nil, nil, nil, fmt.Errorf("adding address: %s", err)
+	}
+	s.SetRouteTable([]tcpip.Route{{
+		Destination: header.IPv4EmptySubnet,
+		NIC:         pipeNICID,
+	}})
+
+	c1, err = DialUDP(s, &tcpip.FullAddress{NIC: pipeNICID, Addr: addr}, nil, ipv4.ProtocolNumber)
+	if err != nil {
+		release()
+		return nil, nil, nil, err
+	}
+	c1Addr, tcpipErr := c1.ep.GetLocalAddress()
+	if tcpipErr != nil {
+		c1.Close()
+		release()
+		return nil, nil, nil, c1.newOpError("getsockname", errors.New(tcpipErr.String()))
+	}
+	c2, err = DialUDP(s, nil, &c1Addr, ipv4.ProtocolNumber)
+	if err != nil {
+		c1.Close()
+		release()
+		return nil, nil, nil, err
+	}
+	c2Addr, tcpipErr := c2.ep.GetLocalAddress()
+	if tcpipErr == nil {
+		tcpipErr = c1.ep.Connect(c2Addr)
+	}
+	if tcpipErr != nil {
+		c1.Close()
+		c2.Close()
+		release()
+		return nil, nil, nil, c1.newOpError("connect", errors.New(tcpipErr.String()))
+	}
+
+	stop = func() {
+		c1.Close()
+		c2.Close()
+		release()
+	}
+	return c1, c2, stop, nil
+}
@@ -128,6 +128,10 @@ type endpoint struct {
 	// endpoint.
 	packetDispatchMode PacketDispatchMode
 
+	// busyPoll controls whether inbound dispatchers spin instead of blocking
+	// when no packets are available.
+	busyPoll bool
+
 	// gsoMaxSize is the maximum GSO packet size. It is zero if GSO is
 	// disabled.
 	gsoMaxSize uint32
@@ -192,6 +196,12 @@ type Options struct {
 	// used for this endpoint.
 	PacketDispatchMode PacketDispatchMode
 
+	// BusyPoll if true, indicates that inbound dispatchers should spin
+	// waiting for packets instead of blocking in poll(). This reduces
+	// receive latency, but keeps one CPU busy per FD even when the link is
+	// idle. It only applies to the Readv and RecvMMsg dispatch modes.
+	BusyPoll bool
+
 	// TXChecksumOffload if true, indicates that this endpoints capability
 	// set should include CapabilityTXChecksumOffload.
 	TXChecksumOffload bool
@@ -265,6 +275,7 @@ func New(opts *Options) (stack.LinkEndpoint, error) {
 		addr:                  opts.Address,
 		hdrSize:               hdrSize,
 		packetDispatchMode:    opts.PacketDispatchMode,
+		busyPoll:              opts.BusyPoll,
 		maxSyscallHeaderBytes: uintptr(opts.MaxSyscallHeaderBytes),
 		writevMaxIovs:         rawfile.MaxIovs,
 	}
@@ -135,7 +135,11 @@ func newReadVDispatcher(fd int, e *endpoint) (linkDispatcher, error) {
 
 // dispatch reads one packet from the file descriptor and dispatches it.
 func (d *readVDispatcher) dispatch() (bool, tcpip.Error) {
-	n, err := rawfile.BlockingReadv(d.fd, d.buf.nextIovecs())
+	readv := rawfile.BlockingReadv
+	if d.e.busyPoll {
+		readv = rawfile.BusyReadv
+	}
+	n, err := readv(d.fd, d.buf.nextIovecs())
 	if n == 0 || err != nil {
 		return false, err
 	}
@@ -234,7 +238,11 @@ func (d *recvMMsgDispatcher) dispatch() (bool, tcpip.Error) {
 		d.msgHdrs[k].Msg.SetIovlen(iovLen)
 	}
 
-	nMsgs, err := rawfile.BlockingRecvMMsg(d.fd, d.msgHdrs)
+	recvMMsg := rawfile.BlockingRecvMMsg
+	if d.e.busyPoll {
+		recvMMsg = rawfile.BusyRecvMMsg
+	}
+	nMsgs, err := recvMMsg(d.fd, d.msgHdrs)
 	if err != nil {
 		return false, err
 	}
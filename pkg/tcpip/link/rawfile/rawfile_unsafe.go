@@ -20,6 +20,7 @@ package rawfile
 
 import (
 	"reflect"
+	"runtime"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -212,3 +213,38 @@ func BlockingRecvMMsg(fd int, msgHdrs []MMsgHdr) (int, tcpip.Error) {
 		}
 	}
 }
+
+// BusyReadv is like BlockingReadv, but instead of blocking in poll() when no
+// data is available it yields the processor and retries. This avoids the
+// wakeup latency of poll() at the cost of keeping a CPU busy for as long as
+// the caller keeps reading.
+func BusyReadv(fd int, iovecs []unix.Iovec) (int, tcpip.Error) {
+	for {
+		n, _, e := unix.RawSyscall(unix.SYS_READV, uintptr(fd), uintptr(unsafe.Pointer(&iovecs[0])), uintptr(len(iovecs)))
+		switch e {
+		case 0:
+			return int(n), nil
+		case unix.EAGAIN, unix.EINTR:
+			runtime.Gosched()
+		default:
+			return 0, TranslateErrno(e)
+		}
+	}
+}
+
+// BusyRecvMMsg is like BlockingRecvMMsg, but instead of blocking in poll()
+// when no data is available it yields the processor and retries. See
+// BusyReadv.
+func BusyRecvMMsg(fd int, msgHdrs []MMsgHdr) (int, tcpip.Error) {
+	for {
+		n, _, e := unix.RawSyscall6(unix.SYS_RECVMMSG, uintptr(fd), uintptr(unsafe.Pointer(&msgHdrs[0])), uintptr(len(msgHdrs)), unix.MSG_DONTWAIT, 0, 0)
+		switch e {
+		case 0:
+			return int(n), nil
+		case unix.EAGAIN, unix.EINTR:
+			runtime.Gosched()
+		default:
+			return 0, TranslateErrno(e)
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/buffer"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
@@ -49,6 +50,81 @@ var LogPackets uint32 = 1
 // LogPacketsToPCAP must be accessed atomically.
 var LogPacketsToPCAP uint32 = 1
 
+// A capture is a capture started by StartCapture.
+type capture struct {
+	// snapLen is the maximum number of bytes of each packet written to w.
+	snapLen uint32
+
+	// mu serializes writes to w, and protects stopped.
+	mu sync.Mutex
+
+	// w receives the captured packets in PCAP format.
+	w io.Writer
+
+	// stopped is set once the capture is stopped, after which nothing is
+	// written to w.
+	stopped bool
+}
+
+// stop marks c as stopped. It waits for any write to c.w in progress.
+func (c *capture) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+}
+
+var (
+	// captureMu serializes StartCapture and StopCapture, and stopping a
+	// capture when writing to it fails.
+	captureMu sync.Mutex
+
+	// activeCapture holds the capture in progress as a *capture, which is
+	// nil if there's none. It's loaded for each packet without taking
+	// captureMu.
+	activeCapture atomic.Value
+)
+
+func init() {
+	// atomic.Value.Load returns nil until something is stored, and
+	// atomic.Value.Store(nil) panics, so store a typed nil.
+	activeCapture.Store((*capture)(nil))
+}
+
+// StartCapture starts writing the packets that traverse endpoints created with
+// New or NewWithPrefix to w in PCAP format, capturing at most snapLen bytes of
+// each packet. Unlike NewWithWriter, it can be used after the endpoints are
+// created. Any previous capture is stopped.
+func StartCapture(w io.Writer, snapLen uint32) error {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if err := writePCAPHeader(w, snapLen); err != nil {
+		return err
+	}
+	if old := activeCapture.Load().(*capture); old != nil {
+		old.stop()
+	}
+	activeCapture.Store(&capture{
+		snapLen: snapLen,
+		w:       w,
+	})
+	return nil
+}
+
+// StopCapture stops a capture started by StartCapture. It returns the writer
+// that was used, or nil if no capture was in progress. Nothing is written to
+// the writer once StopCapture returns.
+func StopCapture() io.Writer {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	c := activeCapture.Load().(*capture)
+	if c == nil {
+		return nil
+	}
+	activeCapture.Store((*capture)(nil))
+	c.stop()
+	return c.w
+}
+
 type endpoint struct {
 	nested.Endpoint
 	writer     io.Writer
@@ -150,37 +226,70 @@ func (e *endpoint) dumpPacket(dir direction, protocol tcpip.NetworkProtocolNumbe
 	if writer == nil && atomic.LoadUint32(&LogPackets) == 1 {
 		logPacket(e.logPrefix, dir, protocol, pkt)
 	}
+	if writer == nil {
+		if c := activeCapture.Load().(*capture); c != nil {
+			c.writePacket(pkt)
+		}
+	}
 	if writer != nil && atomic.LoadUint32(&LogPacketsToPCAP) == 1 {
-		totalLength := pkt.Size()
-		length := totalLength
-		if max := int(e.maxPCAPLen); length > max {
-			length = max
+		if _, err := writer.Write(pcapPacket(pkt, e.maxPCAPLen)); err != nil {
+			panic(err)
 		}
-		packetHeader := newPCAPPacketHeader(time.Now(), uint32(length), uint32(totalLength))
-		packet := make([]byte, binary.Size(packetHeader)+length)
-		{
-			writer := tcpip.SliceWriter(packet)
-			if err := binary.Write(&writer, binary.BigEndian, packetHeader); err != nil {
-				panic(err)
-			}
-			for _, b := range pkt.Views() {
-				if length == 0 {
-					break
-				}
-				if len(b) > length {
-					b = b[:length]
-				}
-				n, err := writer.Write(b)
-				if err != nil {
-					panic(err)
-				}
-				length -= n
-			}
+	}
+}
+
+// writePacket writes pkt to c, unless c is stopped. c is stopped if writing
+// fails.
+func (c *capture) writePacket(pkt *stack.PacketBuffer) {
+	record := pcapPacket(pkt, c.snapLen)
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return
+	}
+	_, err := c.w.Write(record)
+	if err != nil {
+		c.stopped = true
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		log.Warningf("Stopping packet capture, write failed: %v", err)
+		captureMu.Lock()
+		if activeCapture.Load().(*capture) == c {
+			activeCapture.Store((*capture)(nil))
+		}
+		captureMu.Unlock()
+	}
+}
+
+// pcapPacket returns pkt as a PCAP record, truncated to maxLen bytes.
+func pcapPacket(pkt *stack.PacketBuffer, maxLen uint32) []byte {
+	totalLength := pkt.Size()
+	length := totalLength
+	if max := int(maxLen); length > max {
+		length = max
+	}
+	packetHeader := newPCAPPacketHeader(time.Now(), uint32(length), uint32(totalLength))
+	packet := make([]byte, binary.Size(packetHeader)+length)
+	writer := tcpip.SliceWriter(packet)
+	if err := binary.Write(&writer, binary.BigEndian, packetHeader); err != nil {
+		panic(err)
+	}
+	for _, b := range pkt.Views() {
+		if length == 0 {
+			break
+		}
+		if len(b) > length {
+			b = b[:length]
 		}
-		if _, err := writer.Write(packet); err != nil {
+		n, err := writer.Write(b)
+		if err != nil {
 			panic(err)
 		}
+		length -= n
 	}
+	return packet
 }
 
 // WritePacket implements the stack.LinkEndpoint interface. It is called by
@@ -0,0 +1,139 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sniffer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/tcpip/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+// newTestEndpoint returns a sniffer endpoint that doesn't log packets.
+func newTestEndpoint(t *testing.T) stack.LinkEndpoint {
+	t.Helper()
+	old := atomic.SwapUint32(&LogPackets, 0)
+	t.Cleanup(func() {
+		atomic.StoreUint32(&LogPackets, old)
+	})
+	return New(channel.New(10, header.IPv4MinimumMTU, ""))
+}
+
+func writePacket(t *testing.T, ep stack.LinkEndpoint, payload []byte) {
+	t.Helper()
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Data: buffer.NewViewFromBytes(payload).ToVectorisedView(),
+	})
+	if err := ep.WritePacket(stack.RouteInfo{}, header.IPv4ProtocolNumber, pkt); err != nil {
+		t.Fatalf("WritePacket: %s", err)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	ep := newTestEndpoint(t)
+
+	// Packets aren't captured before the capture is started.
+	writePacket(t, ep, []byte{0, 0})
+
+	const snapLen = 4
+	var buf bytes.Buffer
+	if err := StartCapture(&buf, snapLen); err != nil {
+		t.Fatalf("StartCapture: %v", err)
+	}
+	writePacket(t, ep, []byte{1, 2})
+	writePacket(t, ep, []byte{3, 4, 5, 6, 7, 8})
+	if w := StopCapture(); w != &buf {
+		t.Errorf("StopCapture() = %v, want %v", w, &buf)
+	}
+
+	// Packets aren't captured after the capture is stopped.
+	writePacket(t, ep, []byte{9, 9})
+
+	var hdr pcapHeader
+	if err := binary.Read(&buf, binary.BigEndian, &hdr); err != nil {
+		t.Fatalf("reading PCAP header: %v", err)
+	}
+	if hdr.MagicNumber != 0xa1b2c3d4 || hdr.Snaplen != snapLen || hdr.Network != 101 {
+		t.Errorf("got PCAP header %+v, want magic number 0xa1b2c3d4, snaplen %d and network 101", hdr, snapLen)
+	}
+	for _, want := range [][]byte{
+		{1, 2},
+		{3, 4, 5, 6},
+	} {
+		var pktHdr pcapPacketHeader
+		if err := binary.Read(&buf, binary.BigEndian, &pktHdr); err != nil {
+			t.Fatalf("reading PCAP packet header: %v", err)
+		}
+		if int(pktHdr.IncludedLength) != len(want) {
+			t.Fatalf("got included length %d, want %d", pktHdr.IncludedLength, len(want))
+		}
+		got := make([]byte, pktHdr.IncludedLength)
+		if _, err := io.ReadFull(&buf, got); err != nil {
+			t.Fatalf("reading packet: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("got packet %v, want %v", got, want)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %d unexpected bytes after the captured packets", buf.Len())
+	}
+}
+
+func TestStopCaptureWithoutCapture(t *testing.T) {
+	if w := StopCapture(); w != nil {
+		t.Errorf("StopCapture() = %v, want nil", w)
+	}
+}
+
+// failingWriter fails all writes after the first n.
+type failingWriter struct {
+	n      int
+	writes int
+}
+
+// Write implements io.Writer.Write.
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if w.writes > w.n {
+		return 0, errors.New("write failed")
+	}
+	return len(b), nil
+}
+
+func TestCaptureStopsOnWriteError(t *testing.T) {
+	ep := newTestEndpoint(t)
+
+	// Allow writing the PCAP header only.
+	w := &failingWriter{n: 1}
+	if err := StartCapture(w, 65536); err != nil {
+		t.Fatalf("StartCapture: %v", err)
+	}
+	writePacket(t, ep, []byte{1, 2})
+	writePacket(t, ep, []byte{3, 4})
+	if got, want := w.writes, 2; got != want {
+		t.Errorf("got %d writes, want %d", got, want)
+	}
+	if w := StopCapture(); w != nil {
+		t.Errorf("StopCapture() after failed write = %v, want nil", w)
+	}
+}
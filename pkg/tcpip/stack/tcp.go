@@ -445,4 +445,8 @@ type TCPEndpointState struct {
 
 	// Sender holds state related to the TCP Sender for the endpoint.
 	Sender TCPSenderState
+
+	// AbortReason is the reason recorded with tcpip.TCPAbortReasonOption, if
+	// any.
+	AbortReason string
 }
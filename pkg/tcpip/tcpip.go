@@ -744,6 +744,10 @@ const (
 	//
 	// NOTE: This option is currently only stubed out and is a no-op
 	TCPWindowClampOption
+
+	// PathMTUOption is used by GetSockOptInt to get the maximum size of a
+	// network layer payload on the route used by a connected endpoint.
+	PathMTUOption
 )
 
 const (
@@ -945,6 +949,25 @@ type TCPInfoOption struct {
 
 	// ReorderSeen indicates if reordering is seen in the endpoint.
 	ReorderSeen bool
+
+	// SACKPermitted indicates if SACK was negotiated during the handshake.
+	SACKPermitted bool
+
+	// WindowScaling indicates if window scaling was negotiated during the
+	// handshake.
+	WindowScaling bool
+
+	// Timestamps indicates if the timestamp option was negotiated during the
+	// handshake.
+	Timestamps bool
+
+	// SndWndScale is the window scale applied to windows advertised by the
+	// peer. Zero if window scaling was not negotiated.
+	SndWndScale uint8
+
+	// RcvWndScale is the window scale applied to windows advertised to the
+	// peer. Zero if window scaling was not negotiated.
+	RcvWndScale uint8
 }
 
 func (*TCPInfoOption) isGettableSocketOption() {}
@@ -1023,6 +1046,63 @@ func (*TCPDeferAcceptOption) isGettableSocketOption() {}
 
 func (*TCPDeferAcceptOption) isSettableSocketOption() {}
 
+// TCPSynFilterOption is used by SetSockOpt to install a filter that a
+// listening TCP endpoint consults for each incoming SYN, before any state is
+// created for the connection. SYNs from peers for which Filter returns false
+// are answered with a RST. A nil Filter removes the filter.
+//
+// Filter is called with the endpoint locked and must not block or call back
+// into the endpoint.
+type TCPSynFilterOption struct {
+	Filter func(peer FullAddress) bool
+}
+
+func (*TCPSynFilterOption) isSettableSocketOption() {}
+
+// TCPInitialReceiveWindowOption is used by SetSockOpt/GetSockOpt to set the
+// receive window, in bytes, that a TCP endpoint advertises in its SYN or
+// SYN-ACK. By default, it's limited to twice the initial congestion window,
+// like on Linux. The window is still limited by the space in the receive
+// buffer and, since the window in SYN segments isn't scaled, by 65535 bytes.
+// Zero restores the default.
+//
+// It must be set before connecting. Endpoints accepted by a listening endpoint
+// inherit it.
+type TCPInitialReceiveWindowOption int
+
+func (*TCPInitialReceiveWindowOption) isGettableSocketOption() {}
+
+func (*TCPInitialReceiveWindowOption) isSettableSocketOption() {}
+
+// TCPTimestampsOption is used by SetSockOpt/GetSockOpt to enable or disable
+// the negotiation of the TCP timestamp option (RFC 7323) by an endpoint. It's
+// enabled by default. When disabled, the endpoint neither offers timestamps in
+// its SYN nor accepts them in a SYN-ACK or SYN, which saves 12 bytes per
+// segment.
+//
+// Without timestamps, the RTT is only sampled once per window, from
+// non-retransmitted segments, so the RTO adapts more slowly. PAWS is not
+// available either, so old duplicate segments can't be rejected once the
+// sequence numbers wrap, which matters on fast, long lived connections.
+//
+// It must be set before connecting. Endpoints accepted by a listening endpoint
+// inherit it.
+type TCPTimestampsOption bool
+
+func (*TCPTimestampsOption) isGettableSocketOption() {}
+
+func (*TCPTimestampsOption) isSettableSocketOption() {}
+
+// TCPAbortReasonOption is used by SetSockOpt/GetSockOpt to record why a TCP
+// connection is being aborted, for diagnostics. The reason is logged when the
+// connection is reset and is reported in the endpoint's state. It doesn't
+// change the segments sent to the peer.
+type TCPAbortReasonOption string
+
+func (*TCPAbortReasonOption) isGettableSocketOption() {}
+
+func (*TCPAbortReasonOption) isSettableSocketOption() {}
+
 // TCPMinRTOOption is use by SetSockOpt/GetSockOpt to allow overriding
 // default MinRTO used by the Stack.
 type TCPMinRTOOption time.Duration
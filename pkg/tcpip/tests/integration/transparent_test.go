@@ -0,0 +1,214 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transparent_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/pipe"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/tests/utils"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const (
+	clientNICID = 1
+	proxyNICID  = 2
+)
+
+// nonLocalAddr is an address that isn't assigned to either stack.
+var nonLocalAddr = tcpip.FullAddress{
+	Addr: tcpip.Address(net.ParseIP("10.0.0.1").To4()),
+	Port: 80,
+}
+
+// setupStacks returns a client stack and a proxy stack connected by a pipe.
+// Both route all traffic through the pipe. If transparent is true, the proxy
+// NIC is set up the way runsc sets up NICs with --net-transparent.
+func setupStacks(t *testing.T, transparent bool) (*stack.Stack, *stack.Stack) {
+	t.Helper()
+
+	opts := stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{tcp.NewProtocol},
+	}
+	clientStack := stack.New(opts)
+	proxyStack := stack.New(opts)
+
+	clientNIC, proxyNIC := pipe.New(utils.LinkAddr1, utils.LinkAddr2)
+	if err := clientStack.CreateNIC(clientNICID, clientNIC); err != nil {
+		t.Fatalf("clientStack.CreateNIC(%d, _): %s", clientNICID, err)
+	}
+	if err := proxyStack.CreateNIC(proxyNICID, proxyNIC); err != nil {
+		t.Fatalf("proxyStack.CreateNIC(%d, _): %s", proxyNICID, err)
+	}
+	if err := clientStack.AddProtocolAddress(clientNICID, utils.Ipv4Addr1); err != nil {
+		t.Fatalf("clientStack.AddProtocolAddress(%d, %#v): %s", clientNICID, utils.Ipv4Addr1, err)
+	}
+	if err := proxyStack.AddProtocolAddress(proxyNICID, utils.Ipv4Addr2); err != nil {
+		t.Fatalf("proxyStack.AddProtocolAddress(%d, %#v): %s", proxyNICID, utils.Ipv4Addr2, err)
+	}
+	clientStack.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: clientNICID}})
+	proxyStack.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: proxyNICID}})
+
+	if transparent {
+		if err := proxyStack.SetPromiscuousMode(proxyNICID, true); err != nil {
+			t.Fatalf("proxyStack.SetPromiscuousMode(%d, true): %s", proxyNICID, err)
+		}
+		if err := proxyStack.SetSpoofing(proxyNICID, true); err != nil {
+			t.Fatalf("proxyStack.SetSpoofing(%d, true): %s", proxyNICID, err)
+		}
+	}
+
+	t.Cleanup(func() {
+		clientStack.Close()
+		proxyStack.Close()
+		clientStack.Wait()
+		proxyStack.Wait()
+	})
+	return clientStack, proxyStack
+}
+
+// TestBindNonLocalWithoutTransparent checks that binding to an address that
+// isn't assigned to the stack fails on a regular NIC.
+func TestBindNonLocalWithoutTransparent(t *testing.T) {
+	_, proxyStack := setupStacks(t, false /* transparent */)
+
+	var wq waiter.Queue
+	ep, err := proxyStack.NewEndpoint(tcp.ProtocolNumber, ipv4.ProtocolNumber, &wq)
+	if err != nil {
+		t.Fatalf("proxyStack.NewEndpoint(%d, %d, _): %s", tcp.ProtocolNumber, ipv4.ProtocolNumber, err)
+	}
+	defer ep.Close()
+
+	if err := ep.Bind(nonLocalAddr); err == nil {
+		t.Fatalf("got ep.Bind(%#v) = nil, want = %s", nonLocalAddr, &tcpip.ErrBadLocalAddress{})
+	} else if _, ok := err.(*tcpip.ErrBadLocalAddress); !ok {
+		t.Fatalf("got ep.Bind(%#v) = %s, want = %s", nonLocalAddr, err, &tcpip.ErrBadLocalAddress{})
+	}
+}
+
+// TestTransparentAccept checks that with promiscuous mode and spoofing
+// enabled, as with --net-transparent, an endpoint can listen on an address
+// that isn't assigned to the stack, accept connections to it and reply from
+// it.
+func TestTransparentAccept(t *testing.T) {
+	clientStack, proxyStack := setupStacks(t, true /* transparent */)
+
+	var listenerWQ waiter.Queue
+	listenerEP, err := proxyStack.NewEndpoint(tcp.ProtocolNumber, ipv4.ProtocolNumber, &listenerWQ)
+	if err != nil {
+		t.Fatalf("proxyStack.NewEndpoint(%d, %d, _): %s", tcp.ProtocolNumber, ipv4.ProtocolNumber, err)
+	}
+	defer listenerEP.Close()
+	if err := listenerEP.Bind(nonLocalAddr); err != nil {
+		t.Fatalf("listenerEP.Bind(%#v): %s", nonLocalAddr, err)
+	}
+	if err := listenerEP.Listen(1); err != nil {
+		t.Fatalf("listenerEP.Listen(1): %s", err)
+	}
+	listenerWE, listenerCH := waiter.NewChannelEntry(nil)
+	listenerWQ.EventRegister(&listenerWE, waiter.ReadableEvents)
+	defer listenerWQ.EventUnregister(&listenerWE)
+
+	var clientWQ waiter.Queue
+	clientWE, clientCH := waiter.NewChannelEntry(nil)
+	clientWQ.EventRegister(&clientWE, waiter.WritableEvents|waiter.EventErr)
+	defer clientWQ.EventUnregister(&clientWE)
+	clientEP, err := clientStack.NewEndpoint(tcp.ProtocolNumber, ipv4.ProtocolNumber, &clientWQ)
+	if err != nil {
+		t.Fatalf("clientStack.NewEndpoint(%d, %d, _): %s", tcp.ProtocolNumber, ipv4.ProtocolNumber, err)
+	}
+	defer clientEP.Close()
+	{
+		err := clientEP.Connect(nonLocalAddr)
+		if _, ok := err.(*tcpip.ErrConnectStarted); !ok {
+			t.Fatalf("got clientEP.Connect(%#v) = %s, want = %s", nonLocalAddr, err, &tcpip.ErrConnectStarted{})
+		}
+	}
+	select {
+	case <-clientCH:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the connection to %#v", nonLocalAddr)
+	}
+	if err := clientEP.LastError(); err != nil {
+		t.Fatalf("clientEP.LastError(): %s", err)
+	}
+
+	var peer tcpip.FullAddress
+	acceptedEP, _, err := listenerEP.Accept(&peer)
+	if _, ok := err.(*tcpip.ErrWouldBlock); ok {
+		select {
+		case <-listenerCH:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting to accept a connection on %#v", nonLocalAddr)
+		}
+		acceptedEP, _, err = listenerEP.Accept(&peer)
+	}
+	if err != nil {
+		t.Fatalf("listenerEP.Accept(_): %s", err)
+	}
+	defer acceptedEP.Close()
+
+	// The local address of the accepted connection is the original
+	// destination.
+	if got, err := acceptedEP.GetLocalAddress(); err != nil {
+		t.Fatalf("acceptedEP.GetLocalAddress(): %s", err)
+	} else if got.Addr != nonLocalAddr.Addr || got.Port != nonLocalAddr.Port {
+		t.Errorf("got acceptedEP.GetLocalAddress() = %#v, want = %#v", got, nonLocalAddr)
+	}
+	if peer.Addr != utils.Ipv4Addr1.AddressWithPrefix.Address {
+		t.Errorf("got peer address = %s, want = %s", peer.Addr, utils.Ipv4Addr1.AddressWithPrefix.Address)
+	}
+
+	// Replies are sent from the non-local address.
+	clientWQ.EventUnregister(&clientWE)
+	readWE, readCH := waiter.NewChannelEntry(nil)
+	clientWQ.EventRegister(&readWE, waiter.ReadableEvents)
+	defer clientWQ.EventUnregister(&readWE)
+
+	data := []byte{1, 2, 3, 4}
+	var r bytes.Reader
+	r.Reset(data)
+	if n, err := acceptedEP.Write(&r, tcpip.WriteOptions{}); err != nil {
+		t.Fatalf("acceptedEP.Write(_, {}): %s", err)
+	} else if n != int64(len(data)) {
+		t.Fatalf("got acceptedEP.Write(_, {}) = %d, want = %d", n, len(data))
+	}
+
+	var buf bytes.Buffer
+	for buf.Len() < len(data) {
+		if _, err := clientEP.Read(&buf, tcpip.ReadOptions{}); err != nil {
+			if _, ok := err.(*tcpip.ErrWouldBlock); !ok {
+				t.Fatalf("clientEP.Read(_, {}): %s", err)
+			}
+			select {
+			case <-readCH:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for data from the proxy")
+			}
+		}
+	}
+	if got := buf.Bytes(); !bytes.Equal(got, data) {
+		t.Errorf("got clientEP.Read(...) = %v, want = %v", got, data)
+	}
+}
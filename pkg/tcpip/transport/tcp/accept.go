@@ -446,6 +446,8 @@ func (e *endpoint) propagateInheritableOptionsLocked(n *endpoint) {
 	n.boundBindToDevice = e.boundBindToDevice
 	n.boundPortFlags = e.boundPortFlags
 	n.userMSS = e.userMSS
+	n.initialRcvWnd = e.initialRcvWnd
+	n.noTimestamps = e.noTimestamps
 }
 
 // reserveTupleLocked reserves an accepted endpoint's tuple.
@@ -570,6 +572,10 @@ func (e *endpoint) handleListenSegment(ctx *listenContext, s *segment) tcpip.Err
 		return nil
 
 	case s.flags == header.TCPFlagSyn:
+		if e.synFilter != nil && !e.synFilter(tcpip.FullAddress{NIC: s.nicID, Addr: s.srcAddr, Port: s.id.RemotePort}) {
+			e.stack.Stats().DroppedPackets.Increment()
+			return replyWithReset(e.stack, s, e.sendTOS, e.ttl)
+		}
 		if e.acceptQueueIsFull() {
 			e.stack.Stats().TCP.ListenOverflowSynDrop.Increment()
 			e.stats.ReceiveErrors.ListenOverflowSynDrop.Increment()
@@ -578,6 +584,11 @@ func (e *endpoint) handleListenSegment(ctx *listenContext, s *segment) tcpip.Err
 		}
 
 		opts := parseSynSegmentOptions(s)
+		if e.noTimestamps {
+			// Don't echo the timestamp option in the SYN-ACK, and
+			// don't enable it on the new endpoint.
+			opts.TS = false
+		}
 		if !ctx.useSynCookies() {
 			s.incRef()
 			atomic.AddInt32(&e.synRcvdCount, 1)
@@ -681,7 +692,7 @@ func (e *endpoint) handleListenSegment(ctx *listenContext, s *segment) tcpip.Err
 		// if the ack specifies the timestamp option assuming
 		// that the other end did in fact negotiate the
 		// timestamp option in the original SYN.
-		if s.parsedOptions.TS {
+		if s.parsedOptions.TS && !e.noTimestamps {
 			rcvdSynOptions.TS = true
 			rcvdSynOptions.TSVal = s.parsedOptions.TSVal
 			rcvdSynOptions.TSEcr = s.parsedOptions.TSEcr
@@ -282,7 +282,7 @@ func (h *handshake) synSentState(s *segment) tcpip.Error {
 	h.ep.setEndpointState(StateSynRecv)
 	synOpts := header.TCPSynOptions{
 		WS:    int(h.effectiveRcvWndScale()),
-		TS:    rcvSynOpts.TS,
+		TS:    rcvSynOpts.TS && !h.ep.noTimestamps,
 		TSVal: h.ep.timestamp(),
 		TSEcr: h.ep.recentTimestamp(),
 
@@ -479,7 +479,7 @@ func (h *handshake) start() {
 
 	synOpts := header.TCPSynOptions{
 		WS:            h.rcvWndScale,
-		TS:            true,
+		TS:            !h.ep.noTimestamps,
 		TSVal:         h.ep.timestamp(),
 		TSEcr:         h.ep.recentTimestamp(),
 		SACKPermitted: bool(sackEnabled),
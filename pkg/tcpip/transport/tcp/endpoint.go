@@ -26,6 +26,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sleep"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/tcpip"
@@ -554,6 +555,23 @@ type endpoint struct {
 	// listener.
 	deferAccept time.Duration
 
+	// synFilter, if not nil, is consulted by a listening endpoint for each
+	// incoming SYN. SYNs for which it returns false are reset. See
+	// tcpip.TCPSynFilterOption.
+	synFilter func(peer tcpip.FullAddress) bool `state:"nosave"`
+
+	// abortReason is the reason recorded with tcpip.TCPAbortReasonOption.
+	abortReason string
+
+	// initialRcvWnd, if not zero, overrides the default limit of the receive
+	// window advertised in the SYN or SYN-ACK. See
+	// tcpip.TCPInitialReceiveWindowOption.
+	initialRcvWnd int
+
+	// noTimestamps disables the negotiation of the timestamp option. See
+	// tcpip.TCPTimestampsOption.
+	noTimestamps bool
+
 	// pendingAccepted tracks connections queued to be accepted. It is used to
 	// ensure such queued connections are terminated before the accepted queue is
 	// marked closed (by setting its capacity to zero).
@@ -1006,6 +1024,9 @@ func (e *endpoint) Close() {
 		s := e.EndpointState()
 		isResetState := s == StateEstablished || s == StateCloseWait || s == StateFinWait1 || s == StateFinWait2 || s == StateSynRecv
 		if isResetState {
+			if e.abortReason != "" {
+				log.Debugf("tcp: resetting connection %+v: %s", e.TransportEndpointInfo.ID, e.abortReason)
+			}
 			// Close the endpoint without doing full shutdown and
 			// send a RST.
 			e.resetConnectionLocked(&tcpip.ErrConnectionAborted{})
@@ -1165,6 +1186,9 @@ func (e *endpoint) initialReceiveWindow() int {
 
 	// Use the user supplied MSS, if available.
 	routeWnd := InitialCwnd * int(calculateAdvertisedMSS(e.userMSS, e.route)) * 2
+	if e.initialRcvWnd != 0 {
+		routeWnd = e.initialRcvWnd
+	}
 	if rcvWnd > routeWnd {
 		rcvWnd = routeWnd
 	}
@@ -1885,6 +1909,29 @@ func (e *endpoint) SetSockOpt(opt tcpip.SettableSocketOption) tcpip.Error {
 		e.deferAccept = time.Duration(*v)
 		e.UnlockUser()
 
+	case *tcpip.TCPSynFilterOption:
+		e.LockUser()
+		e.synFilter = v.Filter
+		e.UnlockUser()
+
+	case *tcpip.TCPAbortReasonOption:
+		e.LockUser()
+		e.abortReason = string(*v)
+		e.UnlockUser()
+
+	case *tcpip.TCPInitialReceiveWindowOption:
+		if *v < 0 {
+			return &tcpip.ErrInvalidOptionValue{}
+		}
+		e.LockUser()
+		e.initialRcvWnd = int(*v)
+		e.UnlockUser()
+
+	case *tcpip.TCPTimestampsOption:
+		e.LockUser()
+		e.noTimestamps = !bool(*v)
+		e.UnlockUser()
+
 	case *tcpip.SocketDetachFilterOption:
 		return nil
 
@@ -1932,11 +1979,16 @@ func (e *endpoint) GetSockOptInt(opt tcpip.SockOptInt) (int, tcpip.Error) {
 		return v, nil
 
 	case tcpip.MaxSegOption:
-		// This is just stubbed out. Linux never returns the user_mss
-		// value as it either returns the defaultMSS or returns the
-		// actual current MSS. Netstack just returns the defaultMSS
-		// always for now.
+		// Linux never returns the user_mss value as it either returns
+		// the defaultMSS or returns the actual current MSS. Netstack
+		// does the same: connected endpoints report the MSS in use by
+		// the sender and all others report the defaultMSS.
+		e.LockUser()
 		v := header.TCPDefaultMSS
+		if e.EndpointState().connected() && e.snd != nil {
+			v = e.snd.MaxPayloadSize
+		}
+		e.UnlockUser()
 		return v, nil
 
 	case tcpip.MTUDiscoverOption:
@@ -1968,6 +2020,14 @@ func (e *endpoint) GetSockOptInt(opt tcpip.SockOptInt) (int, tcpip.Error) {
 	case tcpip.MulticastTTLOption:
 		return 1, nil
 
+	case tcpip.PathMTUOption:
+		e.LockUser()
+		defer e.UnlockUser()
+		if !e.EndpointState().connected() || e.route == nil {
+			return -1, &tcpip.ErrNotConnected{}
+		}
+		return int(e.route.MTU()), nil
+
 	default:
 		return -1, &tcpip.ErrUnknownProtocolOption{}
 	}
@@ -1996,7 +2056,14 @@ func (e *endpoint) getTCPInfo() tcpip.TCPInfoOption {
 		info.SndSsthresh = uint32(snd.Ssthresh)
 		info.SndCwnd = uint32(snd.SndCwnd)
 		info.ReorderSeen = snd.rc.Reord
+		info.WindowScaling = snd.wndScaleOK
+		info.SndWndScale = snd.SndWndScale
+	}
+	if e.rcv != nil {
+		info.RcvWndScale = e.rcv.RcvWndScale
 	}
+	info.SACKPermitted = e.SACKPermitted
+	info.Timestamps = e.SendTSOk
 	e.UnlockUser()
 	return info
 }
@@ -2037,6 +2104,21 @@ func (e *endpoint) GetSockOpt(opt tcpip.GettableSocketOption) tcpip.Error {
 		*o = tcpip.TCPDeferAcceptOption(e.deferAccept)
 		e.UnlockUser()
 
+	case *tcpip.TCPAbortReasonOption:
+		e.LockUser()
+		*o = tcpip.TCPAbortReasonOption(e.abortReason)
+		e.UnlockUser()
+
+	case *tcpip.TCPInitialReceiveWindowOption:
+		e.LockUser()
+		*o = tcpip.TCPInitialReceiveWindowOption(e.initialRcvWnd)
+		e.UnlockUser()
+
+	case *tcpip.TCPTimestampsOption:
+		e.LockUser()
+		*o = tcpip.TCPTimestampsOption(!e.noTimestamps)
+		e.UnlockUser()
+
 	case *tcpip.OriginalDestinationOption:
 		e.LockUser()
 		ipt := e.stack.IPTables()
@@ -2873,10 +2955,11 @@ func (e *endpoint) updateRecentTimestamp(tsVal uint32, maxSentAck seqnum.Value,
 }
 
 // maybeEnableTimestamp marks the timestamp option enabled for this endpoint if
-// the SYN options indicate that timestamp option was negotiated. It also
+// the SYN options indicate that timestamp option was negotiated and the
+// endpoint doesn't have timestamps disabled. It also
 // initializes the recentTS with the value provided in synOpts.TSval.
 func (e *endpoint) maybeEnableTimestamp(synOpts *header.TCPSynOptions) {
-	if synOpts.TS {
+	if synOpts.TS && !e.noTimestamps {
 		e.SendTSOk = true
 		e.setRecentTimestamp(synOpts.TSVal)
 	}
@@ -2946,6 +3029,7 @@ func (e *endpoint) completeStateLocked() stack.TCPEndpointState {
 		SegTime:               e.stack.Clock().NowMonotonic(),
 		Receiver:              e.rcv.TCPReceiverState,
 		Sender:                e.snd.TCPSenderState,
+		AbortReason:           e.abortReason,
 	}
 
 	sndBufSize := e.getSendBufferSize()
@@ -126,6 +126,10 @@ type sender struct {
 	// gso is set if generic segmentation offload is enabled.
 	gso bool
 
+	// wndScaleOK is set if window scaling was negotiated during the
+	// handshake.
+	wndScaleOK bool
+
 	// state is the current state of congestion control for this endpoint.
 	state tcpip.CongestionControlState
 
@@ -193,6 +197,7 @@ func newSender(ep *endpoint, iss, irs seqnum.Value, sndWnd seqnum.Size, mss uint
 
 	// A negative sndWndScale means that no scaling is in use, otherwise we
 	// store the scaling value.
+	s.wndScaleOK = sndWndScale >= 0
 	if sndWndScale > 0 {
 		s.SndWndScale = uint8(sndWndScale)
 	}
@@ -0,0 +1,195 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/fd"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/fs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/urpc"
+)
+
+// AttachArgs contains arguments to the Attach method.
+type AttachArgs struct {
+	// CID is the ID of the container to attach to.
+	CID string
+
+	// FilePayload contains the stdin, stdout and stderr files to attach, in
+	// that order.
+	urpc.FilePayload
+}
+
+// Attach replaces the stdio of a container's init process with the given
+// files. The previous stdio is restored by Detach.
+func (cm *containerManager) Attach(args *AttachArgs, _ *struct{}) error {
+	log.Debugf("containerManager.Attach, cid: %s", args.CID)
+	if len(args.Files) != 3 {
+		return fmt.Errorf("attach requires exactly 3 files (stdin, stdout, and stderr), got %d", len(args.Files))
+	}
+	stdios, err := fd.NewFromFiles(args.Files)
+	if err != nil {
+		return fmt.Errorf("error dup'ing stdio files: %w", err)
+	}
+	defer func() {
+		for _, fd := range stdios {
+			_ = fd.Close()
+		}
+	}()
+	return cm.l.attachStdio(args.CID, stdios)
+}
+
+// Detach restores the stdio of a container's init process that was replaced
+// by Attach.
+func (cm *containerManager) Detach(cid *string, _ *struct{}) error {
+	log.Debugf("containerManager.Detach, cid: %s", *cid)
+	return cm.l.detachStdio(*cid)
+}
+
+// attachedStdio holds the stdio files of a process that were replaced by
+// attachStdio. A nil entry means that the FD was not open.
+type attachedStdio struct {
+	files     []*fs.File
+	filesVFS2 []*vfs.FileDescription
+}
+
+// initFDTableLocked returns the FD table of the init process of container
+// 'cid', with a reference taken.
+//
+// Precondition: l.mu must be locked.
+func (l *Loader) initFDTableLocked(cid string) (*execProcess, *kernel.FDTable, error) {
+	ep := l.processes[execID{cid: cid}]
+	if ep == nil || ep.tg == nil {
+		return nil, nil, fmt.Errorf("container %q not started", cid)
+	}
+	leader := ep.tg.Leader()
+	if leader == nil {
+		return nil, nil, fmt.Errorf("container %q has stopped", cid)
+	}
+	var fdTable *kernel.FDTable
+	leader.WithMuLocked(func(t *kernel.Task) {
+		if fdTable = t.FDTable(); fdTable != nil {
+			fdTable.IncRef()
+		}
+	})
+	if fdTable == nil {
+		return nil, nil, fmt.Errorf("container %q has stopped", cid)
+	}
+	return ep, fdTable, nil
+}
+
+// attachStdio replaces FDs 0, 1 and 2 of the init process of container 'cid'
+// with 'stdioFDs'. Only the init process is affected; processes that already
+// inherited its stdio keep using the previous files.
+func (l *Loader) attachStdio(cid string, stdioFDs []*fd.FD) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ep, fdTable, err := l.initFDTableLocked(cid)
+	if err != nil {
+		return err
+	}
+	ctx := l.k.SupervisorContext()
+	defer fdTable.DecRef(ctx)
+
+	if ep.tty != nil || ep.ttyVFS2 != nil {
+		return fmt.Errorf("container %q has a terminal, attach is not supported", cid)
+	}
+	if ep.attached != nil {
+		return fmt.Errorf("container %q is already attached", cid)
+	}
+
+	// Import the host files into a temporary table, then move them into the
+	// process' table.
+	newTable, _, _, err := createFDTable(ctx, false /* console */, stdioFDs, specs.User{})
+	if err != nil {
+		return fmt.Errorf("importing stdio files: %w", err)
+	}
+	defer newTable.DecRef(ctx)
+
+	saved := &attachedStdio{}
+	ep.attached = saved
+	for appFD := int32(0); appFD < 3; appFD++ {
+		if kernel.VFS2Enabled {
+			old, _ := fdTable.GetVFS2(appFD)
+			saved.filesVFS2 = append(saved.filesVFS2, old)
+			file, _ := newTable.GetVFS2(appFD)
+			err = fdTable.NewFDAtVFS2(ctx, appFD, file, kernel.FDFlags{})
+			file.DecRef(ctx)
+		} else {
+			old, _ := fdTable.Get(appFD)
+			saved.files = append(saved.files, old)
+			file, _ := newTable.Get(appFD)
+			err = fdTable.NewFDAt(ctx, appFD, file, kernel.FDFlags{})
+			file.DecRef(ctx)
+		}
+		if err != nil {
+			// The files replaced so far are restored by detachStdio.
+			return fmt.Errorf("replacing FD %d: %w", appFD, err)
+		}
+	}
+	return nil
+}
+
+// detachStdio restores the stdio files replaced by attachStdio.
+func (l *Loader) detachStdio(cid string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ep, fdTable, err := l.initFDTableLocked(cid)
+	if err != nil {
+		return err
+	}
+	ctx := l.k.SupervisorContext()
+	defer fdTable.DecRef(ctx)
+
+	saved := ep.attached
+	if saved == nil {
+		return fmt.Errorf("container %q is not attached", cid)
+	}
+	ep.attached = nil
+
+	for i, old := range saved.filesVFS2 {
+		appFD := int32(i)
+		if old == nil {
+			if _, file := fdTable.Remove(ctx, appFD); file != nil {
+				file.DecRef(ctx)
+			}
+			continue
+		}
+		if err := fdTable.NewFDAtVFS2(ctx, appFD, old, kernel.FDFlags{}); err != nil {
+			log.Warningf("Failed to restore FD %d of container %q: %v", appFD, cid, err)
+		}
+		old.DecRef(ctx)
+	}
+	for i, old := range saved.files {
+		appFD := int32(i)
+		if old == nil {
+			if file, _ := fdTable.Remove(ctx, appFD); file != nil {
+				file.DecRef(ctx)
+			}
+			continue
+		}
+		if err := fdTable.NewFDAt(ctx, appFD, old, kernel.FDFlags{}); err != nil {
+			log.Warningf("Failed to restore FD %d of container %q: %v", appFD, cid, err)
+		}
+		old.DecRef(ctx)
+	}
+	return nil
+}
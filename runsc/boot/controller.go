@@ -15,9 +15,13 @@
 package boot
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	gtime "time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -27,12 +31,15 @@ import (
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/control"
 	"gvisor.dev/gvisor/pkg/sentry/fs"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/devpts"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/socket/netstack"
 	"gvisor.dev/gvisor/pkg/sentry/state"
 	"gvisor.dev/gvisor/pkg/sentry/time"
+	"gvisor.dev/gvisor/pkg/sentry/usage"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/sentry/watchdog"
+	"gvisor.dev/gvisor/pkg/state/statefile"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
 	"gvisor.dev/gvisor/pkg/urpc"
 	"gvisor.dev/gvisor/runsc/boot/pprof"
@@ -41,6 +48,13 @@ import (
 )
 
 const (
+	// ContMgrAttach replaces the stdio of a container's init process.
+	ContMgrAttach = "containerManager.Attach"
+
+	// ContMgrBootTimings gets the phases of the sandbox startup recorded by
+	// the sentry.
+	ContMgrBootTimings = "containerManager.BootTimings"
+
 	// ContMgrCheckpoint checkpoints a container.
 	ContMgrCheckpoint = "containerManager.Checkpoint"
 
@@ -51,19 +65,40 @@ const (
 	// associated resources in the sandbox.
 	ContMgrDestroySubcontainer = "containerManager.DestroySubcontainer"
 
+	// ContMgrDetach restores the stdio replaced by ContMgrAttach.
+	ContMgrDetach = "containerManager.Detach"
+
 	// ContMgrEvent gets stats about the container used by "runsc events".
 	ContMgrEvent = "containerManager.Event"
 
 	// ContMgrExecuteAsync executes a command in a container.
 	ContMgrExecuteAsync = "containerManager.ExecuteAsync"
 
+	// ContMgrIPTables gets a table of the sandbox network stack's iptables
+	// rules.
+	ContMgrIPTables = "containerManager.IPTables"
+
+	// ContMgrNetworkStats gets per-NIC network statistics from the sandbox.
+	ContMgrNetworkStats = "containerManager.NetworkStats"
+
 	// ContMgrPause pauses the sandbox (note that individual containers cannot be
 	// paused).
 	ContMgrPause = "containerManager.Pause"
 
+	// ContMgrPlatformCapabilities gets the features of the sandbox's
+	// platform.
+	ContMgrPlatformCapabilities = "containerManager.PlatformCapabilities"
+
 	// ContMgrProcesses lists processes running in a container.
 	ContMgrProcesses = "containerManager.Processes"
 
+	// ContMgrReloadSeccomp installs a new OCI seccomp profile in a running
+	// container.
+	ContMgrReloadSeccomp = "containerManager.ReloadSeccomp"
+
+	// ContMgrRename changes the ID of a container.
+	ContMgrRename = "containerManager.Rename"
+
 	// ContMgrRestore restores a container from a statefile.
 	ContMgrRestore = "containerManager.Restore"
 
@@ -71,16 +106,32 @@ const (
 	// cannot be resumed).
 	ContMgrResume = "containerManager.Resume"
 
+	// ContMgrSetIPTables replaces a table of the sandbox network stack's
+	// iptables rules.
+	ContMgrSetIPTables = "containerManager.SetIPTables"
+
 	// ContMgrSignal sends a signal to a container.
 	ContMgrSignal = "containerManager.Signal"
 
 	// ContMgrStartSubcontainer starts a sub-container inside a running sandbox.
 	ContMgrStartSubcontainer = "containerManager.StartSubcontainer"
 
+	// ContMgrTerminals lists the terminals that have open file descriptions
+	// in the sentry.
+	ContMgrTerminals = "containerManager.Terminals"
+
+	// ContMgrUpdateResources applies updated sandbox resource limits to the
+	// sentry.
+	ContMgrUpdateResources = "containerManager.UpdateResources"
+
 	// ContMgrWait waits on the init process of the container and returns its
 	// ExitStatus.
 	ContMgrWait = "containerManager.Wait"
 
+	// ContMgrWaitWithUsage waits on the init process of the container and
+	// returns its ExitStatus and resource usage.
+	ContMgrWaitWithUsage = "containerManager.WaitWithUsage"
+
 	// ContMgrWaitPID waits on a process with a certain PID in the sandbox and
 	// return its ExitStatus.
 	ContMgrWaitPID = "containerManager.WaitPID"
@@ -95,6 +146,15 @@ const (
 
 	// DebugStacks collects sandbox stacks for debugging.
 	DebugStacks = "debug.Stacks"
+
+	// DebugGoroutineDump returns the stacks of all sentry goroutines.
+	DebugGoroutineDump = "debug.GoroutineDump"
+
+	// DebugDevices lists devices registered in the sandbox for debugging.
+	DebugDevices = "debug.Devices"
+
+	// DebugSetPacketLogging starts or stops packet logging at runtime.
+	DebugSetPacketLogging = "debug.SetPacketLogging"
 )
 
 // Profiling related commands (see pprof.go for more details).
@@ -145,12 +205,13 @@ func newController(fd int, l *Loader) (*controller, error) {
 
 	if eps, ok := l.k.RootNetworkNamespace().Stack().(*netstack.Stack); ok {
 		net := &Network{
-			Stack: eps.Stack,
+			Stack:   eps.Stack,
+			timings: &l.timings,
 		}
 		ctrl.srv.Register(net)
 	}
 
-	ctrl.srv.Register(&debug{})
+	ctrl.srv.Register(&debug{k: l.k})
 	ctrl.srv.Register(&control.Logging{})
 
 	if l.root.conf.ProfileEnable {
@@ -333,6 +394,14 @@ func (cm *containerManager) Checkpoint(o *control.SaveOpts, _ *struct{}) error {
 		return errors.New("checkpoint not supported when using hostinet")
 	}
 
+	// Record which container the checkpoint was taken from, so that restore
+	// can verify that it's compatible with the container being restored.
+	if o.Metadata == nil {
+		o.Metadata = make(map[string]string)
+	}
+	o.Metadata[metadataContainerID] = cm.l.sandboxID
+	o.Metadata[metadataContainerMounts] = mountsFingerprint(cm.l.root.spec)
+
 	state := control.State{
 		Kernel:   cm.l.k,
 		Watchdog: cm.l.watchdog,
@@ -340,6 +409,73 @@ func (cm *containerManager) Checkpoint(o *control.SaveOpts, _ *struct{}) error {
 	return state.Save(o, nil)
 }
 
+// Checkpoint metadata keys describing the checkpointed container.
+const (
+	metadataContainerID     = "container_id"
+	metadataContainerMounts = "container_mounts"
+)
+
+// mountsFingerprint returns a description of the mounts in spec. The mount
+// points must be the same when restoring, since file descriptors and the
+// mount tree are saved in the checkpoint.
+func mountsFingerprint(spec *specs.Spec) string {
+	var mounts []string
+	for _, m := range spec.Mounts {
+		specutils.MaybeConvertToBindMount(&m)
+		mounts = append(mounts, fmt.Sprintf("%s(%s)", filepath.Clean(m.Destination), m.Type))
+	}
+	return strings.Join(mounts, ",")
+}
+
+// checkRestoreMetadata verifies that the checkpoint in stateFile can be
+// restored into the container described by o and spec. Checkpoints taken
+// before the metadata was recorded are not checked.
+//
+// It returns the reader to load the checkpoint from, which starts at the
+// beginning of the state file. stateFile may be a pipe, in which case the
+// bytes read to check the metadata are replayed by the reader.
+func checkRestoreMetadata(stateFile *os.File, o *RestoreOpts, spec *specs.Spec) (io.Reader, error) {
+	info, err := stateFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		source   io.Reader = stateFile
+		metadata map[string]string
+	)
+	if info.Mode().IsRegular() {
+		if info.Size() == 0 {
+			return nil, fmt.Errorf("file cannot be empty")
+		}
+		metadata, err = statefile.MetadataUnsafe(stateFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading state file metadata: %v", err)
+		}
+		// Rewind the state file for loading. Concurrent restores of the same
+		// image open the file separately and don't share the offset.
+		if _, err := stateFile.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("rewinding state file: %v", err)
+		}
+	} else {
+		var header bytes.Buffer
+		metadata, err = statefile.MetadataUnsafe(io.TeeReader(stateFile, &header))
+		if err != nil {
+			return nil, fmt.Errorf("reading state file metadata: %v", err)
+		}
+		source = io.MultiReader(&header, stateFile)
+	}
+
+	if cid, ok := metadata[metadataContainerID]; ok && cid != o.SandboxID && !o.RemapContainerID {
+		return nil, fmt.Errorf("checkpoint was taken from container %q, can't restore it as %q without remapping the container ID", cid, o.SandboxID)
+	}
+	if want, ok := metadata[metadataContainerMounts]; ok {
+		if got := mountsFingerprint(spec); got != want {
+			return nil, fmt.Errorf("spec mounts [%s] don't match the checkpointed container's mounts [%s]", got, want)
+		}
+	}
+	return source, nil
+}
+
 // Pause suspends a sandbox.
 func (cm *containerManager) Pause(_, _ *struct{}) error {
 	log.Debugf("containerManager.Pause")
@@ -359,6 +495,11 @@ type RestoreOpts struct {
 
 	// SandboxID contains the ID of the sandbox.
 	SandboxID string
+
+	// RemapContainerID allows the checkpoint to be restored when it was taken
+	// from a container with a different ID. The restored tasks are moved to
+	// the SandboxID container.
+	RemapContainerID bool
 }
 
 // Restore loads a container from a statefile.
@@ -387,6 +528,11 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 		return fmt.Errorf("at most two files may be passed to Restore")
 	}
 
+	source, err := checkRestoreMetadata(specFile, o, cm.l.root.spec)
+	if err != nil {
+		return err
+	}
+
 	// Pause the kernel while we build a new one.
 	cm.l.k.Pause()
 
@@ -397,7 +543,7 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	k := &kernel.Kernel{
 		Platform: p,
 	}
-	mf, err := createMemoryFile()
+	mf, err := createMemoryFile(cm.l.root.conf)
 	if err != nil {
 		return fmt.Errorf("creating memory file: %v", err)
 	}
@@ -425,14 +571,6 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	if eps, ok := networkStack.(*netstack.Stack); ok {
 		stack.StackFromEnv = eps.Stack // FIXME(b/36201077)
 	}
-	info, err := specFile.Stat()
-	if err != nil {
-		return err
-	}
-	if info.Size() == 0 {
-		return fmt.Errorf("file cannot be empty")
-	}
-
 	if cm.l.root.conf.ProfileEnable {
 		// pprof.Initialize opens /proc/self/maps, so has to be called before
 		// installing seccomp filters.
@@ -445,11 +583,35 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	}
 
 	// Load the state.
-	loadOpts := state.LoadOpts{Source: specFile}
+	loadOpts := state.LoadOpts{Source: source}
 	if err := loadOpts.Load(ctx, k, nil, networkStack, time.NewCalibratedClocks(), &vfs.CompleteRestoreOptions{}); err != nil {
 		return err
 	}
 
+	// The checkpoint may have been taken from a container with a different ID,
+	// e.g. when cloning a container from a checkpoint. Move the restored tasks
+	// to the new container so that they can be found by its ID.
+	if init := k.GlobalInit(); init != nil {
+		if oldCID := init.Leader().ContainerID(); oldCID != o.SandboxID {
+			if !o.RemapContainerID {
+				return fmt.Errorf("checkpoint was taken from container %q, can't restore it as %q without remapping the container ID", oldCID, o.SandboxID)
+			}
+			log.Infof("Restoring container %q as %q", oldCID, o.SandboxID)
+			k.RenameContainer(oldCID, o.SandboxID)
+		}
+	}
+
+	// Apply the settings that the spec overrides, e.g. when restoring on a
+	// different host.
+	uts := k.RootUTSNamespace()
+	if hostname := cm.l.root.spec.Hostname; cm.l.root.conf.IsRestoreEnvOverridden(config.RestoreOverrideHostname) {
+		log.Infof("Overriding restored hostname %q with %q", uts.HostName(), hostname)
+		uts.SetHostName(hostname)
+		uts.SetDomainName(hostname)
+	} else if hostname != uts.HostName() {
+		log.Warningf("Keeping restored hostname %q instead of %q from the spec, see --restore-env-override", uts.HostName(), hostname)
+	}
+
 	// Since we have a new kernel we also must make a new watchdog.
 	dogOpts := watchdog.DefaultOpts
 	dogOpts.TaskTimeoutAction = cm.l.root.conf.WatchdogAction
@@ -482,6 +644,22 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	return nil
 }
 
+// RenameArgs contains arguments to the Rename method.
+type RenameArgs struct {
+	// CID is the current ID of the container.
+	CID string
+
+	// NewCID is the ID the container is renamed to.
+	NewCID string
+}
+
+// Rename changes the ID of a sub-container, for both new and already running
+// processes.
+func (cm *containerManager) Rename(args *RenameArgs, _ *struct{}) error {
+	log.Debugf("containerManager.Rename, cid: %s, new cid: %s", args.CID, args.NewCID)
+	return cm.l.renameContainer(args.CID, args.NewCID)
+}
+
 // Resume unpauses a sandbox.
 func (cm *containerManager) Resume(_, _ *struct{}) error {
 	log.Debugf("containerManager.Resume")
@@ -492,11 +670,30 @@ func (cm *containerManager) Resume(_, _ *struct{}) error {
 // Wait waits for the init process in the given container.
 func (cm *containerManager) Wait(cid *string, waitStatus *uint32) error {
 	log.Debugf("containerManager.Wait, cid: %s", *cid)
-	err := cm.l.waitContainer(*cid, waitStatus)
+	err := cm.l.waitContainer(*cid, waitStatus, nil)
 	log.Debugf("containerManager.Wait returned, cid: %s, waitStatus: %#x, err: %v", *cid, *waitStatus, err)
 	return err
 }
 
+// WaitWithUsageResult is the result of the WaitWithUsage method.
+type WaitWithUsageResult struct {
+	// WaitStatus is the exit status of the container's init process.
+	WaitStatus uint32
+
+	// Rusage is the resource usage of the init process and its waited-for
+	// children.
+	Rusage unix.Rusage
+}
+
+// WaitWithUsage waits for the init process in a container to exit, and
+// returns its exit status and resource usage.
+func (cm *containerManager) WaitWithUsage(cid *string, res *WaitWithUsageResult) error {
+	log.Debugf("containerManager.WaitWithUsage, cid: %s", *cid)
+	err := cm.l.waitContainer(*cid, &res.WaitStatus, &res.Rusage)
+	log.Debugf("containerManager.WaitWithUsage returned, cid: %s, waitStatus: %#x, err: %v", *cid, res.WaitStatus, err)
+	return err
+}
+
 // WaitPIDArgs are arguments to the WaitPID method.
 type WaitPIDArgs struct {
 	// PID is the PID in the container's PID namespace.
@@ -572,3 +769,53 @@ func (cm *containerManager) Signal(args *SignalArgs, _ *struct{}) error {
 	log.Debugf("containerManager.Signal: cid: %s, PID: %d, signal: %d, mode: %v", args.CID, args.PID, args.Signo, args.Mode)
 	return cm.l.signal(args.CID, args.PID, args.Signo, args.Mode)
 }
+
+// ReloadSeccompArgs are arguments to the ReloadSeccomp method.
+type ReloadSeccompArgs struct {
+	// CID is the container ID.
+	CID string
+
+	// Seccomp is the new seccomp profile.
+	Seccomp *specs.LinuxSeccomp
+
+	// Override replaces the container's existing seccomp filters instead of
+	// adding to them, allowing the new profile to be less restrictive.
+	Override bool
+}
+
+// ReloadSeccomp installs a new OCI seccomp profile for all processes in a
+// container.
+func (cm *containerManager) ReloadSeccomp(args *ReloadSeccompArgs, _ *struct{}) error {
+	log.Debugf("containerManager.ReloadSeccomp: cid: %s, override: %t", args.CID, args.Override)
+	return cm.l.reloadSeccomp(args.CID, args.Seccomp, args.Override)
+}
+
+// UpdateResourcesArgs are arguments to the UpdateResources method.
+type UpdateResourcesArgs struct {
+	// TotalMem is the new amount of total memory to report to the
+	// application. 0 means no limit.
+	TotalMem uint64
+}
+
+// UpdateResources applies updated sandbox resource limits to the sentry. The
+// limits themselves are enforced on the host by the sandbox cgroup.
+func (cm *containerManager) UpdateResources(args *UpdateResourcesArgs, _ *struct{}) error {
+	log.Debugf("containerManager.UpdateResources, total memory: %d", args.TotalMem)
+	usage.SetMaximumTotalMemoryBytes(args.TotalMem)
+	return nil
+}
+
+// Terminals returns the terminals that have open file descriptions in the
+// sentry, ordered by index. It is only supported with VFS2.
+func (cm *containerManager) Terminals(_ *struct{}, out *[]devpts.TerminalInfo) error {
+	log.Debugf("containerManager.Terminals")
+	if !kernel.VFS2Enabled {
+		return fmt.Errorf("listing terminals requires VFS2")
+	}
+	fstype, ok := cm.l.k.VFS().FilesystemType(devpts.Name).(*devpts.FilesystemType)
+	if !ok {
+		return fmt.Errorf("filesystem type %q is not registered", devpts.Name)
+	}
+	*out = fstype.Terminals()
+	return nil
+}
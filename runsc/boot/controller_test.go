@@ -0,0 +1,101 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/state/statefile"
+)
+
+// newStateFile returns the content of a state file recording that it was
+// taken from container cid.
+func newStateFile(t *testing.T, cid string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := statefile.NewWriter(&b, nil, map[string]string{metadataContainerID: cid})
+	if err != nil {
+		t.Fatalf("statefile.NewWriter() failed: %v", err)
+	}
+	if _, err := w.Write([]byte("state")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestCheckRestoreMetadataPipe(t *testing.T) {
+	want := newStateFile(t, "foo")
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() failed: %v", err)
+	}
+	defer r.Close()
+	go func() {
+		w.Write(want)
+		w.Close()
+	}()
+
+	source, err := checkRestoreMetadata(r, &RestoreOpts{SandboxID: "foo"}, &specs.Spec{})
+	if err != nil {
+		t.Fatalf("checkRestoreMetadata() failed: %v", err)
+	}
+	// The whole state file must be read back, including the metadata.
+	got, err := ioutil.ReadAll(source)
+	if err != nil {
+		t.Fatalf("reading state file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("read %d bytes of state file, want the %d bytes written", len(got), len(want))
+	}
+}
+
+func TestCheckRestoreMetadataFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "state")
+	if err != nil {
+		t.Fatalf("ioutil.TempFile() failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := checkRestoreMetadata(f, &RestoreOpts{SandboxID: "foo"}, &specs.Spec{}); err == nil {
+		t.Errorf("checkRestoreMetadata() succeeded with an empty file")
+	}
+
+	want := newStateFile(t, "foo")
+	if _, err := f.Write(want); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if _, err := checkRestoreMetadata(f, &RestoreOpts{SandboxID: "bar"}, &specs.Spec{}); err == nil {
+		t.Errorf("checkRestoreMetadata() succeeded for a different container without remapping")
+	}
+	source, err := checkRestoreMetadata(f, &RestoreOpts{SandboxID: "bar", RemapContainerID: true}, &specs.Spec{})
+	if err != nil {
+		t.Fatalf("checkRestoreMetadata() failed: %v", err)
+	}
+	got, err := ioutil.ReadAll(source)
+	if err != nil {
+		t.Fatalf("reading state file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("read %d bytes of state file, want the %d bytes written", len(got), len(want))
+	}
+}
@@ -15,10 +15,23 @@
 package boot
 
 import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/tcpip/link/sniffer"
+	"gvisor.dev/gvisor/pkg/urpc"
 )
 
+// packetCaptureSnapLen is the maximum number of bytes captured from each packet
+// by SetPacketLogging. It's large enough to hold any packet.
+const packetCaptureSnapLen = 65536
+
 type debug struct {
+	k *kernel.Kernel
 }
 
 // Stacks collects all sandbox stacks and copies them to 'stacks'.
@@ -27,3 +40,68 @@ func (*debug) Stacks(_ *struct{}, stacks *string) error {
 	*stacks = string(buf)
 	return nil
 }
+
+// GoroutineDump returns the stacks of all sentry goroutines, as formatted by
+// runtime.Stack.
+func (*debug) GoroutineDump(_ *struct{}, dump *[]byte) error {
+	*dump = log.Stacks(true)
+	return nil
+}
+
+// Devices lists all devices registered with the sandbox VFS. It's empty when
+// VFS2 is not in use.
+func (d *debug) Devices(_ *struct{}, devices *[]vfs.DeviceInfo) error {
+	*devices = d.k.VFS().Devices()
+	return nil
+}
+
+// PacketLoggingArgs are the arguments to SetPacketLogging.
+type PacketLoggingArgs struct {
+	// Enabled indicates whether packet logging should be started or stopped.
+	Enabled bool
+
+	// FilePayload optionally contains the file to write packets to in PCAP
+	// format. If empty, packets are written to the log in text format.
+	urpc.FilePayload
+}
+
+// SetPacketLogging starts or stops logging packets that traverse the sandbox
+// NICs.
+func (*debug) SetPacketLogging(args *PacketLoggingArgs, _ *struct{}) error {
+	log.Debugf("debug.SetPacketLogging, enabled: %t, files: %d", args.Enabled, len(args.Files))
+	if !args.Enabled {
+		atomic.StoreUint32(&sniffer.LogPackets, 0)
+		stopPacketCapture()
+		log.Infof("Packet logging disabled")
+		return nil
+	}
+
+	switch len(args.Files) {
+	case 0:
+		atomic.StoreUint32(&sniffer.LogPackets, 1)
+		log.Infof("Packet logging enabled")
+	case 1:
+		stopPacketCapture()
+		// The RPC's files are closed once it returns, so keep a copy.
+		f, err := args.ReleaseFD(0)
+		if err != nil {
+			return fmt.Errorf("duplicating PCAP file: %w", err)
+		}
+		if err := sniffer.StartCapture(f, packetCaptureSnapLen); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("starting packet capture: %w", err)
+		}
+		log.Infof("Packet capture to PCAP file enabled")
+	default:
+		return fmt.Errorf("at most one PCAP file can be given, got %d", len(args.Files))
+	}
+	return nil
+}
+
+// stopPacketCapture stops a capture started by SetPacketLogging and closes the
+// file it was writing to.
+func stopPacketCapture() {
+	if f, ok := sniffer.StopCapture().(io.Closer); ok {
+		_ = f.Close()
+	}
+}
@@ -15,7 +15,10 @@
 package boot
 
 import (
+	"sync/atomic"
+
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netstack"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
 )
 
@@ -25,6 +28,9 @@ type EventOut struct {
 
 	// ContainerUsage maps each container ID to its total CPU usage.
 	ContainerUsage map[string]uint64 `json:"containerUsage"`
+
+	// ContainerMemoryUsage maps each container ID to its memory usage.
+	ContainerMemoryUsage map[string]control.MemoryUsage `json:"containerMemoryUsage"`
 }
 
 // Event struct for encoding the event data to JSON. Corresponds to runc's
@@ -89,14 +95,18 @@ func (cm *containerManager) Event(_ *struct{}, out *EventOut) error {
 	}
 
 	// Memory usage.
-	// TODO(gvisor.dev/issue/172): Per-container accounting.
 	mem := cm.l.k.MemoryFile()
 	_ = mem.UpdateUsage() // best effort to update.
-	_, totalUsage := usage.MemoryAccounting.Copy()
+	memStats, totalUsage := usage.MemoryAccounting.Copy()
+	out.Event.Data.Memory.Cache = memStats.PageCache
 	out.Event.Data.Memory.Usage = MemoryEntry{
+		Limit: atomic.LoadUint64(&usage.MaximumTotalMemoryBytes),
 		Usage: totalUsage,
 	}
 
+	// Memory usage by container.
+	out.ContainerMemoryUsage = control.ContainerMemoryUsage(cm.l.k)
+
 	// PIDs.
 	// TODO(gvisor.dev/issue/172): Per-container accounting.
 	out.Event.Data.Pids.Current = uint64(len(cm.l.k.TaskSet().Root.ThreadGroups()))
@@ -106,3 +116,42 @@ func (cm *containerManager) Event(_ *struct{}, out *EventOut) error {
 
 	return nil
 }
+
+// NICStats holds aggregate traffic counters for a single NIC.
+type NICStats struct {
+	RxBytes   uint64 `json:"rxBytes"`
+	RxPackets uint64 `json:"rxPackets"`
+	TxBytes   uint64 `json:"txBytes"`
+	TxPackets uint64 `json:"txPackets"`
+
+	// RxDrops is the number of received packets that were dropped, either
+	// because the NIC was disabled or because the network or transport
+	// protocol is not supported.
+	RxDrops uint64 `json:"rxDrops"`
+
+	// RxErrors is the number of received packets that could not be delivered
+	// because their transport header was malformed.
+	RxErrors uint64 `json:"rxErrors"`
+}
+
+// NetworkStats returns statistics for each NIC in the sandbox network stack,
+// keyed by NIC name. The map is empty if the sandbox doesn't use netstack,
+// e.g. with host networking.
+func (cm *containerManager) NetworkStats(_ *struct{}, out *map[string]NICStats) error {
+	stats := make(map[string]NICStats)
+	if eps, ok := cm.l.k.RootNetworkNamespace().Stack().(*netstack.Stack); ok {
+		for _, ni := range eps.Stack.NICInfo() {
+			s := ni.Stats
+			stats[ni.Name] = NICStats{
+				RxBytes:   s.Rx.Bytes.Value(),
+				RxPackets: s.Rx.Packets.Value(),
+				TxBytes:   s.Tx.Bytes.Value(),
+				TxPackets: s.Tx.Packets.Value(),
+				RxDrops:   s.DisabledRx.Packets.Value() + s.UnknownL3ProtocolRcvdPackets.Value() + s.UnknownL4ProtocolRcvdPackets.Value(),
+				RxErrors:  s.MalformedL4RcvdPackets.Value(),
+			}
+		}
+	}
+	*out = stats
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	runtimedebug "runtime/debug"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+)
+
+const (
+	// idleGCMaxBusyTicks is the number of CPU clock ticks that tasks may
+	// consume during an interval for the sandbox to still be considered idle.
+	// At the default tick of 10ms, this is 100ms of CPU time.
+	idleGCMaxBusyTicks = 10
+
+	// idleGCMaxBackoff is the factor by which the interval grows, at most,
+	// while the sandbox is busy.
+	idleGCMaxBackoff = 8
+)
+
+// idleGC periodically returns unused sentry memory to the host while the
+// sandbox is idle.
+type idleGC struct {
+	k        *kernel.Kernel
+	interval time.Duration
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// startIdleGC starts releasing memory every 'interval' while the sandbox is
+// idle. When the sandbox is busy, the interval is doubled up to
+// idleGCMaxBackoff times, so that GC doesn't add latency to the workload.
+func startIdleGC(k *kernel.Kernel, interval time.Duration) *idleGC {
+	g := &idleGC{
+		k:        k,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go g.run()
+	return g
+}
+
+func (g *idleGC) run() {
+	defer close(g.doneCh)
+
+	wait := g.interval
+	last := g.k.CPUClockNow()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-g.stopCh:
+			return
+		case <-timer.C:
+		}
+
+		now := g.k.CPUClockNow()
+		if busy := now - last; busy > idleGCMaxBusyTicks {
+			if wait < g.interval*idleGCMaxBackoff {
+				wait *= 2
+			}
+			log.Debugf("Skipping idle GC, sandbox is busy (%d ticks), next attempt in %v", busy, wait)
+		} else {
+			wait = g.interval
+			g.release()
+		}
+		last = g.k.CPUClockNow()
+		timer.Reset(wait)
+	}
+}
+
+// release evicts cached file data and returns as much memory as possible to
+// the host.
+func (g *idleGC) release() {
+	start := time.Now()
+	g.k.MemoryFile().StartEvictions()
+	g.k.MemoryFile().WaitForEvictions()
+	runtimedebug.FreeOSMemory()
+	log.Debugf("Idle GC done in %v", time.Since(start))
+}
+
+// stop stops releasing memory and waits for any release in progress to
+// complete.
+func (g *idleGC) stop() {
+	close(g.stopCh)
+	<-g.doneCh
+}
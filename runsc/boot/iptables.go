@@ -0,0 +1,101 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netfilter"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netstack"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+// IPTablesArgs contains arguments to the IPTables method.
+type IPTablesArgs struct {
+	// Table is the name of the table to get, e.g. "filter" or "nat".
+	Table string
+
+	// IPv6 selects the ip6tables rules instead of the iptables ones.
+	IPv6 bool
+}
+
+// IPTables returns the rules of a table of the sandbox network stack, in the
+// format of an IPT_SO_SET_REPLACE payload. See netfilter.GetReplace.
+func (cm *containerManager) IPTables(args *IPTablesArgs, out *[]byte) error {
+	log.Debugf("containerManager.IPTables, table: %q, ipv6: %t", args.Table, args.IPv6)
+	stk, err := cm.l.sandboxNetstack()
+	if err != nil {
+		return err
+	}
+	replace, err := netfilter.GetReplace(stk, args.Table, args.IPv6)
+	if err != nil {
+		return fmt.Errorf("getting table %q: %w", args.Table, err)
+	}
+	*out = replace
+	return nil
+}
+
+// SetIPTablesArgs contains arguments to the SetIPTables method.
+type SetIPTablesArgs struct {
+	// Replace is an IPT_SO_SET_REPLACE payload, as returned by IPTables or
+	// passed by the iptables tool to setsockopt(2). The table to replace is
+	// named in the payload.
+	Replace []byte
+
+	// IPv6 selects the ip6tables rules instead of the iptables ones.
+	IPv6 bool
+}
+
+// SetIPTables replaces a table of the sandbox network stack, as if
+// setsockopt(IPT_SO_SET_REPLACE) was called from inside the sandbox.
+func (cm *containerManager) SetIPTables(args *SetIPTablesArgs, _ *struct{}) error {
+	log.Debugf("containerManager.SetIPTables, size: %d, ipv6: %t", len(args.Replace), args.IPv6)
+	if len(args.Replace) < linux.SizeOfIPTReplace {
+		return fmt.Errorf("rules too short, got %d bytes, want at least %d", len(args.Replace), linux.SizeOfIPTReplace)
+	}
+	stk, err := cm.l.sandboxNetstack()
+	if err != nil {
+		return err
+	}
+
+	// Matchers that depend on credentials, e.g. owner, are interpreted
+	// relative to the root container's init process.
+	cm.l.mu.Lock()
+	defer cm.l.mu.Unlock()
+	ep := cm.l.processes[execID{cid: cm.l.sandboxID}]
+	if ep == nil || ep.tg == nil {
+		return fmt.Errorf("container %q not started", cm.l.sandboxID)
+	}
+	leader := ep.tg.Leader()
+	if leader == nil {
+		return fmt.Errorf("container %q has stopped", cm.l.sandboxID)
+	}
+	if err := netfilter.SetEntries(leader, stk, args.Replace, args.IPv6); err != nil {
+		return fmt.Errorf("replacing rules: %w", err.ToError())
+	}
+	return nil
+}
+
+// sandboxNetstack returns the netstack stack of the sandbox, or an error if
+// the sandbox doesn't use netstack, e.g. with host networking.
+func (l *Loader) sandboxNetstack() (*stack.Stack, error) {
+	eps, ok := l.k.RootNetworkNamespace().Stack().(*netstack.Stack)
+	if !ok {
+		return nil, fmt.Errorf("iptables rules are only supported with netstack networking")
+	}
+	return eps.Stack, nil
+}
@@ -22,6 +22,7 @@ import (
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/limits"
 	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/runsc/config"
 )
 
 // Mapping from linux resource names to limits.LimitType.
@@ -133,14 +134,23 @@ func (d *defs) initDefaults() error {
 	return nil
 }
 
-func createLimitSet(spec *specs.Spec) (*limits.LimitSet, error) {
+func createLimitSet(spec *specs.Spec, conf *config.Config) (*limits.LimitSet, error) {
 	ls, err := defaults.get()
 	if err != nil {
 		return nil, err
 	}
 
-	// Then apply overwrites on top of defaults.
-	for _, rl := range spec.Process.Rlimits {
+	// Then apply overwrites on top of defaults, starting with the limits set
+	// for all containers, so that the spec takes precedence.
+	var rlimits []specs.POSIXRlimit
+	if conf.DefaultUlimits != "" {
+		rlimits, err = config.ParseUlimits(conf.DefaultUlimits)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rlimits = append(rlimits, spec.Process.Rlimits...)
+	for _, rl := range rlimits {
 		lt, ok := fromLinuxResource[rl.Type]
 		if !ok {
 			return nil, fmt.Errorf("unknown resource %q", rl.Type)
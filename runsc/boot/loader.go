@@ -119,6 +119,10 @@ type Loader struct {
 	// container. It should be called when a sandbox is destroyed.
 	stopSignalForwarding func()
 
+	// idleGC releases unused memory while the sandbox is idle. It's nil if
+	// --idle-gc-interval is not set.
+	idleGC *idleGC
+
 	// restore is set to true if we are restoring a container.
 	restore bool
 
@@ -138,6 +142,9 @@ type Loader struct {
 	// mountHints provides extra information about mounts for containers that
 	// apply to the entire pod.
 	mountHints *podMountHints
+
+	// timings records the phases of the sandbox startup.
+	timings bootTimings
 }
 
 // execID uniquely identifies a sentry process that is executed in a container.
@@ -164,6 +171,10 @@ type execProcess struct {
 	// TTY file is passed during container create and must be saved until
 	// container start.
 	hostTTY *fd.FD
+
+	// attached holds the stdio files replaced by attachStdio. It's nil if the
+	// process is not attached.
+	attached *attachedStdio
 }
 
 func init() {
@@ -206,6 +217,8 @@ const startingStdioFD = 256
 // New initializes a new kernel loader configured by spec.
 // New also handles setting up a kernel for restoring a container.
 func New(args Args) (*Loader, error) {
+	start := gtime.Now()
+
 	// We initialize the rand package now to make sure /dev/urandom is pre-opened
 	// on kernels that do not support getrandom(2).
 	if err := rand.Init(); err != nil {
@@ -263,7 +276,7 @@ func New(args Args) (*Loader, error) {
 	}
 
 	// Create memory file.
-	mf, err := createMemoryFile()
+	mf, err := createMemoryFile(args.Conf)
 	if err != nil {
 		return nil, fmt.Errorf("creating memory file: %w", err)
 	}
@@ -284,6 +297,9 @@ func New(args Args) (*Loader, error) {
 	if err := enableStrace(args.Conf); err != nil {
 		return nil, fmt.Errorf("enabling strace: %w", err)
 	}
+	if err := enableSyscallAllowList(args.Conf); err != nil {
+		return nil, fmt.Errorf("enabling syscall allow list: %w", err)
+	}
 
 	// Create root network namespace/stack.
 	netns, err := newRootNetworkNamespace(args.Conf, tk, k)
@@ -337,12 +353,13 @@ func New(args Args) (*Loader, error) {
 		RootIPCNamespace:            kernel.NewIPCNamespace(creds.UserNamespace),
 		RootAbstractSocketNamespace: kernel.NewAbstractSocketNamespace(),
 		PIDNamespace:                kernel.NewRootPIDNamespace(creds.UserNamespace),
+		ForkRateLimit:               args.Conf.ForkRateLimit,
 	}); err != nil {
 		return nil, fmt.Errorf("initializing kernel: %w", err)
 	}
 
 	if kernel.VFS2Enabled {
-		if err := registerFilesystems(k); err != nil {
+		if err := registerFilesystems(k, args.Conf); err != nil {
 			return nil, fmt.Errorf("registering filesystems: %w", err)
 		}
 	}
@@ -365,7 +382,7 @@ func New(args Args) (*Loader, error) {
 	dogOpts.TaskTimeoutAction = args.Conf.WatchdogAction
 	dog := watchdog.New(k, dogOpts)
 
-	procArgs, err := createProcessArgs(args.ID, args.Spec, creds, k, k.RootPIDNamespace())
+	procArgs, err := createProcessArgs(args.ID, args.Spec, args.Conf, creds, k, k.RootPIDNamespace())
 	if err != nil {
 		return nil, fmt.Errorf("creating init process for root container: %w", err)
 	}
@@ -406,6 +423,7 @@ func New(args Args) (*Loader, error) {
 		mountHints: mountHints,
 		root:       info,
 	}
+	l.timings.recordAt(BootPhaseSentryStarted, start)
 
 	// We don't care about child signals; some platforms can generate a
 	// tremendous number of useless ones (I'm looking at you, ptrace).
@@ -428,14 +446,15 @@ func New(args Args) (*Loader, error) {
 	if err := ctrl.srv.StartServing(); err != nil {
 		return nil, fmt.Errorf("starting control server: %w", err)
 	}
+	l.timings.record(BootPhaseSentryInitialized)
 
 	return l, nil
 }
 
 // createProcessArgs creates args that can be used with kernel.CreateProcess.
-func createProcessArgs(id string, spec *specs.Spec, creds *auth.Credentials, k *kernel.Kernel, pidns *kernel.PIDNamespace) (kernel.CreateProcessArgs, error) {
+func createProcessArgs(id string, spec *specs.Spec, conf *config.Config, creds *auth.Credentials, k *kernel.Kernel, pidns *kernel.PIDNamespace) (kernel.CreateProcessArgs, error) {
 	// Create initial limits.
-	ls, err := createLimitSet(spec)
+	ls, err := createLimitSet(spec, conf)
 	if err != nil {
 		return kernel.CreateProcessArgs{}, fmt.Errorf("creating limits: %w", err)
 	}
@@ -478,6 +497,9 @@ func (l *Loader) Destroy() {
 		l.stopSignalForwarding()
 	}
 	l.watchdog.Stop()
+	if l.idleGC != nil {
+		l.idleGC.stop()
+	}
 
 	// Stop the control server. This will indirectly stop any
 	// long-running control operations that are in flight, e.g.
@@ -508,7 +530,7 @@ func createPlatform(conf *config.Config, deviceFile *os.File) (platform.Platform
 	return p.New(deviceFile)
 }
 
-func createMemoryFile() (*pgalloc.MemoryFile, error) {
+func createMemoryFile(conf *config.Config) (*pgalloc.MemoryFile, error) {
 	const memfileName = "runsc-memory"
 	memfd, err := memutil.CreateMemFD(memfileName, 0)
 	if err != nil {
@@ -518,7 +540,12 @@ func createMemoryFile() (*pgalloc.MemoryFile, error) {
 	// We can't enable pgalloc.MemoryFileOpts.UseHostMemcgPressure even if
 	// there are memory cgroups specified, because at this point we're already
 	// in a mount namespace in which the relevant cgroupfs is not visible.
-	mf, err := pgalloc.NewMemoryFile(memfile, pgalloc.MemoryFileOpts{})
+	// Without it, evictable memory (i.e. page cache) is only retained if
+	// --pagecache-limit bounds it.
+	mf, err := pgalloc.NewMemoryFile(memfile, pgalloc.MemoryFileOpts{
+		DelayedEviction: pgalloc.DelayedEvictionEnabled,
+		EvictableLimit:  uint64(conf.PageCacheLimit),
+	})
 	if err != nil {
 		_ = memfile.Close()
 		return nil, fmt.Errorf("error creating pgalloc.MemoryFile: %w", err)
@@ -568,6 +595,7 @@ func (l *Loader) run() error {
 		if err := s.Configure(); err != nil {
 			return err
 		}
+		l.timings.record(BootPhaseNetworkConfigured)
 	}
 
 	l.mu.Lock()
@@ -630,7 +658,16 @@ func (l *Loader) run() error {
 
 	log.Infof("Process should have started...")
 	l.watchdog.Start()
-	return l.k.Start()
+	if interval := l.root.conf.IdleGCInterval; interval > 0 {
+		l.idleGC = startIdleGC(l.k, interval)
+	}
+	if err := l.k.Start(); err != nil {
+		return err
+	}
+	if !l.restore {
+		l.timings.record(BootPhaseInitStarted)
+	}
+	return nil
 }
 
 // createSubcontainer creates a new container inside the sandbox.
@@ -704,7 +741,7 @@ func (l *Loader) startSubcontainer(spec *specs.Spec, conf *config.Config, cid st
 		spec:     spec,
 		goferFDs: goferFDs,
 	}
-	info.procArgs, err = createProcessArgs(cid, spec, creds, l.k, pidns)
+	info.procArgs, err = createProcessArgs(cid, spec, conf, creds, l.k, pidns)
 	if err != nil {
 		return fmt.Errorf("creating new process: %w", err)
 	}
@@ -754,6 +791,9 @@ func (l *Loader) createContainerProcess(root bool, cid string, info *containerIn
 	if err := setupContainerFS(ctx, info.conf, mntr, &info.procArgs); err != nil {
 		return nil, nil, nil, err
 	}
+	if root {
+		l.timings.record(BootPhaseMountsDone)
+	}
 
 	// Add the HOME environment variable if it is not already set.
 	var envv []string
@@ -962,7 +1002,7 @@ func (l *Loader) executeAsync(args *control.ExecArgs) (kernel.ThreadID, error) {
 	}
 	args.PIDNamespace = tg.PIDNamespace()
 
-	args.Limits, err = createLimitSet(l.root.spec)
+	args.Limits, err = createLimitSet(l.root.spec, l.root.conf)
 	if err != nil {
 		return 0, fmt.Errorf("creating limits: %w", err)
 	}
@@ -985,8 +1025,42 @@ func (l *Loader) executeAsync(args *control.ExecArgs) (kernel.ThreadID, error) {
 	return tgid, nil
 }
 
-// waitContainer waits for the init process of a container to exit.
-func (l *Loader) waitContainer(cid string, waitStatus *uint32) error {
+// renameContainer changes the ID of container 'cid' to 'newCID', including the
+// processes it's running. The root container can't be renamed, because its ID
+// is the sandbox ID.
+func (l *Loader) renameContainer(cid, newCID string) error {
+	if cid == l.sandboxID {
+		return fmt.Errorf("root container %q can't be renamed", cid)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var eids []execID
+	for eid := range l.processes {
+		switch eid.cid {
+		case cid:
+			eids = append(eids, eid)
+		case newCID:
+			return fmt.Errorf("container %q already exists", newCID)
+		}
+	}
+	if len(eids) == 0 {
+		return fmt.Errorf("container %q not found", cid)
+	}
+	for _, eid := range eids {
+		ep := l.processes[eid]
+		delete(l.processes, eid)
+		l.processes[execID{cid: newCID, pid: eid.pid}] = ep
+	}
+	l.k.RenameContainer(cid, newCID)
+	return nil
+}
+
+// waitContainer waits for the init process of a container to exit. If ru is
+// not nil, it is filled with the resource usage of the init process and its
+// waited-for children, as wait4(2) would.
+func (l *Loader) waitContainer(cid string, waitStatus *uint32, ru *unix.Rusage) error {
 	// Don't defer unlock, as doing so would make it impossible for
 	// multiple clients to wait on the same container.
 	tg, err := l.threadGroupFromID(execID{cid: cid})
@@ -998,6 +1072,9 @@ func (l *Loader) waitContainer(cid string, waitStatus *uint32) error {
 	// consider the container exited.
 	ws := l.wait(tg)
 	*waitStatus = ws
+	if ru != nil {
+		*ru = rusage(tg)
+	}
 
 	// Check for leaks and write coverage report after the root container has
 	// exited. This guarantees that the report is written in cases where the
@@ -1054,6 +1131,23 @@ func (l *Loader) wait(tg *kernel.ThreadGroup) uint32 {
 	return uint32(tg.ExitStatus())
 }
 
+// rusage returns the resource usage of the exited thread group tg, including
+// its joined children. Fields that the sentry doesn't track are left zero.
+func rusage(tg *kernel.ThreadGroup) unix.Rusage {
+	cs := tg.CPUStats()
+	cs.Accumulate(tg.JoinedChildCPUStats())
+	var maxRSS uint64
+	if leader := tg.Leader(); leader != nil {
+		maxRSS = leader.MaxRSS(linux.RUSAGE_BOTH)
+	}
+	return unix.Rusage{
+		Utime:  unix.NsecToTimeval(cs.UserTime.Nanoseconds()),
+		Stime:  unix.NsecToTimeval(cs.SysTime.Nanoseconds()),
+		Maxrss: int64(maxRSS / 1024),
+		Nvcsw:  int64(cs.VoluntarySwitches),
+	}
+}
+
 // WaitForStartSignal waits for a start signal from the control server.
 func (l *Loader) WaitForStartSignal() {
 	<-l.ctrl.manager.startChan
@@ -1297,6 +1391,41 @@ func (l *Loader) signalAllProcesses(cid string, signo int32) error {
 	return l.k.SendContainerSignal(cid, &linux.SignalInfo{Signo: signo})
 }
 
+// reloadSeccomp installs profile as the OCI seccomp filter of all processes in
+// container cid. Unless override is set, the new filter is stacked on top of
+// the existing ones: seccomp uses the least permissive action of all filters,
+// so the container's policy can only become more restrictive. With override,
+// the new filter replaces all existing ones.
+func (l *Loader) reloadSeccomp(cid string, profile *specs.LinuxSeccomp, override bool) error {
+	if !l.root.conf.OCISeccomp {
+		return fmt.Errorf("OCI seccomp is disabled, see --oci-seccomp")
+	}
+	if profile == nil {
+		return fmt.Errorf("no seccomp profile given")
+	}
+	if _, err := l.threadGroupFromID(execID{cid: cid}); err != nil {
+		return err
+	}
+
+	program, err := seccomp.BuildProgram(profile)
+	if err != nil {
+		return fmt.Errorf("building seccomp program: %w", err)
+	}
+	if log.IsLogging(log.Debug) {
+		out, _ := bpf.DecodeProgram(program)
+		log.Debugf("Reloading OCI seccomp filters for container %q, override: %t\nProgram:\n%s", cid, override, out)
+	}
+
+	// Pause the kernel so that no process in the container is cloned while
+	// the filters are updated.
+	l.k.Pause()
+	defer l.k.Unpause()
+	if err := l.k.AppendContainerSyscallFilter(cid, program, override); err != nil {
+		return fmt.Errorf("installing seccomp filters: %w", err)
+	}
+	return nil
+}
+
 // threadGroupFromID is similar to tryThreadGroupFromIDLocked except that it
 // acquires mutex before calling it and fails in case container hasn't started
 // yet.
@@ -24,13 +24,16 @@ import (
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/fd"
 	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/p9"
 	"gvisor.dev/gvisor/pkg/sentry/contexttest"
 	"gvisor.dev/gvisor/pkg/sentry/fs"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/unet"
@@ -729,3 +732,73 @@ func TestRestoreEnvironment(t *testing.T) {
 		})
 	}
 }
+
+type testEvictableUser struct {
+	mu      sync.Mutex
+	evicted uint64
+}
+
+// Evict implements pgalloc.EvictableMemoryUser.Evict.
+func (u *testEvictableUser) Evict(ctx context.Context, er pgalloc.EvictableRange) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.evicted += er.Length()
+}
+
+func (u *testEvictableUser) evictedBytes() uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.evicted
+}
+
+// TestPageCacheLimit checks that the memory file created by the loader
+// retains page cache up to --pagecache-limit, and evicts the least recently
+// used files beyond it.
+func TestPageCacheLimit(t *testing.T) {
+	conf := testConfig()
+	mf, err := createMemoryFile(conf)
+	if err != nil {
+		t.Fatalf("createMemoryFile failed: %v", err)
+	}
+	if mf.ShouldCacheEvictable() {
+		t.Errorf("ShouldCacheEvictable() = true without --pagecache-limit, want false")
+	}
+	mf.Destroy()
+
+	const page = hostarch.PageSize
+	conf.PageCacheLimit = 2 * page
+	mf, err = createMemoryFile(conf)
+	if err != nil {
+		t.Fatalf("createMemoryFile failed: %v", err)
+	}
+	defer mf.Destroy()
+	if !mf.ShouldCacheEvictable() {
+		t.Errorf("ShouldCacheEvictable() = false with --pagecache-limit, want true")
+	}
+
+	var a, b, c testEvictableUser
+	mf.MarkEvictable(&a, pgalloc.EvictableRange{Start: 0, End: page})
+	mf.MarkEvictable(&b, pgalloc.EvictableRange{Start: 0, End: page})
+	mf.MarkEvictable(&a, pgalloc.EvictableRange{Start: page, End: 2 * page})
+	mf.WaitForEvictions()
+	if got := a.evictedBytes() + b.evictedBytes(); got != 0 {
+		t.Errorf("evicted %d bytes within the limit, want 0", got)
+	}
+
+	// a was used more recently than b, so b is evicted first.
+	mf.MarkEvictable(&c, pgalloc.EvictableRange{Start: 0, End: page})
+	mf.WaitForEvictions()
+	for _, tc := range []struct {
+		name string
+		user *testEvictableUser
+		want uint64
+	}{
+		{"a", &a, 0},
+		{"b", &b, page},
+		{"c", &c, 0},
+	} {
+		if got := tc.user.evictedBytes(); got != tc.want {
+			t.Errorf("user %s evicted %d bytes, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -64,6 +64,9 @@ var (
 // Network exposes methods that can be used to configure a network stack.
 type Network struct {
 	Stack *stack.Stack
+
+	// timings, if not nil, records when the network is configured.
+	timings *bootTimings
 }
 
 // Route represents a route in the network stack.
@@ -90,6 +93,11 @@ type FDBasedLink struct {
 	RXChecksumOffload  bool
 	LinkAddress        net.HardwareAddr
 	QDisc              config.QueueingDiscipline
+	PollMode           config.NetPollMode
+
+	// Transparent enables promiscuous mode and address spoofing on the link,
+	// so that traffic for and from any address can be handled.
+	Transparent bool
 
 	// NumChannels controls how many underlying FD's are to be used to
 	// create this endpoint.
@@ -216,6 +224,7 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 			SoftwareGSOEnabled: link.SoftwareGSOEnabled,
 			TXChecksumOffload:  link.TXChecksumOffload,
 			RXChecksumOffload:  link.RXChecksumOffload,
+			BusyPoll:           link.PollMode == config.NetPollBusy,
 		})
 		if err != nil {
 			return err
@@ -236,6 +245,16 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 			return err
 		}
 
+		if link.Transparent {
+			log.Infof("Enabling transparent proxying on interface %q", link.Name)
+			if err := n.Stack.SetPromiscuousMode(nicID, true); err != nil {
+				return fmt.Errorf("SetPromiscuousMode(%d, true) failed: %v", nicID, err)
+			}
+			if err := n.Stack.SetSpoofing(nicID, true); err != nil {
+				return fmt.Errorf("SetSpoofing(%d, true) failed: %v", nicID, err)
+			}
+		}
+
 		// Collect the routes from this link.
 		for _, r := range link.Routes {
 			route, err := r.toTcpipRoute(nicID)
@@ -272,6 +291,9 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 
 	log.Infof("Setting routes %+v", routes)
 	n.Stack.SetRouteTable(routes)
+	if n.timings != nil {
+		n.timings.record(BootPhaseNetworkConfigured)
+	}
 	return nil
 }
 
@@ -0,0 +1,81 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/platform"
+	"gvisor.dev/gvisor/runsc/boot/platforms"
+)
+
+// PlatformCaps describes the features of the platform that the sandbox runs
+// on.
+type PlatformCaps struct {
+	// Name is the name of the platform, e.g. "ptrace" or "kvm".
+	Name string
+
+	// HardwareVirtualization is true if application code runs in a
+	// hardware-virtualized guest, which requires access to /dev/kvm.
+	HardwareVirtualization bool
+
+	// AddressSpaceIO is true if the sentry can access application memory
+	// directly through its address spaces.
+	AddressSpaceIO bool
+
+	// CooperativelySchedulesAddressSpace is true if the platform has a
+	// limited number of address spaces, which are released when unused.
+	CooperativelySchedulesAddressSpace bool
+
+	// DetectsCPUPreemption is true if the platform reports when a task was
+	// preempted on its CPU.
+	DetectsCPUPreemption bool
+
+	// GlobalMemoryBarrier is true if membarrier(2) global barriers are
+	// supported.
+	GlobalMemoryBarrier bool
+
+	// MapUnit is the alignment of optional mappings into address spaces. 0
+	// means that the cost of a mapping doesn't depend on its size.
+	MapUnit uint64
+
+	// MinUserAddress and MaxUserAddress are the bounds of the application
+	// address space.
+	MinUserAddress uint64
+	MaxUserAddress uint64
+}
+
+// newPlatformCaps returns the features of p, which was created for the
+// platform called name.
+func newPlatformCaps(name string, p platform.Platform) *PlatformCaps {
+	return &PlatformCaps{
+		Name:                               name,
+		HardwareVirtualization:             name == platforms.KVM,
+		AddressSpaceIO:                     p.SupportsAddressSpaceIO(),
+		CooperativelySchedulesAddressSpace: p.CooperativelySchedulesAddressSpace(),
+		DetectsCPUPreemption:               p.DetectsCPUPreemption(),
+		GlobalMemoryBarrier:                p.HaveGlobalMemoryBarrier(),
+		MapUnit:                            p.MapUnit(),
+		MinUserAddress:                     uint64(p.MinUserAddress()),
+		MaxUserAddress:                     uint64(p.MaxUserAddress()),
+	}
+}
+
+// PlatformCapabilities returns the features of the platform that the sandbox
+// runs on.
+func (cm *containerManager) PlatformCapabilities(_ *struct{}, out *PlatformCaps) error {
+	log.Debugf("containerManager.PlatformCapabilities")
+	*out = *newPlatformCaps(cm.l.root.conf.Platform, cm.l.k.Platform)
+	return nil
+}
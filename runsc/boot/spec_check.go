@@ -0,0 +1,186 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/cgroupfs"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/devpts"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/devtmpfs"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/proc"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/sys"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/tmpfs"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// SpecSupport describes how well runsc supports a field of an OCI spec.
+type SpecSupport int
+
+const (
+	// SpecUnsupported fields make the container fail to start.
+	SpecUnsupported SpecSupport = iota
+
+	// SpecPartial fields are only partially honored, e.g. applied to the
+	// sandbox as a whole or only on the host.
+	SpecPartial
+
+	// SpecIgnored fields are accepted but have no effect.
+	SpecIgnored
+)
+
+func (s SpecSupport) String() string {
+	switch s {
+	case SpecUnsupported:
+		return "unsupported"
+	case SpecPartial:
+		return "partial"
+	case SpecIgnored:
+		return "ignored"
+	}
+	return fmt.Sprintf("unknown spec support: %d", s)
+}
+
+// SpecIssue describes a field of an OCI spec that runsc doesn't fully
+// support.
+type SpecIssue struct {
+	// Support is how well the field is supported.
+	Support SpecSupport
+
+	// Field is the JSON path of the field, e.g. "process.rlimits[0]".
+	Field string
+
+	// Reason explains how the field is handled.
+	Reason string
+}
+
+// knownFilesystems are the mount types that are mounted in the sandbox.
+// Mounts of other types are skipped.
+var knownFilesystems = map[string]bool{
+	bind:          true,
+	nonefs:        true,
+	devpts.Name:   true,
+	devtmpfs.Name: true,
+	proc.Name:     true,
+	sys.Name:      true,
+	tmpfs.Name:    true,
+	cgroupfs.Name: true,
+}
+
+// CheckSpec inspects spec for fields that runsc, configured with conf, doesn't
+// fully support. It doesn't repeat the checks of specutils.ValidateSpec, which
+// must have passed.
+func CheckSpec(spec *specs.Spec, conf *config.Config) []SpecIssue {
+	var issues []SpecIssue
+	add := func(support SpecSupport, field, format string, args ...interface{}) {
+		issues = append(issues, SpecIssue{
+			Support: support,
+			Field:   field,
+			Reason:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Process.
+	if spec.Process.ApparmorProfile != "" {
+		add(SpecIgnored, "process.apparmorProfile", "AppArmor profiles can't be applied inside the sandbox, see --apparmor-profile to confine the sandbox itself")
+	}
+	if !spec.Process.NoNewPrivileges {
+		add(SpecIgnored, "process.noNewPrivileges", "PR_SET_NO_NEW_PRIVS is always set")
+	}
+	if spec.Process.OOMScoreAdj != nil {
+		add(SpecPartial, "process.oomScoreAdj", "applied to the sandbox and gofer processes on the host")
+	}
+	for i, rl := range spec.Process.Rlimits {
+		if _, ok := fromLinuxResource[rl.Type]; !ok {
+			add(SpecUnsupported, fmt.Sprintf("process.rlimits[%d]", i), "unknown resource %q", rl.Type)
+		}
+	}
+
+	// Hooks.
+	if h := spec.Hooks; h != nil && len(h.Prestart)+len(h.Poststart)+len(h.Poststop) > 0 {
+		add(SpecPartial, "hooks", "hooks run on the host and can't enter the container's namespaces, which are implemented by the sandbox")
+	}
+
+	// Mounts.
+	for i, m := range spec.Mounts {
+		field := fmt.Sprintf("mounts[%d]", i)
+		specutils.MaybeConvertToBindMount(&m)
+		switch {
+		case !knownFilesystems[m.Type]:
+			add(SpecIgnored, field, "unknown filesystem type %q at %q", m.Type, m.Destination)
+		case m.Type == cgroupfs.Name && !conf.Cgroupfs:
+			add(SpecIgnored, field, "cgroup mount at %q is skipped, see --cgroupfs", m.Destination)
+		case m.Type == cgroupfs.Name:
+			add(SpecPartial, field, "cgroup mount at %q is replaced by an emulated cgroupfs", m.Destination)
+		case !specutils.IsSupportedDevMount(m, conf.VFS2):
+			add(SpecIgnored, field, "dev mount at %q is already provided by the sandbox, see --vfs2", m.Destination)
+		}
+	}
+
+	if spec.Linux == nil {
+		return issues
+	}
+
+	// Namespaces.
+	for i, ns := range spec.Linux.Namespaces {
+		field := fmt.Sprintf("linux.namespaces[%d]", i)
+		switch ns.Type {
+		case specs.NetworkNamespace:
+			if ns.Path != "" && conf.Network == config.NetworkNone {
+				add(SpecIgnored, field, "network namespace %q is not joined with --network=none", ns.Path)
+			}
+		case specs.IPCNamespace, specs.UTSNamespace, specs.MountNamespace:
+			if ns.Path != "" {
+				add(SpecIgnored, field, "%s namespace %q is not joined, the sandbox implements its own", ns.Type, ns.Path)
+			}
+		case specs.CgroupNamespace:
+			add(SpecIgnored, field, "cgroup namespaces are not supported")
+		case specs.UserNamespace:
+			add(SpecPartial, field, "user namespace is only used for the sandbox and gofer processes on the host")
+		}
+	}
+
+	// Linux specific fields.
+	if spec.Linux.Seccomp != nil && !conf.OCISeccomp {
+		add(SpecIgnored, "linux.seccomp", "seccomp profile is not applied, see --oci-seccomp")
+	}
+	if len(spec.Linux.Sysctl) > 0 {
+		add(SpecIgnored, "linux.sysctl", "sysctls are not applied")
+	}
+	if len(spec.Linux.Devices) > 0 {
+		add(SpecIgnored, "linux.devices", "devices are provided by the sandbox")
+	}
+	if r := spec.Linux.Resources; r != nil {
+		add(SpecPartial, "linux.resources", "limits are enforced by the sandbox cgroup on the host, which is shared by all containers in the sandbox")
+		if len(r.Devices) > 0 {
+			add(SpecIgnored, "linux.resources.devices", "device cgroup rules are not applied")
+		}
+	}
+	if len(spec.Linux.MaskedPaths) > 0 {
+		add(SpecIgnored, "linux.maskedPaths", "paths are not masked")
+	}
+	if len(spec.Linux.ReadonlyPaths) > 0 {
+		add(SpecIgnored, "linux.readonlyPaths", "paths are not made read-only")
+	}
+	if spec.Linux.MountLabel != "" {
+		add(SpecIgnored, "linux.mountLabel", "SELinux is not supported")
+	}
+	if spec.Linux.IntelRdt != nil {
+		add(SpecIgnored, "linux.intelRdt", "Intel RDT is not supported")
+	}
+	return issues
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"reflect"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+func TestCheckSpec(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec specs.Spec
+		conf config.Config
+		want map[string]SpecSupport
+	}{
+		{
+			name: "supported",
+			spec: specs.Spec{
+				Process: &specs.Process{
+					NoNewPrivileges: true,
+					Rlimits:         []specs.POSIXRlimit{{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024}},
+				},
+				Mounts: []specs.Mount{
+					{Destination: "/proc", Type: "proc"},
+					{Destination: "/tmp", Type: "tmpfs"},
+					{Destination: "/data", Source: "/data", Options: []string{"rbind"}},
+				},
+				Linux: &specs.Linux{
+					Namespaces: []specs.LinuxNamespace{{Type: specs.PIDNamespace}, {Type: specs.NetworkNamespace, Path: "/proc/1/ns/net"}},
+				},
+			},
+			want: map[string]SpecSupport{},
+		},
+		{
+			name: "process",
+			spec: specs.Spec{
+				Process: &specs.Process{
+					ApparmorProfile: "docker-default",
+					Rlimits:         []specs.POSIXRlimit{{Type: "RLIMIT_NOFILE"}, {Type: "RLIMIT_FOO"}},
+				},
+			},
+			want: map[string]SpecSupport{
+				"process.apparmorProfile": SpecIgnored,
+				"process.noNewPrivileges": SpecIgnored,
+				"process.rlimits[1]":      SpecUnsupported,
+			},
+		},
+		{
+			name: "mounts",
+			spec: specs.Spec{
+				Process: &specs.Process{NoNewPrivileges: true},
+				Mounts: []specs.Mount{
+					{Destination: "/mnt", Type: "nfs"},
+					{Destination: "/sys/fs/cgroup", Type: "cgroup"},
+					{Destination: "/dev/shm", Type: "tmpfs"},
+				},
+			},
+			want: map[string]SpecSupport{
+				"mounts[0]": SpecIgnored,
+				"mounts[1]": SpecIgnored,
+				"mounts[2]": SpecIgnored,
+			},
+		},
+		{
+			name: "mounts-vfs2",
+			spec: specs.Spec{
+				Process: &specs.Process{NoNewPrivileges: true},
+				Mounts: []specs.Mount{
+					{Destination: "/sys/fs/cgroup", Type: "cgroup"},
+					{Destination: "/dev/shm", Type: "tmpfs"},
+				},
+			},
+			conf: config.Config{VFS2: true, Cgroupfs: true},
+			want: map[string]SpecSupport{
+				"mounts[0]": SpecPartial,
+			},
+		},
+		{
+			name: "linux",
+			spec: specs.Spec{
+				Process: &specs.Process{NoNewPrivileges: true},
+				Linux: &specs.Linux{
+					Namespaces: []specs.LinuxNamespace{
+						{Type: specs.IPCNamespace, Path: "/proc/1/ns/ipc"},
+						{Type: specs.CgroupNamespace},
+					},
+					Seccomp:   &specs.LinuxSeccomp{DefaultAction: specs.ActAllow},
+					Sysctl:    map[string]string{"net.ipv4.ip_forward": "1"},
+					Resources: &specs.LinuxResources{},
+				},
+			},
+			want: map[string]SpecSupport{
+				"linux.namespaces[0]": SpecIgnored,
+				"linux.namespaces[1]": SpecIgnored,
+				"linux.seccomp":       SpecIgnored,
+				"linux.sysctl":        SpecIgnored,
+				"linux.resources":     SpecPartial,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := make(map[string]SpecSupport)
+			for _, issue := range CheckSpec(&tc.spec, &tc.conf) {
+				got[issue.Field] = issue.Support
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("CheckSpec() = %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
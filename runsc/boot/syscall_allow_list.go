@@ -0,0 +1,79 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// enableSyscallAllowList makes the syscalls that are not in
+// conf.SyscallAllowList fail with ENOSYS, in all syscall tables.
+func enableSyscallAllowList(conf *config.Config) error {
+	if conf.SyscallAllowList == "" {
+		return nil
+	}
+	list := strings.Split(conf.SyscallAllowList, ",")
+
+	// A name only needs to be known by one of the tables, since syscalls
+	// differ between architectures.
+	found := make(map[string]bool)
+	denied := make(map[*kernel.SyscallTable]map[uintptr]bool)
+	for _, table := range kernel.SyscallTables() {
+		denied[table] = deniedSyscalls(table, list, found)
+	}
+	for _, sc := range list {
+		if !found[sc] {
+			return fmt.Errorf("syscall %q not found", sc)
+		}
+	}
+
+	for table, syscalls := range denied {
+		// Missing syscalls already fail with ENOSYS, leave them to
+		// table.Missing so that they are still reported.
+		table.FeatureEnable.Enable(kernel.SyscallDenyEnable, syscalls, false)
+	}
+	return nil
+}
+
+// deniedSyscalls returns the syscalls of table that are not in list, which
+// contains syscall names or numbers. The entries of list that match a syscall
+// of table are added to found.
+func deniedSyscalls(table *kernel.SyscallTable, list []string, found map[string]bool) map[uintptr]bool {
+	allowed := make(map[uintptr]bool)
+	for _, sc := range list {
+		if sysno, err := strconv.ParseUint(sc, 10, 64); err == nil {
+			allowed[uintptr(sysno)] = true
+			found[sc] = true
+			continue
+		}
+		if sysno, err := table.LookupNo(sc); err == nil {
+			allowed[sysno] = true
+			found[sc] = true
+		}
+	}
+
+	denied := make(map[uintptr]bool)
+	for sysno := range table.Table {
+		if !allowed[sysno] {
+			denied[sysno] = true
+		}
+	}
+	return denied
+}
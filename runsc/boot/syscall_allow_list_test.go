@@ -0,0 +1,69 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"reflect"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+)
+
+func TestDeniedSyscalls(t *testing.T) {
+	table := &kernel.SyscallTable{
+		Table: map[uintptr]kernel.Syscall{
+			0: {Name: "read"},
+			1: {Name: "write"},
+			2: {Name: "open"},
+			3: {Name: "close"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		list      []string
+		want      map[uintptr]bool
+		wantFound map[string]bool
+	}{
+		{
+			name:      "names",
+			list:      []string{"read", "write"},
+			want:      map[uintptr]bool{2: true, 3: true},
+			wantFound: map[string]bool{"read": true, "write": true},
+		},
+		{
+			name:      "numbers",
+			list:      []string{"0", "3"},
+			want:      map[uintptr]bool{1: true, 2: true},
+			wantFound: map[string]bool{"0": true, "3": true},
+		},
+		{
+			name:      "unknown",
+			list:      []string{"close", "bogus"},
+			want:      map[uintptr]bool{0: true, 1: true, 2: true},
+			wantFound: map[string]bool{"close": true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			found := make(map[string]bool)
+			if got := deniedSyscalls(table, tc.list, found); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("deniedSyscalls(%v) = %v, want %v", tc.list, got, tc.want)
+			}
+			if !reflect.DeepEqual(found, tc.wantFound) {
+				t.Errorf("deniedSyscalls(%v) found %v, want %v", tc.list, found, tc.wantFound)
+			}
+		})
+	}
+}
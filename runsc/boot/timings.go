@@ -0,0 +1,103 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// Phases of the startup of a sandbox, in the order they normally complete.
+// BootPhaseCreated and BootPhaseGoferStarted are recorded on the host, the
+// others by the sentry.
+const (
+	// BootPhaseCreated is when the root container started to be created.
+	BootPhaseCreated = "created"
+
+	// BootPhaseGoferStarted is when the gofer process was spawned.
+	BootPhaseGoferStarted = "gofer-started"
+
+	// BootPhaseSentryStarted is when the sandbox process started to
+	// initialize the sentry.
+	BootPhaseSentryStarted = "sentry-started"
+
+	// BootPhaseSentryInitialized is when the kernel was initialized and the
+	// control server started.
+	BootPhaseSentryInitialized = "sentry-initialized"
+
+	// BootPhaseNetworkConfigured is when the sandbox network was configured.
+	// It's missing with --network=none.
+	BootPhaseNetworkConfigured = "network-configured"
+
+	// BootPhaseMountsDone is when the root container filesystem was mounted.
+	BootPhaseMountsDone = "mounts-done"
+
+	// BootPhaseInitStarted is when the root container init process started
+	// running.
+	BootPhaseInitStarted = "init-started"
+)
+
+// BootPhase is the time at which a phase of the startup of a sandbox
+// completed.
+type BootPhase struct {
+	// Name is one of the BootPhase* constants.
+	Name string
+
+	// Time is when the phase completed.
+	Time time.Time
+}
+
+// bootTimings records the phases of the startup of the sandbox.
+type bootTimings struct {
+	// mu protects phases.
+	mu     sync.Mutex
+	phases []BootPhase
+}
+
+// record records that phase completed now. Only the first completion of each
+// phase is recorded.
+func (b *bootTimings) record(phase string) {
+	b.recordAt(phase, time.Now())
+}
+
+// recordAt records that phase completed at t.
+func (b *bootTimings) recordAt(phase string, t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, p := range b.phases {
+		if p.Name == phase {
+			return
+		}
+	}
+	log.Debugf("Boot phase %q completed", phase)
+	b.phases = append(b.phases, BootPhase{Name: phase, Time: t})
+}
+
+// get returns a copy of the recorded phases.
+func (b *bootTimings) get() []BootPhase {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]BootPhase(nil), b.phases...)
+}
+
+// BootTimings returns the phases of the sandbox startup recorded by the
+// sentry.
+func (cm *containerManager) BootTimings(_ *struct{}, out *[]BootPhase) error {
+	log.Debugf("containerManager.BootTimings")
+	*out = cm.l.timings.get()
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBootTimingsRecordsFirstCompletion(t *testing.T) {
+	var b bootTimings
+	first := time.Unix(1, 0)
+	b.recordAt(BootPhaseSentryStarted, first)
+	b.recordAt(BootPhaseSentryStarted, time.Unix(2, 0))
+	b.record(BootPhaseSentryInitialized)
+
+	phases := b.get()
+	if len(phases) != 2 {
+		t.Fatalf("got %d phases, want 2: %+v", len(phases), phases)
+	}
+	if phases[0].Name != BootPhaseSentryStarted || !phases[0].Time.Equal(first) {
+		t.Errorf("phases[0] = %+v, want %q at %v", phases[0], BootPhaseSentryStarted, first)
+	}
+	if phases[1].Name != BootPhaseSentryInitialized {
+		t.Errorf("phases[1].Name = %q, want %q", phases[1].Name, BootPhaseSentryInitialized)
+	}
+
+	// The returned slice is a copy.
+	phases[0].Name = "modified"
+	if got := b.get()[0].Name; got != BootPhaseSentryStarted {
+		t.Errorf("get()[0].Name = %q after modifying a previous result, want %q", got, BootPhaseSentryStarted)
+	}
+}
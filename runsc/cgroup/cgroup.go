@@ -388,6 +388,29 @@ func (c *Cgroup) Install(res *specs.LinuxResources) error {
 	return nil
 }
 
+// Update applies 'res' to an installed cgroup. Unlike Install, limits are
+// also written to controllers that were pre-configured by the caller.
+// Optional controllers that don't exist are skipped.
+func (c *Cgroup) Update(res *specs.LinuxResources) error {
+	log.Debugf("Updating cgroup path %q", c.Name)
+	for key, ctrlr := range controllers {
+		path := c.MakePath(key)
+		if _, err := os.Stat(path); err != nil {
+			if ctrlr.optional() && os.IsNotExist(err) {
+				if err := ctrlr.skip(res); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if err := ctrlr.set(res, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Uninstall removes the settings done in Install(). If cgroup path already
 // existed when Install() was called, Uninstall is a noop.
 func (c *Cgroup) Uninstall() error {
@@ -483,6 +506,16 @@ func (c *Cgroup) CPUQuota() (float64, error) {
 	return float64(quota) / float64(period), nil
 }
 
+// CPUQuotaUs returns the CFS CPU quota in microseconds per period, or -1 if
+// there is no quota.
+func (c *Cgroup) CPUQuotaUs() (int64, error) {
+	quota, err := getInt(c.MakePath("cpu"), "cpu.cfs_quota_us")
+	if err != nil {
+		return 0, err
+	}
+	return int64(quota), nil
+}
+
 // CPUUsage returns the total CPU usage of the cgroup.
 func (c *Cgroup) CPUUsage() (uint64, error) {
 	path := c.MakePath("cpuacct")
@@ -513,6 +546,23 @@ func (c *Cgroup) MemoryLimit() (uint64, error) {
 	return strconv.ParseUint(strings.TrimSpace(limStr), 10, 64)
 }
 
+// MemoryUsage returns the current and maximum memory usage, and the number of
+// times the memory limit was hit.
+func (c *Cgroup) MemoryUsage() (usage, maxUsage, failcnt uint64, err error) {
+	path := c.MakePath("memory")
+	vals := make([]uint64, 3)
+	for i, name := range []string{"memory.usage_in_bytes", "memory.max_usage_in_bytes", "memory.failcnt"} {
+		str, err := getValue(path, name)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if vals[i], err = strconv.ParseUint(strings.TrimSpace(str), 10, 64); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return vals[0], vals[1], vals[2], nil
+}
+
 // MakePath builds a path to the given controller.
 func (c *Cgroup) MakePath(controllerName string) string {
 	path := c.Name
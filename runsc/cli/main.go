@@ -70,12 +70,14 @@ func Main(version string) {
 
 	// Register user-facing runsc commands.
 	subcommands.Register(new(cmd.Checkpoint), "")
+	subcommands.Register(new(cmd.CheckpointSelfTest), "")
 	subcommands.Register(new(cmd.Create), "")
 	subcommands.Register(new(cmd.Delete), "")
 	subcommands.Register(new(cmd.Do), "")
 	subcommands.Register(new(cmd.Events), "")
 	subcommands.Register(new(cmd.Exec), "")
 	subcommands.Register(new(cmd.Gofer), "")
+	subcommands.Register(new(cmd.IPTables), "")
 	subcommands.Register(new(cmd.Kill), "")
 	subcommands.Register(new(cmd.List), "")
 	subcommands.Register(new(cmd.Pause), "")
@@ -84,6 +86,7 @@ func Main(version string) {
 	subcommands.Register(new(cmd.Resume), "")
 	subcommands.Register(new(cmd.Run), "")
 	subcommands.Register(new(cmd.Spec), "")
+	subcommands.Register(new(cmd.SpecCheck), "")
 	subcommands.Register(new(cmd.State), "")
 	subcommands.Register(new(cmd.Start), "")
 	subcommands.Register(new(cmd.Symbolize), "")
@@ -172,14 +175,31 @@ func Main(version string) {
 	if *debugLogFD > -1 {
 		f := os.NewFile(uintptr(*debugLogFD), "debug log file")
 
-		e = newEmitter(conf.DebugLogFormat, f)
+		if conf.LogRotateSize > 0 {
+			// Rotated log files are donated right after the debug log FD.
+			files := []*os.File{f}
+			for i := 1; i <= conf.LogRotateCount; i++ {
+				files = append(files, os.NewFile(uintptr(*debugLogFD+i), fmt.Sprintf("debug log file %d", i)))
+			}
+			e = newEmitter(conf.DebugLogFormat, newRotatingWriter(files, conf.LogRotateSize))
+		} else {
+			e = newEmitter(conf.DebugLogFormat, f)
+		}
 
 	} else if conf.DebugLog != "" {
-		f, err := specutils.DebugLogFile(conf.DebugLog, subcommand, "" /* name */)
+		rotate := 0
+		if conf.LogRotateSize > 0 {
+			rotate = conf.LogRotateCount
+		}
+		files, err := specutils.DebugLogFiles(conf.DebugLog, subcommand, "" /* name */, rotate)
 		if err != nil {
 			cmd.Fatalf("error opening debug log file in %q: %v", conf.DebugLog, err)
 		}
-		e = newEmitter(conf.DebugLogFormat, f)
+		if conf.LogRotateSize > 0 {
+			e = newEmitter(conf.DebugLogFormat, newRotatingWriter(files, conf.LogRotateSize))
+		} else {
+			e = newEmitter(conf.DebugLogFormat, files[0])
+		}
 
 	} else {
 		// Stderr is reserved for the application, just discard the logs if no debug
@@ -258,6 +278,14 @@ func Main(version string) {
 	os.Exit(128)
 }
 
+func newRotatingWriter(files []*os.File, size int) io.Writer {
+	w, err := log.NewRotatingWriter(files, int64(size))
+	if err != nil {
+		cmd.Fatalf("error setting up debug log rotation: %v", err)
+	}
+	return w
+}
+
 func newEmitter(format string, logFile io.Writer) log.Emitter {
 	switch format {
 	case "text":
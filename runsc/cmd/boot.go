@@ -144,6 +144,27 @@ func (b *Boot) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		}
 	}
 
+	if conf.CPUAffinity != "" {
+		// Pin the sandbox before it execs itself below, so that the new
+		// process images inherit the affinity, including the Go runtime's
+		// view of the number of CPUs. The CPUs have been checked against the
+		// online set by the sandbox's creator, /sys may not be available here.
+		cpus, err := config.ParseCPUList(conf.CPUAffinity)
+		if err != nil {
+			Fatalf("invalid CPU affinity: %v", err)
+		}
+		if err := specutils.SetCPUAffinity(cpus); err != nil {
+			Fatalf("setting CPU affinity: %v", err)
+		}
+	}
+
+	if conf.AppArmorProfile != "" && !specutils.InAppArmorProfile(conf.AppArmorProfile) {
+		// Confine the sandbox before anything else is done. The spec is read
+		// again after the exec call, see comments below.
+		Fatalf("callSelfWithAppArmorProfile(%q): %v", conf.AppArmorProfile, callSelfWithAppArmorProfile(conf.AppArmorProfile))
+		panic("unreachable")
+	}
+
 	if b.setUpRoot {
 		if err := setUpChroot(b.pidns); err != nil {
 			Fatalf("error setting up chroot: %v", err)
@@ -97,7 +97,10 @@ func (c *Checkpoint) Execute(_ context.Context, f *flag.FlagSet, args ...interfa
 	}
 	defer file.Close()
 
-	if err := cont.Checkpoint(file); err != nil {
+	progress := func(p container.CheckpointProgress) {
+		log.Infof("Checkpoint of container %q: %d bytes written, estimated total %d bytes", id, p.Written, p.Total)
+	}
+	if err := cont.CheckpointWithProgress(file, progress); err != nil {
 		Fatalf("checkpoint failed: %v", err)
 	}
 
@@ -144,7 +147,7 @@ func (c *Checkpoint) Execute(_ context.Context, f *flag.FlagSet, args ...interfa
 	}
 	defer cont.Destroy()
 
-	if err := cont.Restore(spec, conf, fullImagePath); err != nil {
+	if err := cont.Restore(spec, conf, fullImagePath, false /* remapID */); err != nil {
 		Fatalf("starting container: %v", err)
 	}
 
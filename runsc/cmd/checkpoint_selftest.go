@@ -0,0 +1,186 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/subcommands"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// CheckpointSelfTest implements subcommands.Command for the
+// "checkpoint-selftest" command.
+type CheckpointSelfTest struct {
+	aliveFor time.Duration
+}
+
+// Name implements subcommands.Command.Name.
+func (*CheckpointSelfTest) Name() string {
+	return "checkpoint-selftest"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*CheckpointSelfTest) Synopsis() string {
+	return "check that a container can be checkpointed and restored (experimental)"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*CheckpointSelfTest) Usage() string {
+	return `checkpoint-selftest [flags] <container id> - checkpoint the container to a
+temporary image, leaving it running, and restore the image into a throwaway
+sandbox, which must stay alive.
+
+The throwaway sandbox runs the workload for a short time with the container's
+spec, with an overlay over all of its mounts so that it can't modify them. It
+is removed when the test ends. The image is removed if the test passes and
+kept otherwise.
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (c *CheckpointSelfTest) SetFlags(f *flag.FlagSet) {
+	f.DurationVar(&c.aliveFor, "alive-for", time.Second, "how long the restored container must stay alive")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (c *CheckpointSelfTest) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	cont, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		return Errorf("loading container: %v", err)
+	}
+	if cont.Sandbox == nil || cont.Sandbox.ID != cont.ID {
+		return Errorf("container %q is not the root container of a sandbox", id)
+	}
+	if cont.BundleDir == "" {
+		return Errorf("container %q has no bundle directory", id)
+	}
+	spec, err := specutils.ReadSpec(cont.BundleDir, conf)
+	if err != nil {
+		return Errorf("reading spec: %v", err)
+	}
+
+	imagePath, err := ioutil.TempDir("", "runsc-checkpoint-selftest-")
+	if err != nil {
+		return Errorf("creating image directory: %v", err)
+	}
+	if err := checkpointSelfTest(conf, cont, spec, imagePath, c.aliveFor); err != nil {
+		fmt.Printf("Checkpoint/restore self-test of container %q failed, image kept at %q: %v\n", id, imagePath, err)
+		return subcommands.ExitFailure
+	}
+	if err := os.RemoveAll(imagePath); err != nil {
+		log.Warningf("Removing image %q: %v", imagePath, err)
+	}
+	fmt.Printf("Checkpoint/restore self-test of container %q passed\n", id)
+	return subcommands.ExitSuccess
+}
+
+// checkpointSelfTest checkpoints cont to imagePath without stopping it, and
+// checks that the image can be restored into a throwaway container that stays
+// alive for aliveFor. cont is left running whatever the outcome.
+func checkpointSelfTest(conf *config.Config, cont *container.Container, spec *specs.Spec, imagePath string, aliveFor time.Duration) error {
+	fullImagePath := filepath.Join(imagePath, checkpointFileName)
+	file, err := os.OpenFile(fullImagePath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(%q) failed: %v", fullImagePath, err)
+	}
+	err = cont.CheckpointWithOpts(conf, file, container.CheckpointOpts{Resume: true})
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("checkpoint failed: %v", err)
+	}
+	if err := checkAlive(cont); err != nil {
+		return fmt.Errorf("container %q after checkpoint: %v", cont.ID, err)
+	}
+	return restoreAndCheck(conf, cont, spec, fullImagePath, aliveFor)
+}
+
+// restoreAndCheck restores the image at imagePath into a throwaway container
+// and checks that it stays alive for aliveFor. The throwaway container is
+// destroyed before returning.
+func restoreAndCheck(conf *config.Config, cont *container.Container, spec *specs.Spec, imagePath string, aliveFor time.Duration) error {
+	// The throwaway container shares the mounts of cont, which is still
+	// running. Keep its changes in memory so that it doesn't interfere.
+	cloneConf := *conf
+	cloneConf.Overlay = true
+	if cloneConf.FileAccess == config.FileAccessShared {
+		cloneConf.FileAccess = config.FileAccessExclusive
+	}
+
+	args := container.Args{
+		ID:        fmt.Sprintf("%s-selftest-%d", cont.ID, os.Getpid()),
+		Spec:      spec,
+		BundleDir: cont.BundleDir,
+	}
+	log.Infof("Restoring container %q into throwaway container %q", cont.ID, args.ID)
+	clone, err := container.New(&cloneConf, args)
+	if err != nil {
+		return fmt.Errorf("creating throwaway container: %v", err)
+	}
+	defer func() {
+		if err := clone.Destroy(); err != nil {
+			log.Warningf("Destroying throwaway container %q: %v", clone.ID, err)
+		}
+	}()
+
+	if err := clone.Restore(spec, &cloneConf, imagePath, true /* remapID */); err != nil {
+		return fmt.Errorf("restoring: %v", err)
+	}
+	if err := checkAlive(clone); err != nil {
+		return err
+	}
+	time.Sleep(aliveFor)
+	return checkAlive(clone)
+}
+
+// checkAlive checks that the sentry of cont responds and that its init process
+// is still running.
+func checkAlive(cont *container.Container) error {
+	if !cont.IsSandboxRunning() {
+		return fmt.Errorf("sandbox is not running")
+	}
+	// Any request exercises the sentry's control server.
+	if _, err := cont.Event(); err != nil {
+		return fmt.Errorf("sentry is not responding: %v", err)
+	}
+	procs, err := cont.Processes()
+	if err != nil {
+		return fmt.Errorf("listing processes: %v", err)
+	}
+	for _, p := range procs {
+		if p.PID == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("init process is not running")
+}
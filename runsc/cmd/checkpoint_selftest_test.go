@@ -0,0 +1,79 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/test/testutil"
+	"gvisor.dev/gvisor/runsc/container"
+)
+
+// TestCheckpointSelfTest checks that the self-test leaves the container
+// running in its original sandbox and removes the throwaway container.
+func TestCheckpointSelfTest(t *testing.T) {
+	stop := testutil.StartReaper()
+	defer stop()
+
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "10000")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := container.Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := container.New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+	sandboxPid := c.Sandbox.Pid
+
+	imagePath, err := ioutil.TempDir(testutil.TmpDir(), "checkpoint-selftest")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(imagePath)
+
+	if err := checkpointSelfTest(conf, c, spec, imagePath, 100*time.Millisecond); err != nil {
+		t.Fatalf("checkpointSelfTest failed: %v", err)
+	}
+
+	if err := checkAlive(c); err != nil {
+		t.Errorf("container %q after self-test: %v", c.ID, err)
+	}
+	if c.Sandbox.Pid != sandboxPid {
+		t.Errorf("sandbox PID changed from %d to %d", sandboxPid, c.Sandbox.Pid)
+	}
+	ids, err := container.List(conf.RootDir)
+	if err != nil {
+		t.Fatalf("listing containers: %v", err)
+	}
+	if len(ids) != 1 || ids[0].ContainerID != c.ID {
+		t.Errorf("got containers %v, want only %q", ids, c.ID)
+	}
+}
@@ -17,6 +17,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 	"strconv"
 
@@ -75,6 +76,23 @@ func setCapsAndCallSelf(args []string, caps *specs.LinuxCapabilities) error {
 	return fmt.Errorf("error executing %s: %v", binPath, err)
 }
 
+// callSelfWithAppArmorProfile execve's itself again with the same arguments,
+// transitioning into the given AppArmor profile.
+func callSelfWithAppArmorProfile(profile string) error {
+	// Keep thread locked, the exec attribute is per thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := specutils.SetAppArmorProfileOnExec(profile); err != nil {
+		return err
+	}
+	binPath := specutils.ExePath
+
+	log.Infof("Execve %q again in AppArmor profile %q, bye!", binPath, profile)
+	err := unix.Exec(binPath, os.Args, []string{})
+	return fmt.Errorf("error executing %s: %v", binPath, err)
+}
+
 // callSelfAsNobody sets UID and GID to nobody and then execve's itself again.
 func callSelfAsNobody(args []string) error {
 	// Keep thread locked while user/group are changed.
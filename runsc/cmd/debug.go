@@ -16,17 +16,22 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/google/subcommands"
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/devpts"
+	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
 	"gvisor.dev/gvisor/runsc/flag"
@@ -36,6 +41,7 @@ import (
 type Debug struct {
 	pid          int
 	stacks       bool
+	stacksFile   string
 	signal       int
 	profileHeap  string
 	profileCPU   string
@@ -45,9 +51,13 @@ type Debug struct {
 	strace       string
 	logLevel     string
 	logPackets   string
+	packetLog    string
 	delay        time.Duration
 	duration     time.Duration
 	ps           bool
+	devices      bool
+	bootTimings  bool
+	terminals    bool
 }
 
 // Name implements subcommands.Command.
@@ -68,7 +78,8 @@ func (*Debug) Usage() string {
 // SetFlags implements subcommands.Command.
 func (d *Debug) SetFlags(f *flag.FlagSet) {
 	f.IntVar(&d.pid, "pid", 0, "sandbox process ID. Container ID is not necessary if this is set")
-	f.BoolVar(&d.stacks, "stacks", false, "if true, dumps all sandbox stacks to the log")
+	f.BoolVar(&d.stacks, "stacks", false, "if true, writes all sandbox stacks to stdout")
+	f.StringVar(&d.stacksFile, "stacks-file", "", "writes all sandbox stacks to the given file instead of stdout. Implies --stacks.")
 	f.StringVar(&d.profileHeap, "profile-heap", "", "writes heap profile to the given file.")
 	f.StringVar(&d.profileCPU, "profile-cpu", "", "writes CPU profile to the given file.")
 	f.StringVar(&d.profileBlock, "profile-block", "", "writes block profile to the given file.")
@@ -80,7 +91,11 @@ func (d *Debug) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&d.strace, "strace", "", `A comma separated list of syscalls to trace. "all" enables all traces, "off" disables all.`)
 	f.StringVar(&d.logLevel, "log-level", "", "The log level to set: warning (0), info (1), or debug (2).")
 	f.StringVar(&d.logPackets, "log-packets", "", "A boolean value to enable or disable packet logging: true or false.")
+	f.StringVar(&d.packetLog, "packet-log", "", `starts capturing packets to the given file in PCAP format. "off" stops the capture.`)
 	f.BoolVar(&d.ps, "ps", false, "lists processes")
+	f.BoolVar(&d.devices, "devices", false, "lists devices registered in the sandbox")
+	f.BoolVar(&d.bootTimings, "boot-timings", false, "reports how long each phase of the sandbox startup took. Requires the root container")
+	f.BoolVar(&d.terminals, "terminals", false, "lists terminals with open file descriptions in the sandbox, which can reveal leaked PTYs")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -139,13 +154,20 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 			return Errorf("failed to send signal %d to processs %d", d.signal, c.Sandbox.Pid)
 		}
 	}
-	if d.stacks {
+	if d.stacks || d.stacksFile != "" {
 		log.Infof("Retrieving sandbox stacks")
-		stacks, err := c.Sandbox.Stacks()
+		stacks, err := c.Sandbox.GoroutineDump()
 		if err != nil {
 			return Errorf("retrieving stacks: %v", err)
 		}
-		log.Infof("     *** Stack dump ***\n%s", stacks)
+		if d.stacksFile != "" {
+			if err := ioutil.WriteFile(d.stacksFile, stacks, 0644); err != nil {
+				return Errorf("error writing stacks to %q: %v", d.stacksFile, err)
+			}
+			log.Infof("Stack dump written to %q", d.stacksFile)
+		} else if _, err := os.Stdout.Write(stacks); err != nil {
+			return Errorf("error writing stacks: %v", err)
+		}
 	}
 	if d.strace != "" || len(d.logLevel) != 0 || len(d.logPackets) != 0 {
 		args := control.LoggingArgs{}
@@ -203,6 +225,19 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		}
 		log.Infof("Logging options changed")
 	}
+	if d.packetLog != "" {
+		if d.packetLog == "off" {
+			log.Infof("Stopping packet capture")
+			if err := c.Sandbox.SetPacketLogging(false, ""); err != nil {
+				return Errorf("stopping packet capture: %v", err)
+			}
+		} else {
+			log.Infof("Capturing packets to %q", d.packetLog)
+			if err := c.Sandbox.SetPacketLogging(true, d.packetLog); err != nil {
+				return Errorf("starting packet capture: %v", err)
+			}
+		}
+	}
 	if d.ps {
 		pList, err := c.Processes()
 		if err != nil {
@@ -214,6 +249,35 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		}
 		log.Infof(o)
 	}
+	if d.devices {
+		devices, err := c.Sandbox.Devices()
+		if err != nil {
+			return Errorf("retrieving devices: %v", err)
+		}
+		var b strings.Builder
+		for _, dev := range devices {
+			fmt.Fprintf(&b, "%s %d:%d %s %s", dev.Kind, dev.Major, dev.Minor, dev.Type, dev.GroupName)
+			for _, f := range dev.Files {
+				fmt.Fprintf(&b, " /dev/%s (%#o)", f.Pathname, f.Perms)
+			}
+			b.WriteString("\n")
+		}
+		log.Infof("     *** Devices ***\n%s", b.String())
+	}
+	if d.bootTimings {
+		phases, err := c.BootTimings()
+		if err != nil {
+			return Errorf("retrieving boot timings: %v", err)
+		}
+		log.Infof("     *** Boot timings ***\n%s", formatBootTimings(phases))
+	}
+	if d.terminals {
+		terms, err := c.Terminals()
+		if err != nil {
+			return Errorf("retrieving terminals: %v", err)
+		}
+		log.Infof("     *** Terminals ***\n%s", formatTerminals(terms))
+	}
 
 	// Open profiling files.
 	var (
@@ -369,3 +433,35 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 
 	return subcommands.ExitSuccess
 }
+
+// formatBootTimings returns a table with the time elapsed since the first
+// phase and since the previous phase for each of phases, which must be ordered
+// by time.
+func formatBootTimings(phases []boot.BootPhase) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprint(w, "PHASE\tELAPSED\tDELTA\n")
+	for i, p := range phases {
+		var elapsed, delta time.Duration
+		if i > 0 {
+			elapsed = p.Time.Sub(phases[0].Time)
+			delta = p.Time.Sub(phases[i-1].Time)
+		}
+		fmt.Fprintf(w, "%s\t%v\t%v\n", p.Name, elapsed, delta)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// formatTerminals returns a table with the number of open master and replica
+// file descriptions of each of terms.
+func formatTerminals(terms []devpts.TerminalInfo) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprint(w, "PTY\tMASTER\tREPLICA\n")
+	for _, t := range terms {
+		fmt.Fprintf(w, "/dev/pts/%d\t%d\t%d\n", t.Index, t.MasterFDs, t.ReplicaFDs)
+	}
+	_ = w.Flush()
+	return b.String()
+}
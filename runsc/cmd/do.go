@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -45,8 +46,59 @@ type Do struct {
 	root    string
 	cwd     string
 	ip      string
+	ip6     string
 	quiet   bool
 	overlay bool
+	publish publishFlags
+}
+
+// portMapping is a host port forwarded to a port of the sandbox.
+type portMapping struct {
+	hostPort      int
+	containerPort int
+	proto         string
+}
+
+// publishFlags is the value of the repeatable --publish flag.
+type publishFlags []portMapping
+
+// String implements flag.Value.
+func (p *publishFlags) String() string {
+	var ms []string
+	for _, m := range *p {
+		ms = append(ms, fmt.Sprintf("%d:%d/%s", m.hostPort, m.containerPort, m.proto))
+	}
+	return strings.Join(ms, ",")
+}
+
+// Get implements flag.Value.
+func (p *publishFlags) Get() interface{} {
+	return p
+}
+
+// Set implements flag.Value. It parses HOSTPORT:CONTAINERPORT[/tcp|udp].
+func (p *publishFlags) Set(s string) error {
+	ports, proto := s, "tcp"
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		ports, proto = s[:i], s[i+1:]
+	}
+	if proto != "tcp" && proto != "udp" {
+		return fmt.Errorf("invalid protocol %q in %q, must be tcp or udp", proto, s)
+	}
+	parts := strings.Split(ports, ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid port mapping %q, must be HOSTPORT:CONTAINERPORT[/tcp|udp]", s)
+	}
+	var nums [2]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port %q in %q", part, s)
+		}
+		nums[i] = n
+	}
+	*p = append(*p, portMapping{hostPort: nums[0], containerPort: nums[1], proto: proto})
+	return nil
 }
 
 // Name implements subcommands.Command.Name.
@@ -75,9 +127,11 @@ used for testing only.
 func (c *Do) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.root, "root", "/", `path to the root directory, defaults to "/"`)
 	f.StringVar(&c.cwd, "cwd", ".", "path to the current directory, defaults to the current directory")
-	f.StringVar(&c.ip, "ip", "192.168.10.2", "IPv4 address for the sandbox")
+	f.StringVar(&c.ip, "ip", "192.168.10.2", "IPv4 address for the sandbox. Can be empty if --ip6 is set")
+	f.StringVar(&c.ip6, "ip6", "", "IPv6 address for the sandbox. Can be used together with --ip for dual-stack")
 	f.BoolVar(&c.quiet, "quiet", false, "suppress runsc messages to stdout. Application output is still sent to stdout and stderr")
 	f.BoolVar(&c.overlay, "force-overlay", true, "use an overlay. WARNING: disabling gives the command write access to the host")
+	f.Var(&c.publish, "publish", "forward a host port to the sandbox, as HOSTPORT:CONTAINERPORT[/tcp|udp]. Can be repeated. Requires the sandbox network")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -128,6 +182,13 @@ func (c *Do) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) su
 
 	cid := fmt.Sprintf("runsc-%06d", rand.Int31n(1000000))
 
+	if err := c.validateIPs(); err != nil {
+		return Errorf("%v", err)
+	}
+	if len(c.publish) > 0 && (conf.Network != config.NetworkSandbox || conf.Rootless) {
+		return Errorf("--publish requires --network=sandbox and can't be used with --rootless")
+	}
+
 	if conf.Network == config.NetworkNone {
 		addNamespace(spec, specs.LinuxNamespace{Type: specs.NetworkNamespace})
 
@@ -140,6 +201,9 @@ func (c *Do) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) su
 	} else {
 		switch clean, err := c.setupNet(cid, spec); err {
 		case errNoDefaultInterface:
+			if len(c.publish) > 0 {
+				return Errorf("Error setting up network: network interface not found, ports can't be published")
+			}
 			log.Warningf("Network interface not found, using internal network")
 			addNamespace(spec, specs.LinuxNamespace{Type: specs.NetworkNamespace})
 			conf.Network = config.NetworkHost
@@ -183,42 +247,81 @@ func resolvePath(path string) (string, error) {
 	return path, nil
 }
 
+// validateIPs checks that --ip and --ip6 are addresses of the right family and
+// that at least one of them is set.
+func (c *Do) validateIPs() error {
+	if c.ip == "" && c.ip6 == "" {
+		return fmt.Errorf("at least one of --ip and --ip6 must be set")
+	}
+	if c.ip != "" {
+		if ip := net.ParseIP(c.ip); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid IPv4 address %q for --ip", c.ip)
+		}
+	}
+	if c.ip6 != "" {
+		if ip := net.ParseIP(c.ip6); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid IPv6 address %q for --ip6", c.ip6)
+		}
+	}
+	return nil
+}
+
 // setupNet setups up the sandbox network, including the creation of a network
-// namespace, and iptable rules to redirect the traffic. Returns a cleanup
-// function to tear down the network. Returns errNoDefaultInterface when there
-// is no network interface available to setup the network.
+// namespace, and iptable rules to redirect the traffic. IPv4 and IPv6 are
+// configured for --ip and --ip6 respectively, so both are set up for
+// dual-stack. Returns a cleanup function to tear down the network. Returns
+// errNoDefaultInterface when there is no network interface available to setup
+// the network.
 func (c *Do) setupNet(cid string, spec *specs.Spec) (func(), error) {
 	dev, err := defaultDevice()
 	if err != nil {
 		return nil, errNoDefaultInterface
 	}
-	peerIP, err := calculatePeerIP(c.ip)
-	if err != nil {
-		return nil, err
-	}
 	veth, peer := deviceNames(cid)
 
 	cmds := []string{
 		fmt.Sprintf("ip link add %s type veth peer name %s", veth, peer),
-
-		// Setup device outside the namespace.
-		fmt.Sprintf("ip addr add %s/24 dev %s", peerIP, peer),
 		fmt.Sprintf("ip link set %s up", peer),
-
-		// Setup device inside the namespace.
 		fmt.Sprintf("ip netns add %s", cid),
 		fmt.Sprintf("ip link set %s netns %s", veth, cid),
-		fmt.Sprintf("ip netns exec %s ip addr add %s/24 dev %s", cid, c.ip, veth),
 		fmt.Sprintf("ip netns exec %s ip link set %s up", cid, veth),
 		fmt.Sprintf("ip netns exec %s ip link set lo up", cid),
-		fmt.Sprintf("ip netns exec %s ip route add default via %s", cid, peerIP),
+	}
+	if c.ip != "" {
+		peerIP, err := calculatePeerIP(c.ip)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds,
+			// Setup device outside the namespace.
+			fmt.Sprintf("ip addr add %s/24 dev %s", peerIP, peer),
+
+			// Setup device inside the namespace.
+			fmt.Sprintf("ip netns exec %s ip addr add %s/24 dev %s", cid, c.ip, veth),
+			fmt.Sprintf("ip netns exec %s ip route add default via %s", cid, peerIP),
+
+			// Enable network access.
+			"sysctl -w net.ipv4.ip_forward=1",
+		)
+	}
+	if c.ip6 != "" {
+		peerIP6, err := calculatePeerIP(c.ip6)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds,
+			// Skip duplicate address detection, otherwise the addresses
+			// can't be used until it completes.
+			fmt.Sprintf("ip -6 addr add %s/64 dev %s nodad", peerIP6, peer),
+
+			fmt.Sprintf("ip netns exec %s ip -6 addr add %s/64 dev %s nodad", cid, c.ip6, veth),
+			fmt.Sprintf("ip netns exec %s ip -6 route add default via %s", cid, peerIP6),
 
-		// Enable network access.
-		"sysctl -w net.ipv4.ip_forward=1",
-		fmt.Sprintf("iptables -t nat -A POSTROUTING -s %s -o %s -j MASQUERADE", c.ip, dev),
-		fmt.Sprintf("iptables -A FORWARD -i %s -o %s -j ACCEPT", dev, peer),
-		fmt.Sprintf("iptables -A FORWARD -o %s -i %s -j ACCEPT", dev, peer),
+			"sysctl -w net.ipv6.conf.all.forwarding=1",
+		)
 	}
+	cmds = append(cmds, c.forwardRules("-A", dev, peer)...)
+	cmds = append(cmds, c.publishRules("-A")...)
 
 	for _, cmd := range cmds {
 		log.Debugf("Run %q", cmd)
@@ -230,7 +333,7 @@ func (c *Do) setupNet(cid string, spec *specs.Spec) (func(), error) {
 		}
 	}
 
-	resolvPath, err := makeFile("/etc/resolv.conf", "nameserver 8.8.8.8\n", spec)
+	resolvPath, err := makeFile("/etc/resolv.conf", c.resolvConf(), spec)
 	if err != nil {
 		c.cleanupNet(cid, "", "", "")
 		return nil, err
@@ -240,8 +343,7 @@ func (c *Do) setupNet(cid string, spec *specs.Spec) (func(), error) {
 		c.cleanupNet(cid, resolvPath, "", "")
 		return nil, err
 	}
-	hosts := fmt.Sprintf("127.0.0.1\tlocalhost\n%s\t%s\n", c.ip, cid)
-	hostsPath, err := makeFile("/etc/hosts", hosts, spec)
+	hostsPath, err := makeFile("/etc/hosts", c.hosts(cid), spec)
 	if err != nil {
 		c.cleanupNet(cid, resolvPath, hostnamePath, "")
 		return nil, err
@@ -256,6 +358,36 @@ func (c *Do) setupNet(cid string, spec *specs.Spec) (func(), error) {
 	return func() { c.cleanupNet(cid, resolvPath, hostnamePath, hostsPath) }, nil
 }
 
+// resolvConf returns the content of /etc/resolv.conf, with a nameserver for
+// each configured address family.
+func (c *Do) resolvConf() string {
+	var b strings.Builder
+	if c.ip != "" {
+		b.WriteString("nameserver 8.8.8.8\n")
+	}
+	if c.ip6 != "" {
+		b.WriteString("nameserver 2001:4860:4860::8888\n")
+	}
+	return b.String()
+}
+
+// hosts returns the content of /etc/hosts, mapping hostname to the configured
+// addresses.
+func (c *Do) hosts(hostname string) string {
+	var b strings.Builder
+	b.WriteString("127.0.0.1\tlocalhost\n")
+	if c.ip6 != "" {
+		b.WriteString("::1\tlocalhost ip6-localhost ip6-loopback\n")
+	}
+	if c.ip != "" {
+		fmt.Fprintf(&b, "%s\t%s\n", c.ip, hostname)
+	}
+	if c.ip6 != "" {
+		fmt.Fprintf(&b, "%s\t%s\n", c.ip6, hostname)
+	}
+	return b.String()
+}
+
 // cleanupNet tries to cleanup the network setup in setupNet.
 //
 // It may be called when setupNet is only partially complete, in which case it
@@ -270,6 +402,14 @@ func (c *Do) cleanupNet(cid, resolvPath, hostnamePath, hostsPath string) {
 		fmt.Sprintf("ip link delete %s", peer),
 		fmt.Sprintf("ip netns delete %s", cid),
 	}
+	// Rules that weren't added because setup failed early fail to be
+	// deleted, which is only logged.
+	if dev, err := defaultDevice(); err != nil {
+		log.Warningf("Failed to find the default network interface, forwarding rules are not deleted: %v", err)
+	} else {
+		cmds = append(cmds, c.forwardRules("-D", dev, peer)...)
+	}
+	cmds = append(cmds, c.publishRules("-D")...)
 
 	for _, cmd := range cmds {
 		log.Debugf("Run %q", cmd)
@@ -285,6 +425,44 @@ func (c *Do) cleanupNet(cid, resolvPath, hostnamePath, hostsPath string) {
 	tryRemove(hostsPath)
 }
 
+// forwardRules returns the iptables and ip6tables commands that add or delete,
+// depending on op, the rules forwarding and masquerading the sandbox traffic
+// between peer and dev.
+func (c *Do) forwardRules(op, dev, peer string) []string {
+	var cmds []string
+	if c.ip != "" {
+		cmds = append(cmds,
+			fmt.Sprintf("iptables -t nat %s POSTROUTING -s %s -o %s -j MASQUERADE", op, c.ip, dev),
+			fmt.Sprintf("iptables %s FORWARD -i %s -o %s -j ACCEPT", op, dev, peer),
+			fmt.Sprintf("iptables %s FORWARD -o %s -i %s -j ACCEPT", op, dev, peer),
+		)
+	}
+	if c.ip6 != "" {
+		cmds = append(cmds,
+			fmt.Sprintf("ip6tables -t nat %s POSTROUTING -s %s -o %s -j MASQUERADE", op, c.ip6, dev),
+			fmt.Sprintf("ip6tables %s FORWARD -i %s -o %s -j ACCEPT", op, dev, peer),
+			fmt.Sprintf("ip6tables %s FORWARD -o %s -i %s -j ACCEPT", op, dev, peer),
+		)
+	}
+	return cmds
+}
+
+// publishRules returns the iptables and ip6tables commands that add or delete,
+// depending on op, the DNAT rules forwarding the published ports to the
+// sandbox.
+func (c *Do) publishRules(op string) []string {
+	var cmds []string
+	for _, m := range c.publish {
+		if c.ip != "" {
+			cmds = append(cmds, fmt.Sprintf("iptables -t nat %s PREROUTING -p %s --dport %d -j DNAT --to-destination %s:%d", op, m.proto, m.hostPort, c.ip, m.containerPort))
+		}
+		if c.ip6 != "" {
+			cmds = append(cmds, fmt.Sprintf("ip6tables -t nat %s PREROUTING -p %s --dport %d -j DNAT --to-destination [%s]:%d", op, m.proto, m.hostPort, c.ip6, m.containerPort))
+		}
+	}
+	return cmds
+}
+
 func deviceNames(cid string) (string, string) {
 	// Device name is limited to 15 letters.
 	return "ve-" + cid, "vp-" + cid
@@ -333,20 +511,22 @@ func tryRemove(path string) {
 	}
 }
 
+// calculatePeerIP returns the address of the host side of the veth pair, which
+// is ip with its last byte incremented. It works for both IPv4 and IPv6.
 func calculatePeerIP(ip string) (string, error) {
-	parts := strings.Split(ip, ".")
-	if len(parts) != 4 {
+	addr := net.ParseIP(ip)
+	if addr == nil {
 		return "", fmt.Errorf("invalid IP format %q", ip)
 	}
-	n, err := strconv.Atoi(parts[3])
-	if err != nil {
-		return "", fmt.Errorf("invalid IP format %q: %v", ip, err)
+	if v4 := addr.To4(); v4 != nil {
+		addr = v4
 	}
-	n++
-	if n > 255 {
-		n = 1
+	last := len(addr) - 1
+	addr[last]++
+	if addr[last] == 0 {
+		addr[last] = 1
 	}
-	return fmt.Sprintf("%s.%s.%s.%d", parts[0], parts[1], parts[2], n), nil
+	return addr.String(), nil
 }
 
 func startContainerAndWait(spec *specs.Spec, conf *config.Config, cid string, waitStatus *unix.WaitStatus) subcommands.ExitStatus {
@@ -0,0 +1,125 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPublishFlags(t *testing.T) {
+	var p publishFlags
+	for _, s := range []string{"8080:80", "53:5353/udp", "443:443/tcp"} {
+		if err := p.Set(s); err != nil {
+			t.Fatalf("Set(%q) failed: %v", s, err)
+		}
+	}
+	want := publishFlags{
+		{hostPort: 8080, containerPort: 80, proto: "tcp"},
+		{hostPort: 53, containerPort: 5353, proto: "udp"},
+		{hostPort: 443, containerPort: 443, proto: "tcp"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("publishFlags = %+v, want: %+v", p, want)
+	}
+	if got, want := p.String(), "8080:80/tcp,53:5353/udp,443:443/tcp"; got != want {
+		t.Errorf("String() = %q, want: %q", got, want)
+	}
+
+	for _, s := range []string{"", "80", "80:", ":80", "0:80", "80:65536", "a:80", "80:80/sctp", "1:2:3"} {
+		if err := p.Set(s); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestCalculatePeerIP(t *testing.T) {
+	for _, tc := range []struct {
+		ip   string
+		want string
+	}{
+		{ip: "192.168.10.2", want: "192.168.10.3"},
+		{ip: "192.168.10.255", want: "192.168.10.1"},
+		{ip: "fd00::2", want: "fd00::3"},
+		{ip: "fd00::ff", want: "fd00::1"},
+	} {
+		got, err := calculatePeerIP(tc.ip)
+		if err != nil {
+			t.Errorf("calculatePeerIP(%q) failed: %v", tc.ip, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("calculatePeerIP(%q) = %q, want: %q", tc.ip, got, tc.want)
+		}
+	}
+	if _, err := calculatePeerIP("192.168.10"); err == nil {
+		t.Errorf("calculatePeerIP(%q) succeeded, want error", "192.168.10")
+	}
+}
+
+func TestValidateIPs(t *testing.T) {
+	for _, tc := range []struct {
+		ip, ip6 string
+		valid   bool
+	}{
+		{ip: "192.168.10.2", valid: true},
+		{ip6: "fd00::2", valid: true},
+		{ip: "192.168.10.2", ip6: "fd00::2", valid: true},
+		{},
+		{ip: "fd00::2"},
+		{ip6: "192.168.10.2"},
+		{ip: "192.168.10.2", ip6: "fd00::zz"},
+	} {
+		c := Do{ip: tc.ip, ip6: tc.ip6}
+		if err := c.validateIPs(); (err == nil) != tc.valid {
+			t.Errorf("validateIPs(ip: %q, ip6: %q) = %v, want valid: %t", tc.ip, tc.ip6, err, tc.valid)
+		}
+	}
+}
+
+func TestForwardRules(t *testing.T) {
+	c := Do{ip: "192.168.10.2", ip6: "fd00::2"}
+	add := c.forwardRules("-A", "eth0", "vp1234")
+	want := []string{
+		"iptables -t nat -A POSTROUTING -s 192.168.10.2 -o eth0 -j MASQUERADE",
+		"iptables -A FORWARD -i eth0 -o vp1234 -j ACCEPT",
+		"iptables -A FORWARD -o eth0 -i vp1234 -j ACCEPT",
+		"ip6tables -t nat -A POSTROUTING -s fd00::2 -o eth0 -j MASQUERADE",
+		"ip6tables -A FORWARD -i eth0 -o vp1234 -j ACCEPT",
+		"ip6tables -A FORWARD -o eth0 -i vp1234 -j ACCEPT",
+	}
+	if !reflect.DeepEqual(add, want) {
+		t.Errorf("forwardRules(-A) = %q, want: %q", add, want)
+	}
+
+	// Every rule added is deleted.
+	del := c.forwardRules("-D", "eth0", "vp1234")
+	if len(del) != len(add) {
+		t.Fatalf("forwardRules(-D) = %q, want as many rules as %q", del, add)
+	}
+	for i := range add {
+		if got, want := del[i], strings.Replace(add[i], " -A ", " -D ", 1); got != want {
+			t.Errorf("forwardRules(-D)[%d] = %q, want: %q", i, got, want)
+		}
+	}
+
+	c = Do{ip6: "fd00::2"}
+	for _, rule := range c.forwardRules("-D", "eth0", "vp1234") {
+		if !strings.HasPrefix(rule, "ip6tables ") {
+			t.Errorf("forwardRules(-D) with only --ip6 contains %q", rule)
+		}
+	}
+}
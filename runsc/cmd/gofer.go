@@ -25,6 +25,7 @@ import (
 	"github.com/google/subcommands"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/p9"
 	"gvisor.dev/gvisor/pkg/sync"
@@ -61,8 +62,9 @@ type Gofer struct {
 	applyCaps bool
 	setUpRoot bool
 
-	specFD   int
-	mountsFD int
+	specFD       int
+	mountsFD     int
+	controllerFD int
 }
 
 // Name implements subcommands.Command.
@@ -88,6 +90,7 @@ func (g *Gofer) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&g.setUpRoot, "setup-root", true, "if true, set up an empty root for the process")
 	f.IntVar(&g.specFD, "spec-fd", -1, "required fd with the container spec")
 	f.IntVar(&g.mountsFD, "mounts-fd", -1, "mountsFD is the file descriptor to write list of mounts after they have been resolved (direct paths, no symlinks).")
+	f.IntVar(&g.controllerFD, "controller-fd", -1, "optional FD of a stream socket for the control server that must be donated to this process")
 }
 
 // Execute implements subcommands.Command.
@@ -99,6 +102,11 @@ func (g *Gofer) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 
 	conf := args[0].(*config.Config)
 
+	if conf.AppArmorProfile != "" && !specutils.InAppArmorProfile(conf.AppArmorProfile) {
+		Fatalf("callSelfWithAppArmorProfile(%q): %v", conf.AppArmorProfile, callSelfWithAppArmorProfile(conf.AppArmorProfile))
+		panic("unreachable")
+	}
+
 	specFile := os.NewFile(uintptr(g.specFD), "spec file")
 	defer specFile.Close()
 	spec, err := specutils.ReadSpecFromFile(g.bundleDir, specFile, conf)
@@ -205,6 +213,18 @@ func (g *Gofer) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		filter.InstallXattrFilters()
 	}
 
+	if g.controllerFD >= 0 {
+		srv, err := server.CreateFromFD(g.controllerFD)
+		if err != nil {
+			Fatalf("creating control server: %v", err)
+		}
+		srv.Register(&fsgofer.Controller{})
+		if err := srv.StartServing(); err != nil {
+			Fatalf("starting control server: %v", err)
+		}
+		filter.InstallControlServerFilters(g.controllerFD)
+	}
+
 	if err := filter.Install(); err != nil {
 		Fatalf("installing seccomp filters: %v", err)
 	}
@@ -377,7 +397,11 @@ func setupMounts(conf *config.Config, mounts []specs.Mount, root, procPath strin
 		}
 
 		// Set propagation options that cannot be set together with other options.
+		// The spec options take precedence over --mount-propagation-default.
 		flags = specutils.PropOptionsToFlags(m.Options)
+		if flags == 0 && conf.MountPropagationDefault != "" {
+			flags = specutils.PropOptionsToFlags([]string{conf.MountPropagationDefault})
+		}
 		if flags != 0 {
 			if err := specutils.SafeMount("", dst, "", uintptr(flags), "", procPath); err != nil {
 				return fmt.Errorf("mount dst: %q, flags: %#x, err: %v", dst, flags, err)
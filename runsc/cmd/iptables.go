@@ -0,0 +1,106 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// IPTables implements subcommands.Command for the "iptables" command.
+type IPTables struct {
+	table string
+	ipv6  bool
+	apply string
+}
+
+// Name implements subcommands.Command.Name.
+func (*IPTables) Name() string {
+	return "iptables"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*IPTables) Synopsis() string {
+	return "dump or replace the iptables rules of a running sandbox"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*IPTables) Usage() string {
+	return `iptables [flags] <container id> - dump or replace the iptables rules of the sandbox network stack.
+
+Rules are exchanged in the binary format that the iptables tool passes to
+setsockopt(IPT_SO_SET_REPLACE), one table at a time. Without -apply, the
+table selected by -table is written to stdout. With -apply, the table named in
+the given file is replaced.
+
+Only the filter and nat tables can be replaced.
+
+OPTIONS:
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (i *IPTables) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&i.table, "table", "filter", "table to dump.")
+	f.BoolVar(&i.ipv6, "6", false, "use the ip6tables rules instead of the iptables ones.")
+	f.StringVar(&i.apply, "apply", "", "path to a file with the rules to apply, or - for stdin.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (i *IPTables) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	cont, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		Fatalf("loading container: %v", err)
+	}
+
+	if i.apply == "" {
+		replace, err := cont.IPTables(i.table, i.ipv6)
+		if err != nil {
+			Fatalf("getting iptables: %v", err)
+		}
+		if _, err := os.Stdout.Write(replace); err != nil {
+			Fatalf("writing iptables: %v", err)
+		}
+		return subcommands.ExitSuccess
+	}
+
+	var replace []byte
+	if i.apply == "-" {
+		replace, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		replace, err = ioutil.ReadFile(i.apply)
+	}
+	if err != nil {
+		Fatalf("reading rules: %v", err)
+	}
+	if err := cont.SetIPTables(replace, i.ipv6); err != nil {
+		Fatalf("setting iptables: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
@@ -28,6 +28,7 @@ import (
 // PS implements subcommands.Command for the "ps" command.
 type PS struct {
 	format string
+	tree   bool
 }
 
 // Name implements subcommands.Command.Name.
@@ -48,6 +49,7 @@ func (*PS) Usage() string {
 // SetFlags implements subcommands.Command.SetFlags.
 func (ps *PS) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&ps.format, "format", "table", "output format. Select one of: table or json (default: table)")
+	f.BoolVar(&ps.tree, "tree", false, "show processes under their parent. With --format=json, prints the processes as nested objects instead of a list of PIDs")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -64,6 +66,10 @@ func (ps *PS) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{})
 	if err != nil {
 		Fatalf("loading sandbox: %v", err)
 	}
+	if ps.tree {
+		ps.printTree(c)
+		return subcommands.ExitSuccess
+	}
 	pList, err := c.Processes()
 	if err != nil {
 		Fatalf("getting processes for container: %v", err)
@@ -84,3 +90,23 @@ func (ps *PS) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{})
 
 	return subcommands.ExitSuccess
 }
+
+// printTree prints the process tree of c in the requested format.
+func (ps *PS) printTree(c *container.Container) {
+	pt, err := c.ListProcessesTree()
+	if err != nil {
+		Fatalf("getting processes for container: %v", err)
+	}
+	switch ps.format {
+	case "table":
+		fmt.Println(control.ProcessTreeToTable(pt))
+	case "json":
+		o, err := control.ProcessTreeToJSON(pt)
+		if err != nil {
+			Fatalf("generating JSON: %v", err)
+		}
+		fmt.Println(o)
+	default:
+		Fatalf("unsupported format: %s", ps.format)
+	}
+}
@@ -16,7 +16,10 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/google/subcommands"
 	"golang.org/x/sys/unix"
@@ -31,7 +34,8 @@ type Restore struct {
 	// Restore flags are a super-set of those for Create.
 	Create
 
-	// imagePath is the path to the saved container image
+	// imagePath is the path to the saved container image, or "-" or
+	// "fd://N" to read the state file from stdin or FD N.
 	imagePath string
 
 	// detach indicates that runsc has to start a process and exit without waiting it.
@@ -50,14 +54,19 @@ func (*Restore) Synopsis() string {
 
 // Usage implements subcommands.Command.Usage.
 func (*Restore) Usage() string {
-	return `restore [flags] <container id> - restore saved state of container.
+	return `restore [flags] <container id> - restore saved state of container. The
+container ID doesn't need to match the ID of the checkpointed container.
+
+The state file can be streamed instead of read from the image directory, by
+setting --image-path to "-" to read it from stdin, or to "fd://N" to read it
+from file descriptor N. With "-", stdin must not be used by the container.
 `
 }
 
 // SetFlags implements subcommands.Command.SetFlags.
 func (r *Restore) SetFlags(f *flag.FlagSet) {
 	r.Create.SetFlags(f)
-	f.StringVar(&r.imagePath, "image-path", "", "directory path to saved container image")
+	f.StringVar(&r.imagePath, "image-path", "", `directory path to saved container image, or "-" or "fd://N" to read the state file from stdin or file descriptor N`)
 	f.BoolVar(&r.detach, "detach", false, "detach from the container's process")
 
 	// Unimplemented flags necessary for compatibility with docker.
@@ -98,7 +107,10 @@ func (r *Restore) Execute(_ context.Context, f *flag.FlagSet, args ...interface{
 		return Errorf("image-path flag must be provided")
 	}
 
-	conf.RestoreFile = filepath.Join(r.imagePath, checkpointFileName)
+	conf.RestoreFile, err = restoreFilePath(r.imagePath)
+	if err != nil {
+		return Errorf("%v", err)
+	}
 
 	runArgs := container.Args{
 		ID:            id,
@@ -117,3 +129,20 @@ func (r *Restore) Execute(_ context.Context, f *flag.FlagSet, args ...interface{
 
 	return subcommands.ExitSuccess
 }
+
+// restoreFilePath returns the path of the state file to restore for
+// imagePath. "-" and "fd://N" are mapped to paths that reopen stdin and FD N,
+// so that the state file can be read from a pipe without staging it on disk.
+func restoreFilePath(imagePath string) (string, error) {
+	if imagePath == "-" {
+		return "/dev/stdin", nil
+	}
+	if strings.HasPrefix(imagePath, "fd://") {
+		fd, err := strconv.Atoi(strings.TrimPrefix(imagePath, "fd://"))
+		if err != nil || fd < 0 {
+			return "", fmt.Errorf("invalid image path %q, must be fd://N with N a file descriptor", imagePath)
+		}
+		return fmt.Sprintf("/proc/self/fd/%d", fd), nil
+	}
+	return filepath.Join(imagePath, checkpointFileName), nil
+}
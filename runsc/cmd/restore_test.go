@@ -0,0 +1,44 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestRestoreFilePath(t *testing.T) {
+	for _, tc := range []struct {
+		imagePath string
+		want      string
+	}{
+		{imagePath: "/tmp/image", want: "/tmp/image/" + checkpointFileName},
+		{imagePath: "-", want: "/dev/stdin"},
+		{imagePath: "fd://3", want: "/proc/self/fd/3"},
+	} {
+		got, err := restoreFilePath(tc.imagePath)
+		if err != nil {
+			t.Errorf("restoreFilePath(%q) failed: %v", tc.imagePath, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("restoreFilePath(%q) = %q, want: %q", tc.imagePath, got, tc.want)
+		}
+	}
+	for _, imagePath := range []string{"fd://", "fd://-1", "fd://x"} {
+		if _, err := restoreFilePath(imagePath); err == nil {
+			t.Errorf("restoreFilePath(%q) succeeded, want error", imagePath)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/flag"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// SpecCheck implements subcommands.Command for the "spec-check" command.
+type SpecCheck struct{}
+
+// Name implements subcommands.Command.Name.
+func (*SpecCheck) Name() string {
+	return "spec-check"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*SpecCheck) Synopsis() string {
+	return "report OCI spec fields of a bundle that runsc doesn't fully support"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*SpecCheck) Usage() string {
+	return `spec-check [flags] <bundle> - check the bundle's config.json against the
+features supported by runsc, with the given flags.
+
+Each field that runsc doesn't fully support is reported as one of:
+  unsupported: the container will fail to start.
+  partial:     the field is only partially honored.
+  ignored:     the field has no effect.
+
+The command fails if the spec is invalid or has unsupported fields.
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (*SpecCheck) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.Execute.
+func (*SpecCheck) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	bundleDir := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	spec, err := specutils.ReadSpec(bundleDir, conf)
+	if err != nil {
+		Fatalf("reading spec: %v", err)
+	}
+
+	issues := boot.CheckSpec(spec, conf)
+	if len(issues) == 0 {
+		fmt.Println("All spec fields are supported.")
+		return subcommands.ExitSuccess
+	}
+
+	status := subcommands.ExitSuccess
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "SUPPORT\tFIELD\tREASON\n")
+	for _, issue := range issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", issue.Support, issue.Field, issue.Reason)
+		if issue.Support == boot.SpecUnsupported {
+			status = subcommands.ExitFailure
+		}
+	}
+	_ = w.Flush()
+	return status
+}
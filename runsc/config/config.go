@@ -19,7 +19,13 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"gvisor.dev/gvisor/pkg/refs"
 	"gvisor.dev/gvisor/pkg/sentry/watchdog"
 )
@@ -61,6 +67,14 @@ type Config struct {
 	// DebugLogFormat is the log format for debug.
 	DebugLogFormat string `flag:"debug-log-format"`
 
+	// LogRotateSize is the size in bytes at which the sandbox and gofer debug
+	// logs are rotated. Zero disables rotation.
+	LogRotateSize int `flag:"log-rotate-size"`
+
+	// LogRotateCount is the number of rotated debug log files to keep in
+	// addition to the current one. Only used if LogRotateSize is set.
+	LogRotateCount int `flag:"log-rotate-count"`
+
 	// FileAccess indicates how the root filesystem is accessed.
 	FileAccess FileAccessType `flag:"file-access"`
 
@@ -76,6 +90,17 @@ type Config struct {
 	// FSGoferHostUDS enables the gofer to mount a host UDS.
 	FSGoferHostUDS bool `flag:"fsgofer-host-uds"`
 
+	// GoferHealthCheck starts a control server in gofer processes, which
+	// Container.GoferHealthy uses to check that the gofer serves 9P
+	// requests.
+	GoferHealthCheck bool `flag:"gofer-health-check"`
+
+	// MountPropagationDefault is the propagation set by the gofer on bind
+	// mounts whose spec options don't set one. Empty leaves the propagation
+	// inherited from the root mount, which is rslave unless the spec sets
+	// rootfsPropagation.
+	MountPropagationDefault string `flag:"mount-propagation-default"`
+
 	// Network indicates what type of network to use.
 	Network NetworkType `flag:"network"`
 
@@ -100,6 +125,23 @@ type Config struct {
 	// for non-loopback interfaces.
 	QDisc QueueingDiscipline `flag:"qdisc"`
 
+	// NetMTU overrides the MTU of the sandbox network interfaces, which is
+	// otherwise inherited from the host interfaces. Zero means no override.
+	// With GSO enabled, segments are still sized based on this MTU.
+	NetMTU int `flag:"net-mtu"`
+
+	// NetPollMode controls how network channel goroutines wait for inbound
+	// packets.
+	NetPollMode NetPollMode `flag:"net-poll-mode"`
+
+	// NetTransparent makes the sandbox network interfaces accept packets
+	// destined to, and send packets from, non-local addresses, so that
+	// applications can transparently proxy traffic (TPROXY).
+	//
+	// Since spoofing is enabled, the sandbox can send packets with any
+	// source address. Anti-spoofing must be done outside the sandbox.
+	NetTransparent bool `flag:"net-transparent"`
+
 	// LogPackets indicates that all network packets should be logged.
 	LogPackets bool `flag:"log-packets"`
 
@@ -170,9 +212,66 @@ type Config struct {
 	// Enables seccomp inside the sandbox.
 	OCISeccomp bool `flag:"oci-seccomp"`
 
+	// SyscallAllowList is a comma-separated list of syscall names or numbers
+	// that the application may call. Other syscalls fail with ENOSYS in the
+	// sentry. If empty, all syscalls are allowed.
+	SyscallAllowList string `flag:"syscall-allow-list"`
+
+	// EnvPassthrough is a comma-separated list of environment variables that
+	// are copied from runsc's environment into the environment of container
+	// processes, unless the spec defines them already.
+	EnvPassthrough string `flag:"env-passthrough"`
+
+	// AppArmorProfile is the name of a host AppArmor profile that the sandbox
+	// and gofer processes are confined to. Empty means no profile is applied.
+	AppArmorProfile string `flag:"apparmor-profile"`
+
 	// Mounts the cgroup filesystem backed by the sentry's cgroupfs.
 	Cgroupfs bool `flag:"cgroupfs"`
 
+	// ForkRateLimit is the maximum number of processes that can be created
+	// per second inside the sandbox. Zero means no limit.
+	ForkRateLimit int `flag:"fork-rate-limit"`
+
+	// StartupTimeout is the maximum amount of time to wait for the sandbox to
+	// boot, and then separately for it to start or restore the root
+	// container, before giving up and destroying it. Zero means no timeout.
+	StartupTimeout time.Duration `flag:"startup-timeout"`
+
+	// IdleGCInterval is how often the sentry returns unused memory to the host
+	// while the sandbox is idle. Zero disables it.
+	IdleGCInterval time.Duration `flag:"idle-gc-interval"`
+
+	// PageCacheLimit is the maximum size in bytes of file data that the
+	// sentry keeps cached after it is no longer mapped by the application.
+	// Zero means no limit.
+	PageCacheLimit uint `flag:"pagecache-limit"`
+
+	// MaxPTYs is the maximum number of PTYs that can be allocated in the
+	// sandbox, like /proc/sys/kernel/pty/max. Zero uses the devpts default.
+	MaxPTYs uint `flag:"max-ptys"`
+
+	// CPUAffinity is a list of host CPUs that the sandbox process is pinned
+	// to, e.g. "0-3,8". See ParseCPUList for the format. Empty means no
+	// pinning.
+	CPUAffinity string `flag:"cpu-affinity"`
+
+	// RestoreEnvOverride is a comma-separated list of settings that are taken
+	// from the spec instead of the checkpoint when restoring a container. Only
+	// settings that don't affect the consistency of the restored state are
+	// allowed, see RestoreEnvOverrides.
+	RestoreEnvOverride string `flag:"restore-env-override"`
+
+	// DefaultUlimits is a comma-separated list of resource limits applied to
+	// the processes of every container, unless the spec sets them, e.g.
+	// "nofile=1024:2048". See ParseUlimits for the format.
+	DefaultUlimits string `flag:"default-ulimits"`
+
+	// GoferOOMScoreAdj is the oom_score_adj set on gofer processes, between
+	// -1000 and 1000. Empty means that each gofer gets the oom_score_adj of
+	// its container's process. See GoferOOMScoreAdjOverride.
+	GoferOOMScoreAdj string `flag:"oom-score-adj-gofer"`
+
 	// TestOnlyAllowRunAsCurrentUserWithoutChroot should only be used in
 	// tests. It allows runsc to start the sandbox process as the current
 	// user, and without chrooting the sandbox process. This can be
@@ -188,6 +287,23 @@ type Config struct {
 	TestOnlyTestNameEnv string `flag:"TESTONLY-test-name-env"`
 }
 
+const (
+	// minNetMTU is the smallest MTU allowed for --net-mtu. It's the minimum
+	// IPv6 MTU, which is larger than the IPv4 one.
+	minNetMTU = 1280
+
+	// maxNetMTU is the largest MTU allowed for --net-mtu.
+	maxNetMTU = 65535
+
+	// minOOMScoreAdj and maxOOMScoreAdj are the bounds of oom_score_adj.
+	minOOMScoreAdj = -1000
+	maxOOMScoreAdj = 1000
+
+	// maxCPUs bounds the CPU indices accepted by ParseCPUList. It matches the
+	// largest CONFIG_NR_CPUS supported by Linux.
+	maxCPUs = 8192
+)
+
 func (c *Config) validate() error {
 	if c.FileAccess == FileAccessShared && c.Overlay {
 		return fmt.Errorf("overlay flag is incompatible with shared file access")
@@ -195,9 +311,214 @@ func (c *Config) validate() error {
 	if c.NumNetworkChannels <= 0 {
 		return fmt.Errorf("num_network_channels must be > 0, got: %d", c.NumNetworkChannels)
 	}
+	if c.LogRotateSize < 0 {
+		return fmt.Errorf("log_rotate_size must be >= 0, got: %d", c.LogRotateSize)
+	}
+	if c.LogRotateSize > 0 && c.LogRotateCount <= 0 {
+		return fmt.Errorf("log_rotate_count must be > 0 when log_rotate_size is set, got: %d", c.LogRotateCount)
+	}
+	if c.StartupTimeout < 0 {
+		return fmt.Errorf("startup_timeout must be >= 0, got: %v", c.StartupTimeout)
+	}
+	if c.IdleGCInterval < 0 {
+		return fmt.Errorf("idle_gc_interval must be >= 0, got: %v", c.IdleGCInterval)
+	}
+	if c.NetMTU != 0 && (c.NetMTU < minNetMTU || c.NetMTU > maxNetMTU) {
+		return fmt.Errorf("net_mtu must be 0 or between %d and %d, got: %d", minNetMTU, maxNetMTU, c.NetMTU)
+	}
+	if c.MaxPTYs > math.MaxUint32 {
+		return fmt.Errorf("max_ptys must be <= %d, got: %d", uint32(math.MaxUint32), c.MaxPTYs)
+	}
+	if c.ForkRateLimit < 0 {
+		return fmt.Errorf("fork_rate_limit must be >= 0, got: %d", c.ForkRateLimit)
+	}
+	if c.CPUAffinity != "" {
+		if _, err := ParseCPUList(c.CPUAffinity); err != nil {
+			return fmt.Errorf("invalid cpu_affinity: %v", err)
+		}
+	}
+	if c.RestoreEnvOverride != "" {
+		for _, name := range strings.Split(c.RestoreEnvOverride, ",") {
+			if _, ok := RestoreEnvOverrides[name]; !ok {
+				return fmt.Errorf("invalid restore_env_override: setting %q can't be overridden on restore", name)
+			}
+		}
+	}
+	if c.SyscallAllowList != "" {
+		for _, sc := range strings.Split(c.SyscallAllowList, ",") {
+			if sc == "" {
+				return fmt.Errorf("invalid syscall_allow_list: empty syscall in %q", c.SyscallAllowList)
+			}
+		}
+	}
+	if c.DefaultUlimits != "" {
+		if _, err := ParseUlimits(c.DefaultUlimits); err != nil {
+			return fmt.Errorf("invalid default_ulimits: %v", err)
+		}
+	}
+	if c.MountPropagationDefault != "" && !mountPropagationModes[c.MountPropagationDefault] {
+		return fmt.Errorf("mount_propagation_default must be one of private, rprivate, slave or rslave, got: %q", c.MountPropagationDefault)
+	}
+	if c.GoferOOMScoreAdj != "" {
+		score, err := strconv.Atoi(c.GoferOOMScoreAdj)
+		if err != nil || score < minOOMScoreAdj || score > maxOOMScoreAdj {
+			return fmt.Errorf("oom_score_adj_gofer must be between %d and %d, got: %q", minOOMScoreAdj, maxOOMScoreAdj, c.GoferOOMScoreAdj)
+		}
+	}
 	return nil
 }
 
+// GoferOOMScoreAdjOverride returns the oom_score_adj to set on gofer
+// processes, or false if they inherit it from their container.
+func (c *Config) GoferOOMScoreAdjOverride() (int, bool) {
+	if c.GoferOOMScoreAdj == "" {
+		return 0, false
+	}
+	// Validated in validate().
+	score, _ := strconv.Atoi(c.GoferOOMScoreAdj)
+	return score, true
+}
+
+// mountPropagationModes are the values accepted by --mount-propagation-default.
+// Shared propagation isn't allowed, since mount changes in the sandbox must
+// not propagate to the host.
+var mountPropagationModes = map[string]bool{
+	"private":  true,
+	"rprivate": true,
+	"slave":    true,
+	"rslave":   true,
+}
+
+// ulimitResources maps the resource names accepted by ParseUlimits, which are
+// the ones used by 'docker run --ulimit', to their names in the OCI spec.
+var ulimitResources = map[string]string{
+	"as":         "RLIMIT_AS",
+	"core":       "RLIMIT_CORE",
+	"cpu":        "RLIMIT_CPU",
+	"data":       "RLIMIT_DATA",
+	"fsize":      "RLIMIT_FSIZE",
+	"locks":      "RLIMIT_LOCKS",
+	"memlock":    "RLIMIT_MEMLOCK",
+	"msgqueue":   "RLIMIT_MSGQUEUE",
+	"nice":       "RLIMIT_NICE",
+	"nofile":     "RLIMIT_NOFILE",
+	"nproc":      "RLIMIT_NPROC",
+	"rss":        "RLIMIT_RSS",
+	"rtprio":     "RLIMIT_RTPRIO",
+	"rttime":     "RLIMIT_RTTIME",
+	"sigpending": "RLIMIT_SIGPENDING",
+	"stack":      "RLIMIT_STACK",
+}
+
+// ParseUlimits parses a comma-separated list of resource limits in the form
+// name=soft[:hard], e.g. "nofile=1024:2048,core=0". name is a resource as
+// accepted by 'docker run --ulimit'. The hard limit defaults to the soft one.
+// Limits are decimal numbers, or "unlimited" or -1 for no limit. The limits
+// are returned in the form used by the OCI spec, in the order given.
+func ParseUlimits(s string) ([]specs.POSIXRlimit, error) {
+	var rls []specs.POSIXRlimit
+	seen := make(map[string]struct{})
+	for _, item := range strings.Split(s, ",") {
+		name, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			name, value = item[:i], item[i+1:]
+		}
+		typ, ok := ulimitResources[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown resource %q in %q", name, item)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("resource %q is set more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		softStr, hardStr := value, value
+		if i := strings.IndexByte(value, ':'); i >= 0 {
+			softStr, hardStr = value[:i], value[i+1:]
+		}
+		soft, err := parseUlimit(softStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid soft limit in %q: %v", item, err)
+		}
+		hard, err := parseUlimit(hardStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hard limit in %q: %v", item, err)
+		}
+		if soft > hard {
+			return nil, fmt.Errorf("soft limit is greater than the hard limit in %q", item)
+		}
+		rls = append(rls, specs.POSIXRlimit{Type: typ, Soft: soft, Hard: hard})
+	}
+	return rls, nil
+}
+
+// parseUlimit parses a single limit for ParseUlimits.
+func parseUlimit(s string) (uint64, error) {
+	if s == "unlimited" || s == "-1" {
+		return math.MaxUint64, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// RestoreOverrideHostname takes the hostname and domain name of the sandbox
+// from the spec when restoring. Processes that use their own UTS namespace keep
+// the names from the checkpoint.
+const RestoreOverrideHostname = "hostname"
+
+// RestoreEnvOverrides are the settings that can be listed in
+// --restore-env-override.
+var RestoreEnvOverrides = map[string]struct{}{
+	RestoreOverrideHostname: {},
+}
+
+// IsRestoreEnvOverridden returns true if setting name is taken from the spec
+// instead of the checkpoint when restoring.
+func (c *Config) IsRestoreEnvOverridden(name string) bool {
+	if c.RestoreEnvOverride == "" {
+		return false
+	}
+	for _, n := range strings.Split(c.RestoreEnvOverride, ",") {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCPUList parses a comma-separated list of CPU indices and inclusive
+// ranges, e.g. "0-3,8", in the format used by cpusets and
+// /sys/devices/system/cpu/online. It returns the sorted list of distinct
+// CPUs, which is never empty.
+func ParseCPUList(s string) ([]int, error) {
+	seen := make(map[int]struct{})
+	for _, item := range strings.Split(strings.TrimSpace(s), ",") {
+		lo, hi := item, item
+		if i := strings.IndexByte(item, '-'); i >= 0 {
+			lo, hi = item[:i], item[i+1:]
+		}
+		first, err := strconv.ParseUint(lo, 10, 32)
+		if err != nil || first >= maxCPUs {
+			return nil, fmt.Errorf("invalid CPU %q in %q", lo, s)
+		}
+		last, err := strconv.ParseUint(hi, 10, 32)
+		if err != nil || last >= maxCPUs {
+			return nil, fmt.Errorf("invalid CPU %q in %q", hi, s)
+		}
+		if first > last {
+			return nil, fmt.Errorf("invalid CPU range %q in %q", item, s)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			seen[int(cpu)] = struct{}{}
+		}
+	}
+	cpus := make([]int, 0, len(seen))
+	for cpu := range seen {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
 // FileAccessType tells how the filesystem is accessed.
 type FileAccessType int
 
@@ -347,6 +668,52 @@ func (q QueueingDiscipline) String() string {
 	panic(fmt.Sprintf("Invalid qdisc %d", q))
 }
 
+// NetPollMode is used to specify how network channels wait for inbound
+// packets.
+type NetPollMode int
+
+const (
+	// NetPollBlock blocks in poll() until packets are available.
+	NetPollBlock NetPollMode = iota
+
+	// NetPollBusy spins until packets are available. This lowers receive
+	// latency, but keeps one host CPU busy per network channel at all times.
+	NetPollBusy
+)
+
+func netPollModePtr(v NetPollMode) *NetPollMode {
+	return &v
+}
+
+// Set implements flag.Value.
+func (m *NetPollMode) Set(v string) error {
+	switch v {
+	case "block":
+		*m = NetPollBlock
+	case "busy":
+		*m = NetPollBusy
+	default:
+		return fmt.Errorf("invalid net poll mode %q", v)
+	}
+	return nil
+}
+
+// Get implements flag.Value.
+func (m *NetPollMode) Get() interface{} {
+	return *m
+}
+
+// String implements flag.Value.
+func (m NetPollMode) String() string {
+	switch m {
+	case NetPollBlock:
+		return "block"
+	case NetPollBusy:
+		return "busy"
+	}
+	panic(fmt.Sprintf("Invalid net poll mode %d", m))
+}
+
 func leakModePtr(v refs.LeakMode) *refs.LeakMode {
 	return &v
 }
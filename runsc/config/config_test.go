@@ -15,9 +15,12 @@
 package config
 
 import (
+	"math"
+	"reflect"
 	"strings"
 	"testing"
 
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"gvisor.dev/gvisor/runsc/flag"
 )
 
@@ -146,6 +149,10 @@ func TestInvalidFlags(t *testing.T) {
 			name:  "qdisc",
 			error: "invalid qdisc",
 		},
+		{
+			name:  "net-poll-mode",
+			error: "invalid net poll mode",
+		},
 		{
 			name:  "watchdog-action",
 			error: "invalid watchdog action",
@@ -185,6 +192,98 @@ func TestValidationFail(t *testing.T) {
 			},
 			error: "num_network_channels must be > 0",
 		},
+		{
+			name: "fork-rate-limit",
+			flags: map[string]string{
+				"fork-rate-limit": "-1",
+			},
+			error: "fork_rate_limit must be >= 0",
+		},
+		{
+			name: "net-mtu",
+			flags: map[string]string{
+				"net-mtu": "100",
+			},
+			error: "net_mtu must be 0 or between",
+		},
+		{
+			name: "max-ptys",
+			flags: map[string]string{
+				"max-ptys": "4294967296",
+			},
+			error: "max_ptys must be <=",
+		},
+		{
+			name: "syscall-allow-list",
+			flags: map[string]string{
+				"syscall-allow-list": "read,,write",
+			},
+			error: "invalid syscall_allow_list",
+		},
+		{
+			name: "startup-timeout",
+			flags: map[string]string{
+				"startup-timeout": "-1s",
+			},
+			error: "startup_timeout must be >= 0",
+		},
+		{
+			name: "idle-gc-interval",
+			flags: map[string]string{
+				"idle-gc-interval": "-1s",
+			},
+			error: "idle_gc_interval must be >= 0",
+		},
+		{
+			name: "log-rotate-size",
+			flags: map[string]string{
+				"log-rotate-size": "-1",
+			},
+			error: "log_rotate_size must be >= 0",
+		},
+		{
+			name: "log-rotate-count",
+			flags: map[string]string{
+				"log-rotate-size":  "1024",
+				"log-rotate-count": "0",
+			},
+			error: "log_rotate_count must be > 0",
+		},
+		{
+			name: "cpu-affinity",
+			flags: map[string]string{
+				"cpu-affinity": "3-1",
+			},
+			error: "invalid cpu_affinity",
+		},
+		{
+			name: "restore-env-override",
+			flags: map[string]string{
+				"restore-env-override": "hostname,network",
+			},
+			error: "invalid restore_env_override",
+		},
+		{
+			name: "default-ulimits",
+			flags: map[string]string{
+				"default-ulimits": "nofile=2048:1024",
+			},
+			error: "invalid default_ulimits",
+		},
+		{
+			name: "mount-propagation-default",
+			flags: map[string]string{
+				"mount-propagation-default": "rshared",
+			},
+			error: "mount_propagation_default must be one of",
+		},
+		{
+			name: "oom-score-adj-gofer",
+			flags: map[string]string{
+				"oom-score-adj-gofer": "1001",
+			},
+			error: "oom_score_adj_gofer must be between",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			for name, val := range tc.flags {
@@ -286,3 +385,77 @@ func TestOverrideError(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCPUList(t *testing.T) {
+	for _, tc := range []struct {
+		list string
+		want []int
+	}{
+		{list: "0", want: []int{0}},
+		{list: "0-3,8", want: []int{0, 1, 2, 3, 8}},
+		{list: "8,2-3,3", want: []int{2, 3, 8}},
+		{list: "0-1\n", want: []int{0, 1}},
+	} {
+		t.Run(tc.list, func(t *testing.T) {
+			got, err := ParseCPUList(tc.list)
+			if err != nil {
+				t.Fatalf("ParseCPUList(%q): %v", tc.list, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseCPUList(%q) = %v, want: %v", tc.list, got, tc.want)
+			}
+		})
+	}
+
+	for _, list := range []string{"", ",", "a", "-1", "1-", "3-1", "0,,1", "100000"} {
+		t.Run(list, func(t *testing.T) {
+			if got, err := ParseCPUList(list); err == nil {
+				t.Errorf("ParseCPUList(%q) = %v, want error", list, got)
+			}
+		})
+	}
+}
+
+func TestParseUlimits(t *testing.T) {
+	for _, tc := range []struct {
+		ulimits string
+		want    []specs.POSIXRlimit
+	}{
+		{
+			ulimits: "nofile=1024:2048",
+			want:    []specs.POSIXRlimit{{Type: "RLIMIT_NOFILE", Soft: 1024, Hard: 2048}},
+		},
+		{
+			ulimits: "nproc=100,core=0",
+			want: []specs.POSIXRlimit{
+				{Type: "RLIMIT_NPROC", Soft: 100, Hard: 100},
+				{Type: "RLIMIT_CORE", Soft: 0, Hard: 0},
+			},
+		},
+		{
+			ulimits: "stack=8388608:unlimited,memlock=-1",
+			want: []specs.POSIXRlimit{
+				{Type: "RLIMIT_STACK", Soft: 8388608, Hard: math.MaxUint64},
+				{Type: "RLIMIT_MEMLOCK", Soft: math.MaxUint64, Hard: math.MaxUint64},
+			},
+		},
+	} {
+		t.Run(tc.ulimits, func(t *testing.T) {
+			got, err := ParseUlimits(tc.ulimits)
+			if err != nil {
+				t.Fatalf("ParseUlimits(%q): %v", tc.ulimits, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseUlimits(%q) = %+v, want: %+v", tc.ulimits, got, tc.want)
+			}
+		})
+	}
+
+	for _, ulimits := range []string{"", "nofile", "files=1", "nofile=a", "nofile=2:1", "nofile=1:2:3", "nofile=1,nofile=2", "nofile=1,"} {
+		t.Run(ulimits, func(t *testing.T) {
+			if got, err := ParseUlimits(ulimits); err == nil {
+				t.Errorf("ParseUlimits(%q) = %+v, want error", ulimits, got)
+			}
+		})
+	}
+}
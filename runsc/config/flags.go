@@ -48,6 +48,8 @@ func RegisterFlags() {
 		flag.String("coverage-report", "", "file path where Go coverage reports are written. Reports will only be generated if runsc is built with --collect_code_coverage and --instrumentation_filter Bazel flags.")
 		flag.Bool("log-packets", false, "enable network packet logging.")
 		flag.String("debug-log-format", "text", "log format: text (default), json, or json-k8s.")
+		flag.Int("log-rotate-size", 0, "size in bytes at which the sandbox and gofer debug logs are rotated. 0 disables rotation.")
+		flag.Int("log-rotate-count", 3, "number of rotated debug log files to keep when --log-rotate-size is set.")
 		flag.Bool("alsologtostderr", false, "send log messages to stderr.")
 		flag.Bool("allow-flag-override", false, "allow OCI annotations (dev.gvisor.flag.<name>) to override flags for debugging.")
 		flag.String("traceback", "system", "golang runtime's traceback level")
@@ -65,7 +67,19 @@ func RegisterFlags() {
 		flag.Bool("rootless", false, "it allows the sandbox to be started with a user that is not root. Sandbox and Gofer processes may run with same privileges as current user.")
 		flag.Var(leakModePtr(refs.NoLeakChecking), "ref-leak-mode", "sets reference leak check mode: disabled (default), log-names, log-traces.")
 		flag.Bool("cpu-num-from-quota", false, "set cpu number to cpu quota (least integer greater or equal to quota value, but not less than 2)")
+		flag.String("cpu-affinity", "", "comma-separated list of host CPUs and CPU ranges to pin the sandbox process to, e.g. 0-3,8. The number of CPUs in the sandbox is capped to the number of CPUs in the list.")
+		flag.String("oom-score-adj-gofer", "", "oom_score_adj to set on gofer processes, between -1000 and 1000. If unset, each gofer gets the oom_score_adj of its container.")
+		flag.String("default-ulimits", "", "comma-separated list of resource limits applied to every container unless its spec sets them, in the form name=soft[:hard], e.g. nofile=1024:2048.")
+		flag.String("restore-env-override", "", "comma-separated list of settings to take from the spec instead of the checkpoint when restoring, to restore a checkpoint on a host with a different environment. Supported settings: hostname.")
 		flag.Bool("oci-seccomp", false, "Enables loading OCI seccomp filters inside the sandbox.")
+		flag.String("syscall-allow-list", "", "comma-separated list of syscall names or numbers that the application may call, independently of the container's seccomp filters. Other syscalls fail with ENOSYS. If empty, all supported syscalls are allowed.")
+		flag.String("env-passthrough", "", "comma-separated list of environment variables to copy from runsc's environment into containers. Variables defined in the spec take precedence.")
+		flag.String("apparmor-profile", "", "name of a host AppArmor profile to confine the sandbox and gofer processes to. The profile must be loaded and must allow everything runsc does during setup.")
+		flag.Int("fork-rate-limit", 0, "maximum number of processes that can be created per second inside the sandbox. fork/clone fail with EAGAIN when exceeded. 0 disables the limit.")
+		flag.Duration("startup-timeout", 0, "maximum time to wait for the sandbox to boot, and then for it to start or restore the root container, before destroying it. 0 means no timeout.")
+		flag.Duration("idle-gc-interval", 0, "how often to return unused sentry memory to the host while the sandbox is idle. Backs off while the sandbox is busy. 0 disables it.")
+		flag.Uint("pagecache-limit", 0, "maximum size in bytes of unmapped file data cached by the sentry. Least recently used files are evicted when exceeded. 0 means no limit.")
+		flag.Uint("max-ptys", 0, "maximum number of PTYs that can be allocated in the sandbox, like /proc/sys/kernel/pty/max. 0 uses the default of 4096.")
 
 		// Flags that control sandbox runtime behavior: FS related.
 		flag.Var(fileAccessTypePtr(FileAccessExclusive), "file-access", "specifies which filesystem validation to use for the root mount: exclusive (default), shared.")
@@ -73,6 +87,8 @@ func RegisterFlags() {
 		flag.Bool("overlay", false, "wrap filesystem mounts with writable overlay. All modifications are stored in memory inside the sandbox.")
 		flag.Bool("verity", false, "specifies whether a verity file system will be mounted.")
 		flag.Bool("fsgofer-host-uds", false, "allow the gofer to mount Unix Domain Sockets.")
+		flag.Bool("gofer-health-check", false, "start a control server in gofer processes to allow checking that they serve file system requests. This allows the gofer to accept connections on its control socket.")
+		flag.String("mount-propagation-default", "", "propagation of bind mounts that don't set one in the spec: private, rprivate, slave or rslave. Propagation options in the spec take precedence.")
 		flag.Bool("vfs2", false, "enables VFSv2. This uses the new VFS layer that is faster than the previous one.")
 		flag.Bool("fuse", false, "TEST ONLY; use while FUSE in VFSv2 is landing. This allows the use of the new experimental FUSE filesystem.")
 		flag.Bool("cgroupfs", false, "Automatically mount cgroupfs.")
@@ -84,6 +100,9 @@ func RegisterFlags() {
 		flag.Bool("software-gso", true, "enable software segmentation offload when hardware offload can't be enabled.")
 		flag.Bool("tx-checksum-offload", false, "enable TX checksum offload.")
 		flag.Bool("rx-checksum-offload", true, "enable RX checksum offload.")
+		flag.Int("net-mtu", 0, "overrides the MTU of the sandbox network interfaces, which is otherwise inherited from the host. Must match what the host network can carry. 0 disables the override.")
+		flag.Var(netPollModePtr(NetPollBlock), "net-poll-mode", "specifies how network channels wait for packets: block (in poll) or busy (spin). busy lowers latency but keeps one host CPU busy per channel, see --num-network-channels.")
+		flag.Bool("net-transparent", false, "allow sandbox network interfaces to receive traffic for and bind to non-local addresses, for transparent proxying. The original destination of a connection is its local address. This also allows the sandbox to send packets with any source address, so the host must filter spoofed traffic if needed.")
 		flag.Var(queueingDisciplinePtr(QDiscFIFO), "qdisc", "specifies which queueing discipline to apply by default to the non loopback nics used by the sandbox.")
 		flag.Int("num-network-channels", 1, "number of underlying channels(FDs) to use for network link endpoints.")
 
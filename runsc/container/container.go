@@ -19,10 +19,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -33,13 +35,21 @@ import (
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/cleanup"
+	"gvisor.dev/gvisor/pkg/control/client"
+	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/p9"
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/devpts"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/sighandling"
+	"gvisor.dev/gvisor/pkg/unet"
+	"gvisor.dev/gvisor/pkg/urpc"
 	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/cgroup"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/console"
+	"gvisor.dev/gvisor/runsc/fsgofer"
 	"gvisor.dev/gvisor/runsc/sandbox"
 	"gvisor.dev/gvisor/runsc/specutils"
 )
@@ -109,6 +119,15 @@ type Container struct {
 	// be 0 if the gofer has been killed.
 	GoferPid int `json:"goferPid"`
 
+	// GoferStartedAt is the time the gofer was spawned. It's only set for
+	// root containers.
+	GoferStartedAt time.Time `json:"goferStartedAt,omitempty"`
+
+	// GoferControlAddr is the address of the gofer's control server. It's
+	// only set if the container was created with --gofer-health-check. It
+	// doesn't change if the container is renamed.
+	GoferControlAddr string `json:"goferControlAddr,omitempty"`
+
 	// Sandbox is the sandbox this container is running in. It's set when the
 	// container is created and reset when the sandbox is destroyed.
 	Sandbox *sandbox.Sandbox `json:"sandbox"`
@@ -117,6 +136,14 @@ type Container struct {
 	// processes.
 	Saver StateFile `json:"saver"`
 
+	// Labels contains arbitrary key/value metadata associated with the
+	// container. Unlike OCI annotations, labels may change after the
+	// container is created. See SetLabel.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// CPUBurst is set while the sandbox CPU quota is raised by SetCPUBurst.
+	CPUBurst *CPUBurst `json:"cpuBurst,omitempty"`
+
 	//
 	// Fields below this line are not saved in the state file and will not
 	// be preserved across commands.
@@ -173,6 +200,10 @@ func New(conf *config.Config, args Args) (*Container, error) {
 		return nil, fmt.Errorf("creating container root directory %q: %v", conf.RootDir, err)
 	}
 
+	if conf.EnvPassthrough != "" {
+		specutils.PassthroughEnv(args.Spec, strings.Split(conf.EnvPassthrough, ","))
+	}
+
 	sandboxID := args.ID
 	if !isRoot(args.Spec) {
 		var ok bool
@@ -224,6 +255,12 @@ func New(conf *config.Config, args Args) (*Container, error) {
 	if isRoot(args.Spec) {
 		log.Debugf("Creating new sandbox for container, cid: %s", args.ID)
 
+		if conf.AppArmorProfile != "" {
+			if err := specutils.CheckAppArmorProfile(conf.AppArmorProfile); err != nil {
+				return nil, err
+			}
+		}
+
 		if args.Spec.Linux == nil {
 			args.Spec.Linux = &specs.Linux{}
 		}
@@ -258,6 +295,7 @@ func New(conf *config.Config, args Args) (*Container, error) {
 			if err != nil {
 				return err
 			}
+			c.GoferStartedAt = time.Now()
 
 			// Start a new sandbox for this container. Any errors after this point
 			// must destroy the container.
@@ -416,12 +454,15 @@ func (c *Container) Start(conf *config.Config) error {
 
 	// Set container's oom_score_adj to the gofer since it is dedicated to
 	// the container, in case the gofer uses up too much memory.
-	return c.adjustGoferOOMScoreAdj()
+	return c.adjustGoferOOMScoreAdj(conf)
 }
 
 // Restore takes a container and replaces its kernel and file system
-// to restore a container from its state file.
-func (c *Container) Restore(spec *specs.Spec, conf *config.Config, restoreFile string) error {
+// to restore a container from its state file. If remapID is set, the state
+// file may come from a container with a different ID, e.g. to clone a
+// container from a checkpoint. In either case, the container's spec must have
+// the same mounts as the checkpointed container.
+func (c *Container) Restore(spec *specs.Spec, conf *config.Config, restoreFile string, remapID bool) error {
 	log.Debugf("Restore container, cid: %s", c.ID)
 	if err := c.Saver.lock(); err != nil {
 		return err
@@ -440,7 +481,7 @@ func (c *Container) Restore(spec *specs.Spec, conf *config.Config, restoreFile s
 		}
 	}
 
-	if err := c.Sandbox.Restore(c.ID, spec, conf, restoreFile); err != nil {
+	if err := c.Sandbox.Restore(c.ID, spec, conf, restoreFile, remapID); err != nil {
 		return err
 	}
 	c.changeStatus(Running)
@@ -463,7 +504,7 @@ func Run(conf *config.Config, args Args) (unix.WaitStatus, error) {
 
 	if conf.RestoreFile != "" {
 		log.Debugf("Restore: %v", conf.RestoreFile)
-		if err := c.Restore(args.Spec, conf, conf.RestoreFile); err != nil {
+		if err := c.Restore(args.Spec, conf, conf.RestoreFile, true /* remapID */); err != nil {
 			return 0, fmt.Errorf("starting container: %v", err)
 		}
 	} else {
@@ -489,6 +530,61 @@ func (c *Container) Execute(conf *config.Config, args *control.ExecArgs) (int32,
 	return c.Sandbox.Execute(conf, args)
 }
 
+// ExecStream is like Execute, but connects the stdout and stderr of the new
+// process to pipes and returns their read ends. Stdin is connected to
+// /dev/null. args must not have a file payload or a terminal.
+//
+// wait waits for the process to exit and returns its exit status, or 128 plus
+// the signal number if it was killed by a signal. The caller should consume
+// stdout and stderr concurrently with wait, since the process may block once
+// the pipes are full, and close them when done.
+func (c *Container) ExecStream(conf *config.Config, args *control.ExecArgs) (pid int32, stdout, stderr io.ReadCloser, wait func() (int, error), err error) {
+	if len(args.FilePayload.Files) > 0 || args.StdioIsPty {
+		return 0, nil, nil, nil, fmt.Errorf("cannot stream the output of a process with its own stdio")
+	}
+
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+	defer stdin.Close()
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return 0, nil, nil, nil, fmt.Errorf("creating stdout pipe: %v", err)
+	}
+	cu := cleanup.Make(func() { stdoutR.Close() })
+	defer cu.Clean()
+	defer stdoutW.Close()
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		return 0, nil, nil, nil, fmt.Errorf("creating stderr pipe: %v", err)
+	}
+	cu.Add(func() { stderrR.Close() })
+	defer stderrW.Close()
+
+	// The write ends are donated to the sandbox, so they're closed here once
+	// the process is started. The readers then see EOF when the process and
+	// its children close them.
+	args.FilePayload = urpc.FilePayload{Files: []*os.File{stdin, stdoutW, stderrW}}
+	pid, err = c.Execute(conf, args)
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	wait = func() (int, error) {
+		ws, err := c.WaitPID(pid)
+		if err != nil {
+			return 0, err
+		}
+		if ws.Signaled() {
+			return 128 + int(ws.Signal()), nil
+		}
+		return ws.ExitStatus(), nil
+	}
+	cu.Release()
+	return pid, stdoutR, stderrR, wait, nil
+}
+
 // Event returns events for the container.
 func (c *Container) Event() (*boot.EventOut, error) {
 	log.Debugf("Getting events for container, cid: %s", c.ID)
@@ -506,6 +602,176 @@ func (c *Container) Event() (*boot.EventOut, error) {
 	return event, nil
 }
 
+// Attach connects the given host files to the stdin, stdout and stderr of the
+// container's init process, replacing the stdio it was started with. The
+// returned function detaches them and restores the original stdio.
+//
+// Only the init process is affected, processes that it already started keep
+// their stdio. Attaching to a container that uses a terminal is not
+// supported.
+func (c *Container) Attach(stdin, stdout, stderr *os.File) (func(), error) {
+	log.Debugf("Attach to container, cid: %s", c.ID)
+	if err := c.requireStatus("attach to", Running); err != nil {
+		return nil, err
+	}
+	if err := c.Sandbox.Attach(c.ID, stdin, stdout, stderr); err != nil {
+		return nil, err
+	}
+	detach := func() {
+		if err := c.Sandbox.Detach(c.ID); err != nil {
+			log.Warningf("Failed to detach from container %q: %v", c.ID, err)
+		}
+	}
+	return detach, nil
+}
+
+// NetworkStats returns network statistics for each NIC in the sandbox, keyed
+// by NIC name. Since NICs belong to the sandbox, the stats are shared by all
+// containers in it. With host networking the sandbox network stack is not used
+// and the returned map is empty.
+func (c *Container) NetworkStats() (map[string]boot.NICStats, error) {
+	log.Debugf("Getting network stats for container, cid: %s", c.ID)
+	if err := c.requireStatus("get network stats for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	return c.Sandbox.NetworkStats()
+}
+
+// BootTimings returns the phases of the startup of the sandbox, ordered by
+// time. The container must be the root container of the sandbox.
+func (c *Container) BootTimings() ([]boot.BootPhase, error) {
+	log.Debugf("Getting boot timings for container, cid: %s", c.ID)
+	if err := c.requireStatus("get boot timings for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	if !isRoot(c.Spec) {
+		return nil, fmt.Errorf("cannot get boot timings for container %q: they are only recorded for the root container of sandbox %q", c.ID, c.Sandbox.ID)
+	}
+	phases, err := c.Sandbox.BootTimings()
+	if err != nil {
+		return nil, err
+	}
+	phases = append(phases, boot.BootPhase{Name: boot.BootPhaseCreated, Time: c.CreatedAt})
+	if !c.GoferStartedAt.IsZero() {
+		phases = append(phases, boot.BootPhase{Name: boot.BootPhaseGoferStarted, Time: c.GoferStartedAt})
+	}
+	sort.SliceStable(phases, func(i, j int) bool {
+		return phases[i].Time.Before(phases[j].Time)
+	})
+	return phases, nil
+}
+
+// Terminals returns the terminals that have open file descriptions in the
+// sandbox, ordered by index. Terminals are shared by all containers in the
+// sandbox.
+func (c *Container) Terminals() ([]devpts.TerminalInfo, error) {
+	log.Debugf("Getting terminals for container, cid: %s", c.ID)
+	if err := c.requireStatus("get terminals for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	return c.Sandbox.Terminals()
+}
+
+// PlatformCapabilities returns the features of the platform that the sandbox
+// runs on, which are shared by all containers in the sandbox.
+func (c *Container) PlatformCapabilities() (*boot.PlatformCaps, error) {
+	log.Debugf("Getting platform capabilities for container, cid: %s", c.ID)
+	if err := c.requireStatus("get platform capabilities for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	return c.Sandbox.PlatformCapabilities()
+}
+
+// IPTables returns the rules of a table of the sandbox network stack, in the
+// format of an IPT_SO_SET_REPLACE payload. Rules are shared by all containers
+// in the sandbox.
+func (c *Container) IPTables(table string, ipv6 bool) ([]byte, error) {
+	log.Debugf("Getting iptables for container, cid: %s, table: %q", c.ID, table)
+	if err := c.requireStatus("get iptables for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	return c.Sandbox.IPTables(table, ipv6)
+}
+
+// SetIPTables replaces a table of the sandbox network stack with the rules in
+// replace, which must be in the format returned by IPTables. The table to
+// replace is named in the payload.
+func (c *Container) SetIPTables(replace []byte, ipv6 bool) error {
+	log.Debugf("Setting iptables for container, cid: %s", c.ID)
+	if err := c.requireStatus("set iptables for", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.SetIPTables(replace, ipv6)
+}
+
+// ReloadSeccomp installs profile as an additional OCI seccomp filter for all
+// processes in the container. Seccomp always applies the least permissive
+// action of all installed filters, so the container's syscall policy can only
+// be tightened this way. Use OverrideSeccomp to loosen it. Requires
+// --oci-seccomp.
+func (c *Container) ReloadSeccomp(profile specs.LinuxSeccomp) error {
+	log.Debugf("Reload seccomp profile, cid: %s", c.ID)
+	if err := c.requireStatus("reload seccomp profile for", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.ReloadSeccomp(c.ID, &profile, false)
+}
+
+// OverrideSeccomp is like ReloadSeccomp, but profile replaces all seccomp
+// filters of the container's processes, including those installed by the
+// application itself. The new policy may thus be less restrictive than the
+// current one.
+func (c *Container) OverrideSeccomp(profile specs.LinuxSeccomp) error {
+	log.Debugf("Override seccomp profile, cid: %s", c.ID)
+	if err := c.requireStatus("override seccomp profile for", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.ReloadSeccomp(c.ID, &profile, true)
+}
+
+// Rename changes the ID of the container to newID. Processes already running
+// in the container are moved to the new ID as well.
+//
+// The root container can't be renamed, because its ID is also the sandbox ID,
+// which the sandbox control socket and the state of all other containers in
+// the sandbox are keyed on.
+func (c *Container) Rename(newID string) error {
+	log.Debugf("Rename container, cid: %s, new cid: %s", c.ID, newID)
+	if err := validateID(newID); err != nil {
+		return err
+	}
+	if c.ID == c.Saver.ID.SandboxID {
+		return fmt.Errorf("cannot rename root container %q, its ID is the sandbox ID", c.ID)
+	}
+
+	if err := c.Saver.lock(); err != nil {
+		return err
+	}
+	defer c.Saver.unlockOrDie()
+
+	oldID := c.ID
+	cu := cleanup.Cleanup{}
+	defer cu.Clean()
+	if c.Status != Stopped && c.IsSandboxRunning() {
+		if err := c.Sandbox.RenameContainer(oldID, newID); err != nil {
+			return err
+		}
+		cu.Add(func() {
+			if err := c.Sandbox.RenameContainer(newID, oldID); err != nil {
+				log.Warningf("Failed to rename container %q back to %q: %v", newID, oldID, err)
+			}
+		})
+	}
+
+	c.ID = newID
+	if err := c.Saver.renameLocked(FullID{SandboxID: c.Saver.ID.SandboxID, ContainerID: newID}, c); err != nil {
+		c.ID = oldID
+		return fmt.Errorf("renaming container metadata: %w", err)
+	}
+	cu.Release()
+	return nil
+}
+
 // SandboxPid returns the Pid of the sandbox the container is running in, or -1 if the
 // container is not running.
 func (c *Container) SandboxPid() int {
@@ -515,6 +781,44 @@ func (c *Container) SandboxPid() int {
 	return c.Sandbox.Pid
 }
 
+// InitPidFD returns a file that becomes readable when the container's init
+// process exits, which makes it usable with poll(2) and epoll(7) without the
+// PID reuse races of waiting on a PID.
+//
+// For the root container, it's a pidfd referring to the sandbox process, which
+// exits with the init process. Otherwise, or if the host doesn't support
+// pidfds, it's the read end of a pipe that's closed once the init process
+// exits. The caller is responsible for closing the file.
+func (c *Container) InitPidFD() (*os.File, error) {
+	log.Debugf("Getting init pidfd for container, cid: %s", c.ID)
+	if err := c.requireStatus("get init pidfd for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	if c.Sandbox.IsRootContainer(c.ID) {
+		fd, _, errno := unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(c.Sandbox.Pid), 0, 0)
+		switch errno {
+		case 0:
+			return os.NewFile(fd, fmt.Sprintf("pidfd:%d", c.Sandbox.Pid)), nil
+		case unix.ENOSYS:
+			log.Debugf("pidfd_open not supported, falling back to a pipe")
+		default:
+			return nil, fmt.Errorf("pidfd_open(%d): %w", c.Sandbox.Pid, errno)
+		}
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating pipe: %w", err)
+	}
+	go func() {
+		defer w.Close()
+		if _, err := c.Sandbox.Wait(c.ID); err != nil {
+			log.Warningf("Waiting for container %q init process: %v", c.ID, err)
+		}
+	}()
+	return r, nil
+}
+
 // Wait waits for the container to exit, and returns its WaitStatus.
 // Call to wait on a stopped container is needed to retrieve the exit status
 // and wait returns immediately.
@@ -528,6 +832,19 @@ func (c *Container) Wait() (unix.WaitStatus, error) {
 	return ws, err
 }
 
+// WaitWithUsage waits for the container to exit, and returns its WaitStatus
+// along with the resource usage of its init process, as wait4(2) would.
+// Fields that the sentry doesn't track are left zero.
+func (c *Container) WaitWithUsage() (unix.WaitStatus, *unix.Rusage, error) {
+	log.Debugf("Wait with usage on container, cid: %s", c.ID)
+	ws, ru, err := c.Sandbox.WaitWithUsage(c.ID)
+	if err == nil {
+		// Wait succeeded, container is not running anymore.
+		c.changeStatus(Stopped)
+	}
+	return ws, ru, err
+}
+
 // WaitRootPID waits for process 'pid' in the sandbox's PID namespace and
 // returns its WaitStatus.
 func (c *Container) WaitRootPID(pid int32) (unix.WaitStatus, error) {
@@ -568,6 +885,16 @@ func (c *Container) SignalContainer(sig unix.Signal, all bool) error {
 	return c.Sandbox.SignalContainer(c.ID, sig, all)
 }
 
+// SignalByName is like SignalContainer, but takes the signal by name or
+// number, as accepted by kill(1), e.g. "SIGTERM", "TERM" or "15".
+func (c *Container) SignalByName(name string, all bool) error {
+	sig, err := specutils.ParseSignal(name)
+	if err != nil {
+		return err
+	}
+	return c.SignalContainer(sig, all)
+}
+
 // SignalProcess sends sig to a specific process in the container.
 func (c *Container) SignalProcess(sig unix.Signal, pid int32) error {
 	log.Debugf("Signal process %d in container, cid: %s, signal: %v (%d)", pid, c.ID, sig, sig)
@@ -600,11 +927,163 @@ func (c *Container) ForwardSignals(pid int32, fgProcess bool) func() {
 // Checkpoint sends the checkpoint call to the container.
 // The statefile will be written to f, the file at the specified image-path.
 func (c *Container) Checkpoint(f *os.File) error {
-	log.Debugf("Checkpoint container, cid: %s", c.ID)
+	return c.CheckpointWithProgress(f, nil)
+}
+
+// checkpointProgressInterval is how often CheckpointWithProgress reports
+// progress.
+const checkpointProgressInterval = time.Second
+
+// CheckpointProgress describes how far along a checkpoint is.
+type CheckpointProgress struct {
+	// Written is the number of bytes written to the state file so far.
+	Written int64
+
+	// Total is the sandbox memory usage when the checkpoint started, which
+	// serves as an estimate of the amount of data to be saved. It may be
+	// larger than the final state file size, since the state is compressed.
+	// Zero if unknown.
+	Total uint64
+
+	// Done is set for the final report, once the checkpoint has completed.
+	Done bool
+}
+
+// CheckpointWithProgress is like Checkpoint, but also calls 'progress'
+// periodically while the checkpoint is in flight, and one last time once it's
+// done. 'progress' may be nil.
+//
+// Progress is measured by the size of 'f', which must be a regular file.
+func (c *Container) CheckpointWithProgress(f *os.File, progress func(CheckpointProgress)) error {
+	return c.checkpoint(f, progress, false /* resume */)
+}
+
+func (c *Container) checkpoint(f *os.File, progress func(CheckpointProgress), resume bool) error {
+	log.Debugf("Checkpoint container, cid: %s, resume: %t", c.ID, resume)
 	if err := c.requireStatus("checkpoint", Created, Running, Paused); err != nil {
 		return err
 	}
-	return c.Sandbox.Checkpoint(c.ID, f)
+	if progress == nil {
+		return c.Sandbox.Checkpoint(c.ID, f, resume)
+	}
+
+	var total uint64
+	if event, err := c.Sandbox.Event(c.ID); err != nil {
+		log.Warningf("Failed to get memory usage for checkpoint progress: %v", err)
+	} else {
+		total = event.Event.Data.Memory.Usage.Usage
+	}
+	report := func(done bool) {
+		p := CheckpointProgress{Total: total, Done: done}
+		if fi, err := f.Stat(); err == nil {
+			p.Written = fi.Size()
+		}
+		progress(p)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(checkpointProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report(false)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	err := c.Sandbox.Checkpoint(c.ID, f, resume)
+	close(stop)
+	<-stopped
+	if err != nil {
+		return err
+	}
+	report(true)
+	return nil
+}
+
+// CheckpointOpts contains options for CheckpointWithOpts.
+type CheckpointOpts struct {
+	// PreCheckpointExec, if not nil, is a process executed in the container
+	// right before the checkpoint, e.g. to have the application flush its
+	// buffers. The checkpoint is only taken once it exits with status 0.
+	// Its stdout and stderr are those of the current process. Terminal
+	// isn't supported.
+	PreCheckpointExec *specs.Process
+
+	// Progress, if not nil, is called as in CheckpointWithProgress.
+	Progress func(CheckpointProgress)
+
+	// Resume keeps the container running after the checkpoint. By default,
+	// the sandbox exits once the checkpoint is taken.
+	Resume bool
+}
+
+// CheckpointWithOpts is like CheckpointWithProgress, with additional options.
+func (c *Container) CheckpointWithOpts(conf *config.Config, f *os.File, opts CheckpointOpts) error {
+	if opts.PreCheckpointExec != nil {
+		if err := c.preCheckpointExec(conf, opts.PreCheckpointExec); err != nil {
+			return fmt.Errorf("pre-checkpoint command failed, checkpoint aborted: %v", err)
+		}
+	}
+	return c.checkpoint(f, opts.Progress, opts.Resume)
+}
+
+// preCheckpointExec runs p in the container and waits for it to exit
+// successfully.
+func (c *Container) preCheckpointExec(conf *config.Config, p *specs.Process) error {
+	if len(p.Args) == 0 {
+		return fmt.Errorf("no command given")
+	}
+	if p.Terminal {
+		return fmt.Errorf("terminal is not supported")
+	}
+	var caps *auth.TaskCapabilities
+	if p.Capabilities != nil {
+		var err error
+		caps, err = specutils.Capabilities(conf.EnableRaw, p.Capabilities)
+		if err != nil {
+			return fmt.Errorf("creating capabilities: %v", err)
+		}
+	}
+	extraKGIDs := make([]auth.KGID, 0, len(p.User.AdditionalGids))
+	for _, gid := range p.User.AdditionalGids {
+		extraKGIDs = append(extraKGIDs, auth.KGID(gid))
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+
+	args := &control.ExecArgs{
+		Argv:             p.Args,
+		Envv:             p.Env,
+		WorkingDirectory: p.Cwd,
+		KUID:             auth.KUID(p.User.UID),
+		KGID:             auth.KGID(p.User.GID),
+		ExtraKGIDs:       extraKGIDs,
+		Capabilities:     caps,
+		FilePayload:      urpc.FilePayload{Files: []*os.File{devNull, os.Stdout, os.Stderr}},
+	}
+	log.Infof("Running pre-checkpoint command in container %q: %v", c.ID, p.Args)
+	pid, err := c.Execute(conf, args)
+	if err != nil {
+		return fmt.Errorf("executing %v: %v", p.Args, err)
+	}
+	ws, err := c.WaitPID(pid)
+	if err != nil {
+		return fmt.Errorf("waiting for %v: %v", p.Args, err)
+	}
+	if !ws.Exited() || ws.ExitStatus() != 0 {
+		return fmt.Errorf("%v exited with status %#x", p.Args, uint32(ws))
+	}
+	return nil
 }
 
 // Pause suspends the container and its kernel.
@@ -646,6 +1125,192 @@ func (c *Container) Resume() error {
 	return c.saveLocked()
 }
 
+// Update applies new resource limits to a created or running container, like
+// 'runc update'. Limits are enforced by the sandbox cgroup on the host, which
+// is shared by all containers in the sandbox. Thus, only the root container's
+// limits are applied. For other containers, res is only recorded in the spec
+// and the shared sandbox limits are left untouched.
+func (c *Container) Update(conf *config.Config, res *specs.LinuxResources) error {
+	log.Debugf("Update container, cid: %s", c.ID)
+	if err := c.Saver.lock(); err != nil {
+		return err
+	}
+	defer c.Saver.unlockOrDie()
+
+	if err := c.requireStatus("update", Created, Running); err != nil {
+		return err
+	}
+	if c.Sandbox.Cgroup == nil {
+		return fmt.Errorf("cannot update container %q: sandbox %q has no cgroup configured", c.ID, c.Sandbox.ID)
+	}
+
+	if isRoot(c.Spec) {
+		if err := c.Sandbox.UpdateResources(res); err != nil {
+			switch {
+			case errors.Is(err, unix.EACCES) && conf.Rootless:
+				log.Warningf("Skipping cgroup update in rootless mode: %v", err)
+			default:
+				return fmt.Errorf("updating container %q: %v", c.ID, err)
+			}
+		}
+	} else {
+		log.Warningf("Container %q shares the cgroup of sandbox %q, resources are only updated with the root container", c.ID, c.Sandbox.ID)
+	}
+
+	if res.CPU != nil && res.CPU.Quota != nil {
+		// The new quota is the steady state, a pending burst must not
+		// revert it.
+		c.CPUBurst = nil
+	}
+	if c.Spec.Linux == nil {
+		c.Spec.Linux = &specs.Linux{}
+	}
+	c.Spec.Linux.Resources = res
+	return c.saveLocked()
+}
+
+// CPUBurst records a temporary raise of the sandbox CPU quota.
+type CPUBurst struct {
+	// SteadyQuota is the CFS CPU quota, in microseconds per period, restored
+	// when the burst ends. -1 means no quota.
+	SteadyQuota int64 `json:"steadyQuota"`
+}
+
+// SetCPUBurst raises the CFS CPU quota of the sandbox cgroup to quota
+// microseconds per period, or lifts it if quota is -1, until EndCPUBurst is
+// called. Like Update, it only applies to the root container, since the
+// cgroup is shared by all containers in the sandbox.
+//
+// The steady-state quota is saved in the state file, so the burst can be
+// ended by another process, e.g. a later runsc invocation, and a burst
+// started while another one is in progress still reverts to the quota that
+// was set before either of them. Setting the CPU quota with Update ends the
+// burst and makes the new quota the steady state.
+//
+// The burst isn't ended automatically: the sandbox can't change its own
+// cgroup, and the calling process may exit before a timer would fire.
+func (c *Container) SetCPUBurst(quota int64) error {
+	log.Debugf("Set CPU burst for container, cid: %s, quota: %d", c.ID, quota)
+	if quota <= 0 && quota != -1 {
+		return fmt.Errorf("invalid CPU quota %d, must be positive or -1", quota)
+	}
+	if err := c.Saver.lock(); err != nil {
+		return err
+	}
+	defer c.Saver.unlockOrDie()
+
+	if err := c.requireCPUBurstLocked("set CPU burst for"); err != nil {
+		return err
+	}
+
+	// Reload the burst from the state file, as it may have been started by
+	// another process. In that case the cgroup holds the burst quota, not
+	// the steady-state one.
+	var cur Container
+	if err := c.Saver.loadLocked(&cur); err != nil {
+		return fmt.Errorf("reading container metadata: %v", err)
+	}
+	burst := cur.CPUBurst
+	if burst == nil {
+		steady, err := c.Sandbox.Cgroup.CPUQuotaUs()
+		if err != nil {
+			return fmt.Errorf("reading CPU quota: %v", err)
+		}
+		burst = &CPUBurst{SteadyQuota: steady}
+	}
+	if err := setCPUQuota(c.Sandbox.Cgroup, quota); err != nil {
+		return fmt.Errorf("setting CPU burst for container %q: %v", c.ID, err)
+	}
+	c.CPUBurst = burst
+	return c.saveLocked()
+}
+
+// EndCPUBurst restores the steady-state CPU quota saved by SetCPUBurst. It's
+// a no-op if no burst is in progress.
+func (c *Container) EndCPUBurst() error {
+	log.Debugf("End CPU burst for container, cid: %s", c.ID)
+	if err := c.Saver.lock(); err != nil {
+		return err
+	}
+	defer c.Saver.unlockOrDie()
+
+	var cur Container
+	if err := c.Saver.loadLocked(&cur); err != nil {
+		return fmt.Errorf("reading container metadata: %v", err)
+	}
+	if cur.CPUBurst == nil {
+		c.CPUBurst = nil
+		return nil
+	}
+	if err := c.requireCPUBurstLocked("end CPU burst for"); err != nil {
+		return err
+	}
+	log.Debugf("Ending CPU burst for container, cid: %s, quota: %d", c.ID, cur.CPUBurst.SteadyQuota)
+	if err := setCPUQuota(c.Sandbox.Cgroup, cur.CPUBurst.SteadyQuota); err != nil {
+		return fmt.Errorf("ending CPU burst for container %q: %v", c.ID, err)
+	}
+	c.CPUBurst = nil
+	return c.saveLocked()
+}
+
+// requireCPUBurstLocked returns an error if the sandbox CPU quota can't be
+// changed through c. action is used in the error message.
+//
+// Precondition: container must be locked with container.lock().
+func (c *Container) requireCPUBurstLocked(action string) error {
+	if err := c.requireStatus(action, Created, Running); err != nil {
+		return err
+	}
+	if !isRoot(c.Spec) {
+		return fmt.Errorf("cannot %s container %q: it shares the cgroup of sandbox %q, use the root container", action, c.ID, c.Sandbox.ID)
+	}
+	if c.Sandbox.Cgroup == nil {
+		return fmt.Errorf("cannot %s container %q: sandbox %q has no cgroup configured", action, c.ID, c.Sandbox.ID)
+	}
+	return nil
+}
+
+// setCPUQuota sets the CFS CPU quota of cg.
+func setCPUQuota(cg *cgroup.Cgroup, quota int64) error {
+	return cg.Update(&specs.LinuxResources{CPU: &specs.LinuxCPU{Quota: &quota}})
+}
+
+// SetLabel sets the label key to value and persists it in the container's
+// state file.
+func (c *Container) SetLabel(key, value string) error {
+	log.Debugf("Set label for container, cid: %s, key: %q", c.ID, key)
+	if key == "" {
+		return fmt.Errorf("label key cannot be empty")
+	}
+	if err := c.Saver.lock(); err != nil {
+		return err
+	}
+	defer c.Saver.unlockOrDie()
+
+	// Reload labels from the state file to avoid dropping labels set by other
+	// processes after this container was loaded.
+	var cur Container
+	if err := c.Saver.loadLocked(&cur); err != nil {
+		return fmt.Errorf("reading container metadata: %v", err)
+	}
+	if cur.Labels == nil {
+		cur.Labels = make(map[string]string)
+	}
+	cur.Labels[key] = value
+	c.Labels = cur.Labels
+	return c.saveLocked()
+}
+
+// GetLabels returns a copy of the container's labels as of the time the
+// container was loaded or last modified by this process.
+func (c *Container) GetLabels() map[string]string {
+	labels := make(map[string]string, len(c.Labels))
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
 // State returns the metadata of the container.
 func (c *Container) State() specs.State {
 	return specs.State{
@@ -666,6 +1331,20 @@ func (c *Container) Processes() ([]*control.Process, error) {
 	return c.Sandbox.Processes(c.ID)
 }
 
+// ListProcessesTree returns the processes running in the container arranged
+// by parent, see control.ProcessListToTree.
+func (c *Container) ListProcessesTree() (*control.ProcessTree, error) {
+	pl, err := c.Processes()
+	if err != nil {
+		return nil, err
+	}
+	pt := control.ProcessListToTree(pl)
+	if pt == nil {
+		return nil, fmt.Errorf("container %q has no processes", c.ID)
+	}
+	return pt, nil
+}
+
 // Destroy stops all processes and frees all resources associated with the
 // container.
 func (c *Container) Destroy() error {
@@ -678,7 +1357,13 @@ func (c *Container) Destroy() error {
 		c.Saver.unlockOrDie()
 		_ = c.Saver.close()
 	}()
+	return c.destroyLocked()
+}
 
+// destroyLocked is Destroy with the container lock already held.
+//
+// Precondition: container must be locked with container.lock().
+func (c *Container) destroyLocked() error {
 	// Stored for later use as stop() sets c.Sandbox to nil.
 	sb := c.Sandbox
 
@@ -736,6 +1421,96 @@ func (c *Container) Destroy() error {
 	return fmt.Errorf(strings.Join(errs, "\n"))
 }
 
+// GC destroys all containers in rootDir whose sandbox process no longer
+// exists, as can be left behind when runsc or the sandbox crashes. It returns
+// the IDs of the containers that were cleaned up.
+//
+// Containers that are still being created, or whose sandbox PID hasn't been
+// recorded yet, are never considered stale.
+//
+// Cleanup is best effort: containers that fail to be destroyed are logged and
+// skipped, and the first such error is returned along with the IDs that were
+// successfully cleaned up.
+func GC(rootDir string) ([]string, error) {
+	log.Debugf("GC containers, rootDir: %q", rootDir)
+	ids, err := List(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %v", err)
+	}
+
+	var (
+		cleaned  []string
+		firstErr error
+	)
+	for _, id := range ids {
+		c, err := Load(rootDir, id, LoadOpts{Exact: true, SkipCheck: true})
+		if err != nil {
+			// Container file may not exist if it raced with deletion.
+			if os.IsNotExist(err) {
+				continue
+			}
+			log.Warningf("Skipping container %q: %v", id, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !c.isStale() {
+			continue
+		}
+		destroyed, err := c.destroyIfStale()
+		if err != nil {
+			log.Warningf("Failed to destroy stale container %q: %v", c.ID, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("destroying container %q: %v", c.ID, err)
+			}
+			continue
+		}
+		if destroyed {
+			cleaned = append(cleaned, c.ID)
+		}
+	}
+	return cleaned, firstErr
+}
+
+// isStale returns true if c has a recorded sandbox whose process no longer
+// exists.
+func (c *Container) isStale() bool {
+	if c.Status == Creating || c.Sandbox == nil || c.Sandbox.Pid == 0 {
+		return false
+	}
+	return !c.Sandbox.IsRunning()
+}
+
+// destroyIfStale reloads c under the container lock and destroys it if it is
+// still stale, so that it doesn't race with other runsc commands updating c.
+// It returns true if c was destroyed.
+func (c *Container) destroyIfStale() (bool, error) {
+	if err := c.Saver.lock(); err != nil {
+		return false, err
+	}
+	defer func() {
+		c.Saver.unlockOrDie()
+		_ = c.Saver.close()
+	}()
+
+	if err := c.Saver.loadLocked(c); err != nil {
+		if os.IsNotExist(err) {
+			// Destroyed concurrently.
+			return false, nil
+		}
+		return false, fmt.Errorf("reading container metadata file %q: %v", c.Saver.statePath(), err)
+	}
+	if !c.isStale() {
+		return false, nil
+	}
+	log.Infof("Removing stale container %q, sandbox %q is not running", c.ID, c.Sandbox.ID)
+	if err := c.destroyLocked(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // saveLocked saves the container metadata to a file.
 //
 // Precondition: container must be locked with container.lock().
@@ -850,14 +1625,21 @@ func (c *Container) createGoferProcess(spec *specs.Spec, conf *config.Config, bu
 				test = t
 			}
 		}
-		debugLogFile, err := specutils.DebugLogFile(conf.DebugLog, "gofer", test)
+		rotate := 0
+		if conf.LogRotateSize > 0 {
+			rotate = conf.LogRotateCount
+		}
+		debugLogFiles, err := specutils.DebugLogFiles(conf.DebugLog, "gofer", test, rotate)
 		if err != nil {
 			return nil, nil, fmt.Errorf("opening debug log file in %q: %v", conf.DebugLog, err)
 		}
-		defer debugLogFile.Close()
-		goferEnds = append(goferEnds, debugLogFile)
+		// Rotated files, if any, are donated right after the debug log FD.
 		args = append(args, "--debug-log-fd="+strconv.Itoa(nextFD))
-		nextFD++
+		for _, f := range debugLogFiles {
+			defer f.Close()
+			goferEnds = append(goferEnds, f)
+			nextFD++
+		}
 	}
 
 	args = append(args, "gofer", "--bundle", bundleDir)
@@ -883,6 +1665,21 @@ func (c *Container) createGoferProcess(spec *specs.Spec, conf *config.Config, bu
 	args = append(args, fmt.Sprintf("--mounts-fd=%d", nextFD))
 	nextFD++
 
+	if conf.GoferHealthCheck {
+		// Create a socket for the gofer control server, used by GoferHealthy.
+		addr := fsgofer.ControlSocketAddr(c.ID)
+		sockFD, err := server.CreateSocket(addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating control server socket for gofer: %v", err)
+		}
+		controllerFile := os.NewFile(uintptr(sockFD), "gofer control server socket")
+		defer controllerFile.Close()
+		goferEnds = append(goferEnds, controllerFile)
+		args = append(args, fmt.Sprintf("--controller-fd=%d", nextFD))
+		nextFD++
+		c.GoferControlAddr = addr
+	}
+
 	// Add root mount and then add any other additional mounts.
 	mountCount := 1
 	for _, m := range spec.Mounts {
@@ -955,6 +1752,92 @@ func (c *Container) createGoferProcess(spec *specs.Spec, conf *config.Config, bu
 	return sandEnds, mountsSand, nil
 }
 
+// goferProbeTimeout is how long GoferHealthy waits for the gofer to answer.
+const goferProbeTimeout = time.Second
+
+// GoferHealthy checks that the container's gofer process exists and serves 9P
+// requests within a short timeout. It returns false without an error if the
+// gofer has exited or is too slow to answer, and an error if the check itself
+// can't be done. The container must have been created with
+// --gofer-health-check.
+func (c *Container) GoferHealthy() (bool, error) {
+	if c.GoferControlAddr == "" {
+		return false, fmt.Errorf("gofer of container %q has no control server, --gofer-health-check wasn't set", c.ID)
+	}
+	if c.GoferPid == 0 {
+		return false, nil
+	}
+	if err := unix.Kill(c.GoferPid, 0); err != nil {
+		if err == unix.ESRCH {
+			return false, nil
+		}
+		return false, fmt.Errorf("checking gofer PID %d: %v", c.GoferPid, err)
+	}
+
+	conn, err := client.ConnectTo(c.GoferControlAddr)
+	if err != nil {
+		return false, fmt.Errorf("connecting to gofer control server at PID %d: %v", c.GoferPid, err)
+	}
+	defer conn.Close()
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return false, fmt.Errorf("creating probe socket: %v", err)
+	}
+	goferEnd := os.NewFile(uintptr(fds[0]), "gofer probe socket")
+	defer goferEnd.Close()
+	sock, err := unet.NewSocket(fds[1])
+	if err != nil {
+		unix.Close(fds[1])
+		return false, fmt.Errorf("creating probe socket: %v", err)
+	}
+	defer sock.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- probeGofer(conn, goferEnd, sock)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Warningf("Probing gofer for container %q: %v", c.ID, err)
+			return false, nil
+		}
+		return true, nil
+	case <-time.After(goferProbeTimeout):
+		log.Warningf("Gofer for container %q didn't answer in %v", c.ID, goferProbeTimeout)
+		// Unblock probeGofer. Client.Close would wait for the in-flight call.
+		conn.Socket.Close()
+		sock.Close()
+		return false, nil
+	}
+}
+
+// probeGofer has the gofer serve 9P on goferEnd, and checks that it answers
+// requests sent over sock, which is connected to goferEnd.
+func probeGofer(conn *urpc.Client, goferEnd *os.File, sock *unet.Socket) error {
+	args := fsgofer.ProbeArgs{
+		FilePayload: urpc.FilePayload{Files: []*os.File{goferEnd}},
+	}
+	if err := conn.Call(fsgofer.ControllerProbe, &args, nil); err != nil {
+		return err
+	}
+	p9Client, err := p9.NewClient(sock, p9.DefaultMessageSize, p9.HighestVersionString())
+	if err != nil {
+		return fmt.Errorf("creating 9P client: %v", err)
+	}
+	defer p9Client.Close()
+	root, err := p9Client.Attach("")
+	if err != nil {
+		return fmt.Errorf("attaching to gofer root: %v", err)
+	}
+	defer root.Close()
+	if _, _, _, err := root.GetAttr(p9.AttrMaskAll()); err != nil {
+		return fmt.Errorf("getting gofer root attributes: %v", err)
+	}
+	return nil
+}
+
 // changeStatus transitions from one status to another ensuring that the
 // transition is valid.
 func (c *Container) changeStatus(s Status) {
@@ -1030,9 +1913,16 @@ func runInCgroup(cg *cgroup.Cgroup, fn func() error) error {
 	return fn()
 }
 
-// adjustGoferOOMScoreAdj sets the oom_store_adj for the container's gofer.
-func (c *Container) adjustGoferOOMScoreAdj() error {
-	if c.GoferPid == 0 || c.Spec.Process.OOMScoreAdj == nil {
+// adjustGoferOOMScoreAdj sets the oom_store_adj for the container's gofer. It's
+// taken from --oom-score-adj-gofer if set, and from the container otherwise.
+func (c *Container) adjustGoferOOMScoreAdj(conf *config.Config) error {
+	if c.GoferPid == 0 {
+		return nil
+	}
+	if score, ok := conf.GoferOOMScoreAdjOverride(); ok {
+		return setOOMScoreAdj(c.GoferPid, score)
+	}
+	if c.Spec.Process.OOMScoreAdj == nil {
 		return nil
 	}
 	return setOOMScoreAdj(c.GoferPid, *c.Spec.Process.OOMScoreAdj)
@@ -1131,6 +2021,8 @@ func setOOMScoreAdj(pid int, scoreAdj int) error {
 // TODO(gvisor.dev/issue/172): This is an estimation; we should do more
 // detailed accounting.
 func (c *Container) populateStats(event *boot.EventOut) {
+	c.populateMemoryStats(event)
+
 	// The events command, when run for all running containers, should
 	// account for the full cgroup CPU usage. We split cgroup usage
 	// proportionally according to the sentry-internal usage measurements,
@@ -1177,3 +2069,46 @@ func (c *Container) populateStats(event *boot.EventOut) {
 	event.Event.Data.CPU.Usage.Total = uint64(total)
 	return
 }
+
+// populateMemoryStats replaces the sandbox memory usage in event with an
+// estimate of the container's share of it. Usage is split proportionally to
+// the resident set size of each container's processes, as reported by the
+// sentry. Usage is taken from the host cgroup if it's readable, and from the
+// sentry's accounting otherwise.
+func (c *Container) populateMemoryStats(event *boot.EventOut) {
+	if len(event.ContainerMemoryUsage) == 0 {
+		// Nothing to split the usage by, leave the sandbox usage in place.
+		return
+	}
+	var allContainersRSS uint64
+	for _, u := range event.ContainerMemoryUsage {
+		allContainersRSS += u.RSS
+	}
+	container := event.ContainerMemoryUsage[c.ID]
+
+	mem := &event.Event.Data.Memory
+	if cgroup, err := c.Sandbox.NewCGroup(); err == nil {
+		if usage, _, failcnt, err := cgroup.MemoryUsage(); err == nil {
+			mem.Usage.Usage = usage
+			mem.Usage.Failcnt = failcnt
+		} else {
+			log.Warningf("events: failed when getting cgroup memory usage for container: %v", err)
+		}
+		if limit, err := cgroup.MemoryLimit(); err == nil {
+			mem.Usage.Limit = limit
+		}
+	}
+
+	// If the sentry reports no RSS, split usage equally across containers.
+	share := 1 / float64(len(event.ContainerMemoryUsage))
+	if allContainersRSS > 0 {
+		share = float64(container.RSS) / float64(allContainersRSS)
+	}
+	mem.Usage.Usage = uint64(float64(mem.Usage.Usage) * share)
+	mem.Cache = uint64(float64(mem.Cache) * share)
+	mem.Usage.Max = container.MaxRSS
+	if mem.Usage.Max < mem.Usage.Usage {
+		mem.Usage.Max = mem.Usage.Usage
+	}
+	log.Debugf("Memory usage, container RSS: %d, all RSS: %d, usage: %d, max: %d", container.RSS, allContainersRSS, mem.Usage.Usage, mem.Usage.Max)
+}
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"reflect"
@@ -44,6 +45,7 @@ import (
 	"gvisor.dev/gvisor/pkg/urpc"
 	"gvisor.dev/gvisor/runsc/boot/platforms"
 	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/sandbox"
 	"gvisor.dev/gvisor/runsc/specutils"
 )
 
@@ -1136,7 +1138,12 @@ func TestCheckpointRestore(t *testing.T) {
 			}
 			defer cont2.Destroy()
 
-			if err := cont2.Restore(spec, conf, imagePath); err != nil {
+			// The checkpoint comes from a different container ID, so it can only
+			// be restored with the ID remapped.
+			if err := cont2.Restore(spec, conf, imagePath, false /* remapID */); err == nil {
+				t.Fatalf("restoring container with a different ID succeeded without remapping")
+			}
+			if err := cont2.Restore(spec, conf, imagePath, true /* remapID */); err != nil {
 				t.Fatalf("error restoring container: %v", err)
 			}
 
@@ -1155,6 +1162,15 @@ func TestCheckpointRestore(t *testing.T) {
 			if lastNum+1 != firstNum {
 				t.Errorf("error numbers not in order, previous: %d, next: %d", lastNum, firstNum)
 			}
+
+			// The restored processes must belong to the new container ID.
+			procs, err := cont2.Processes()
+			if err != nil {
+				t.Fatalf("error getting processes: %v", err)
+			}
+			if len(procs) == 0 {
+				t.Errorf("no processes found for restored container %q", cont2.ID)
+			}
 			cont2.Destroy()
 
 			// Restore into another container!
@@ -1180,7 +1196,7 @@ func TestCheckpointRestore(t *testing.T) {
 			}
 			defer cont3.Destroy()
 
-			if err := cont3.Restore(spec, conf, imagePath); err != nil {
+			if err := cont3.Restore(spec, conf, imagePath, true /* remapID */); err != nil {
 				t.Fatalf("error restoring container: %v", err)
 			}
 
@@ -1315,7 +1331,7 @@ func TestUnixDomainSockets(t *testing.T) {
 			}
 			defer contRestore.Destroy()
 
-			if err := contRestore.Restore(spec, conf, imagePath); err != nil {
+			if err := contRestore.Restore(spec, conf, imagePath, true /* remapID */); err != nil {
 				t.Fatalf("error restoring container: %v", err)
 			}
 
@@ -2085,25 +2101,19 @@ func TestWaitOnExitedSandbox(t *testing.T) {
 	}
 }
 
-func TestDestroyNotStarted(t *testing.T) {
-	doDestroyNotStartedTest(t, false)
-}
-
-func TestDestroyNotStartedVFS2(t *testing.T) {
-	doDestroyNotStartedTest(t, true)
-}
-
-func doDestroyNotStartedTest(t *testing.T, vfs2 bool) {
-	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+// TestWaitWithUsage checks that the resource usage of the container's init
+// process is returned along with its exit status.
+func TestWaitWithUsage(t *testing.T) {
+	const wantExit = 3
+	cmd := fmt.Sprintf("i=0; while [ $i -lt 100000 ]; do i=$((i+1)); done; exit %d", wantExit)
+	spec := testutil.NewSpecWithArgs("/bin/sh", "-c", cmd)
 	conf := testutil.TestConfig(t)
-	conf.VFS2 = vfs2
 	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
 	if err != nil {
 		t.Fatalf("error setting up container: %v", err)
 	}
 	defer cleanup()
 
-	// Create the container and check that it can be destroyed.
 	args := Args{
 		ID:        testutil.RandomContainerID(),
 		Spec:      spec,
@@ -2113,141 +2123,158 @@ func doDestroyNotStartedTest(t *testing.T, vfs2 bool) {
 	if err != nil {
 		t.Fatalf("error creating container: %v", err)
 	}
-	if err := c.Destroy(); err != nil {
-		t.Fatalf("deleting non-started container failed: %v", err)
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
 	}
-}
-
-// TestDestroyStarting attempts to force a race between start and destroy.
-func TestDestroyStarting(t *testing.T) {
-	doDestroyStartingTest(t, false)
-}
 
-func TestDestroyStartedVFS2(t *testing.T) {
-	doDestroyStartingTest(t, true)
+	ws, ru, err := c.WaitWithUsage()
+	if err != nil {
+		t.Fatalf("error waiting on container: %v", err)
+	}
+	if got := ws.ExitStatus(); got != wantExit {
+		t.Errorf("got exit status %d, want %d", got, wantExit)
+	}
+	if ru.Utime.Nano()+ru.Stime.Nano() == 0 {
+		t.Errorf("got zero CPU time, rusage: %+v", ru)
+	}
+	if ru.Maxrss == 0 {
+		t.Errorf("got zero max RSS, rusage: %+v", ru)
+	}
 }
 
-func doDestroyStartingTest(t *testing.T, vfs2 bool) {
-	for i := 0; i < 10; i++ {
-		spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
-		conf := testutil.TestConfig(t)
-		conf.VFS2 = vfs2
-		rootDir, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
-		if err != nil {
-			t.Fatalf("error setting up container: %v", err)
-		}
-		defer cleanup()
+func TestReloadSeccomp(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	conf.OCISeccomp = true
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
 
-		// Create the container and check that it can be destroyed.
-		args := Args{
-			ID:        testutil.RandomContainerID(),
-			Spec:      spec,
-			BundleDir: bundleDir,
-		}
-		c, err := New(conf, args)
-		if err != nil {
-			t.Fatalf("error creating container: %v", err)
-		}
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
 
-		// Container is not thread safe, so load another instance to run in
-		// concurrently.
-		startCont, err := Load(rootDir, FullID{ContainerID: args.ID}, LoadOpts{})
+	seccompMode := func() string {
+		t.Helper()
+		out, err := executeCombinedOutput(conf, c, "/bin/sh", "-c", "grep Seccomp: /proc/1/status")
 		if err != nil {
-			t.Fatalf("error loading container: %v", err)
+			t.Fatalf("exec failed: %v, out: %s", err, out)
 		}
-		wg := sync.WaitGroup{}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			// Ignore failures, start can fail if destroy runs first.
-			_ = startCont.Start(conf)
-		}()
+		return strings.TrimSpace(string(out))
+	}
+	if got, want := seccompMode(), "Seccomp:\t0"; got != want {
+		t.Fatalf("before reload got %q, want %q", got, want)
+	}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := c.Destroy(); err != nil {
-				t.Errorf("deleting non-started container failed: %v", err)
-			}
-		}()
-		wg.Wait()
+	profile := specs.LinuxSeccomp{
+		DefaultAction: specs.ActAllow,
+		Syscalls: []specs.LinuxSyscall{
+			{
+				Names:  []string{"uname"},
+				Action: specs.ActErrno,
+			},
+		},
+	}
+	if err := c.ReloadSeccomp(profile); err != nil {
+		t.Fatalf("ReloadSeccomp(): %v", err)
+	}
+	if got, want := seccompMode(), "Seccomp:\t2"; got != want {
+		t.Errorf("after reload got %q, want %q", got, want)
 	}
 }
 
-func TestCreateWorkingDir(t *testing.T) {
-	for name, conf := range configs(t, all...) {
-		t.Run(name, func(t *testing.T) {
-			tmpDir, err := ioutil.TempDir(testutil.TmpDir(), "cwd-create")
-			if err != nil {
-				t.Fatalf("ioutil.TempDir() failed: %v", err)
-			}
-			dir := path.Join(tmpDir, "new/working/dir")
+// TestUpdateNoCgroup checks that updating resources fails when the sandbox
+// has no cgroup, as is the case in tests.
+func TestUpdateNoCgroup(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
 
-			// touch will fail if the directory doesn't exist.
-			spec := testutil.NewSpecWithArgs("/bin/touch", path.Join(dir, "file"))
-			spec.Process.Cwd = dir
-			spec.Root.Readonly = true
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if c.Sandbox.Cgroup != nil {
+		t.Skipf("sandbox has a cgroup: %+v", c.Sandbox.Cgroup)
+	}
 
-			if err := run(spec, conf); err != nil {
-				t.Fatalf("Error running container: %v", err)
-			}
-		})
+	limit := int64(64 << 20)
+	res := &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: &limit}}
+	if err := c.Update(conf, res); err == nil {
+		t.Fatalf("Update() succeeded without a cgroup")
+	}
+	if c.Spec.Linux != nil && c.Spec.Linux.Resources == res {
+		t.Errorf("failed Update() changed the spec")
 	}
 }
 
-// TestMountPropagation verifies that mount propagates to slave but not to
-// private mounts.
-func TestMountPropagation(t *testing.T) {
-	// Setup dir structure:
-	//   - src: is mounted as shared and is used as source for both private and
-	//     slave mounts
-	//   - dir: will be bind mounted inside src and should propagate to slave
-	tmpDir, err := ioutil.TempDir(testutil.TmpDir(), "mount")
+// TestSetCPUBurstNoCgroup checks that a CPU burst can't be set when the
+// sandbox has no cgroup, as is the case in tests.
+func TestSetCPUBurstNoCgroup(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
 	if err != nil {
-		t.Fatalf("ioutil.TempDir() failed: %v", err)
+		t.Fatalf("error setting up container: %v", err)
 	}
-	src := filepath.Join(tmpDir, "src")
-	srcMnt := filepath.Join(src, "mnt")
-	dir := filepath.Join(tmpDir, "dir")
-	for _, path := range []string{src, srcMnt, dir} {
-		if err := os.MkdirAll(path, 0777); err != nil {
-			t.Fatalf("MkdirAll(%q): %v", path, err)
-		}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
 	}
-	dirFile := filepath.Join(dir, "file")
-	f, err := os.Create(dirFile)
+	c, err := New(conf, args)
 	if err != nil {
-		t.Fatalf("os.Create(%q): %v", dirFile, err)
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if c.Sandbox.Cgroup != nil {
+		t.Skipf("sandbox has a cgroup: %+v", c.Sandbox.Cgroup)
 	}
-	f.Close()
 
-	// Setup src as a shared mount.
-	if err := unix.Mount(src, src, "bind", unix.MS_BIND, ""); err != nil {
-		t.Fatalf("mount(%q, %q, MS_BIND): %v", dir, srcMnt, err)
+	for _, quota := range []int64{0, -2, 100000} {
+		if err := c.SetCPUBurst(quota); err == nil {
+			t.Errorf("SetCPUBurst(%d) succeeded, want error", quota)
+		}
 	}
-	if err := unix.Mount("", src, "", unix.MS_SHARED, ""); err != nil {
-		t.Fatalf("mount(%q, MS_SHARED): %v", srcMnt, err)
+	if c.CPUBurst != nil {
+		t.Errorf("failed SetCPUBurst() recorded a burst: %+v", c.CPUBurst)
 	}
 
-	spec := testutil.NewSpecWithArgs("sleep", "1000")
-
-	priv := filepath.Join(tmpDir, "priv")
-	slave := filepath.Join(tmpDir, "slave")
-	spec.Mounts = []specs.Mount{
-		{
-			Source:      src,
-			Destination: priv,
-			Type:        "bind",
-			Options:     []string{"private"},
-		},
-		{
-			Source:      src,
-			Destination: slave,
-			Type:        "bind",
-			Options:     []string{"slave"},
-		},
+	// Ending a burst when none is in progress is a no-op.
+	if err := c.EndCPUBurst(); err != nil {
+		t.Errorf("EndCPUBurst() without a burst: %v", err)
 	}
+}
 
+// TestCheckpointPreExecFails checks that a failing pre-checkpoint command
+// aborts the checkpoint and leaves the container running.
+func TestCheckpointPreExecFails(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
 	conf := testutil.TestConfig(t)
 	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
 	if err != nil {
@@ -2260,51 +2287,419 @@ func TestMountPropagation(t *testing.T) {
 		Spec:      spec,
 		BundleDir: bundleDir,
 	}
-	cont, err := New(conf, args)
+	c, err := New(conf, args)
 	if err != nil {
-		t.Fatalf("creating container: %v", err)
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
 	}
-	defer cont.Destroy()
 
-	if err := cont.Start(conf); err != nil {
-		t.Fatalf("starting container: %v", err)
+	file, err := ioutil.TempFile(testutil.TmpDir(), "checkpoint")
+	if err != nil {
+		t.Fatalf("error creating image file: %v", err)
 	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-	// After the container is started, mount dir inside source and check what
-	// happens to both destinations.
-	if err := unix.Mount(dir, srcMnt, "bind", unix.MS_BIND, ""); err != nil {
-		t.Fatalf("mount(%q, %q, MS_BIND): %v", dir, srcMnt, err)
+	opts := CheckpointOpts{
+		PreCheckpointExec: &specs.Process{Args: []string{"/bin/false"}, Cwd: "/"},
+	}
+	if err := c.CheckpointWithOpts(conf, file, opts); err == nil || !strings.Contains(err.Error(), "checkpoint aborted") {
+		t.Fatalf("got CheckpointWithOpts() = %v, want checkpoint aborted error", err)
+	}
+	if fi, err := file.Stat(); err != nil || fi.Size() != 0 {
+		t.Errorf("image file was written: %+v, %v", fi, err)
+	}
+	if _, err := c.Event(); err != nil {
+		t.Errorf("container isn't running after aborted checkpoint: %v", err)
 	}
+}
 
-	// Check that mount didn't propagate to private mount.
-	privFile := filepath.Join(priv, "mnt", "file")
-	if ws, err := execute(conf, cont, "/usr/bin/test", "!", "-f", privFile); err != nil || ws != 0 {
-		t.Fatalf("exec: test ! -f %q, ws: %v, err: %v", privFile, ws, err)
+// TestGoferHealthy checks that the gofer answers pings while the container
+// runs, and is reported unhealthy once it's killed.
+func TestGoferHealthy(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	conf.GoferHealthCheck = true
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
 	}
+	defer cleanup()
 
-	// Check that mount propagated to slave mount.
-	slaveFile := filepath.Join(slave, "mnt", "file")
-	if ws, err := execute(conf, cont, "/usr/bin/test", "-f", slaveFile); err != nil || ws != 0 {
-		t.Fatalf("exec: test -f %q, ws: %v, err: %v", privFile, ws, err)
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
 	}
-}
 
-func TestMountSymlink(t *testing.T) {
-	for name, conf := range configs(t, all...) {
-		t.Run(name, func(t *testing.T) {
-			dir, err := ioutil.TempDir(testutil.TmpDir(), "mount-symlink")
-			if err != nil {
-				t.Fatalf("ioutil.TempDir() failed: %v", err)
-			}
-			defer os.RemoveAll(dir)
+	if ok, err := c.GoferHealthy(); !ok || err != nil {
+		t.Fatalf("got GoferHealthy() = %t, %v, want = true, nil", ok, err)
+	}
 
-			source := path.Join(dir, "source")
-			target := path.Join(dir, "target")
-			for _, path := range []string{source, target} {
-				if err := os.MkdirAll(path, 0777); err != nil {
-					t.Fatalf("os.MkdirAll(): %v", err)
-				}
-			}
+	if err := unix.Kill(c.GoferPid, unix.SIGKILL); err != nil {
+		t.Fatalf("killing gofer: %v", err)
+	}
+	// The gofer is a child of the test, collect it so that its PID is gone.
+	if _, err := unix.Wait4(c.GoferPid, nil, 0, nil); err != nil {
+		t.Fatalf("waiting for gofer: %v", err)
+	}
+	c.goferIsChild = false
+	if ok, err := c.GoferHealthy(); ok || err != nil {
+		t.Errorf("got GoferHealthy() after killing the gofer = %t, %v, want = false, nil", ok, err)
+	}
+}
+
+// TestGoferHealthyNotEnabled checks that the gofer doesn't get a control server
+// unless --gofer-health-check is set.
+func TestGoferHealthyNotEnabled(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+
+	if c.GoferControlAddr != "" {
+		t.Errorf("got GoferControlAddr = %q, want none", c.GoferControlAddr)
+	}
+	if ok, err := c.GoferHealthy(); ok || err == nil {
+		t.Errorf("got GoferHealthy() = %t, %v, want = false, error", ok, err)
+	}
+}
+
+func TestPlatformCapabilities(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+
+	caps, err := c.PlatformCapabilities()
+	if err != nil {
+		t.Fatalf("PlatformCapabilities() failed: %v", err)
+	}
+	if caps.Name != conf.Platform {
+		t.Errorf("got platform name %q, want %q", caps.Name, conf.Platform)
+	}
+	if caps.HardwareVirtualization != (conf.Platform == "kvm") {
+		t.Errorf("got HardwareVirtualization = %t for platform %q", caps.HardwareVirtualization, conf.Platform)
+	}
+	if caps.MinUserAddress >= caps.MaxUserAddress {
+		t.Errorf("got user address range [%#x, %#x), want non-empty range", caps.MinUserAddress, caps.MaxUserAddress)
+	}
+}
+
+func TestExecStream(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	execArgs := &control.ExecArgs{
+		Filename: "/bin/sh",
+		Argv:     []string{"/bin/sh", "-c", "echo out; echo err >&2; exit 3"},
+	}
+	_, stdout, stderr, wait, err := c.ExecStream(conf, execArgs)
+	if err != nil {
+		t.Fatalf("ExecStream() failed: %v", err)
+	}
+	defer stdout.Close()
+	defer stderr.Close()
+
+	for _, tc := range []struct {
+		name string
+		r    io.Reader
+		want string
+	}{
+		{"stdout", stdout, "out\n"},
+		{"stderr", stderr, "err\n"},
+	} {
+		got, err := ioutil.ReadAll(tc.r)
+		if err != nil {
+			t.Fatalf("reading %s: %v", tc.name, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("got %s %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if status, err := wait(); err != nil || status != 3 {
+		t.Errorf("got wait() = %d, %v, want 3, nil", status, err)
+	}
+}
+
+func TestDestroyNotStarted(t *testing.T) {
+	doDestroyNotStartedTest(t, false)
+}
+
+func TestDestroyNotStartedVFS2(t *testing.T) {
+	doDestroyNotStartedTest(t, true)
+}
+
+func doDestroyNotStartedTest(t *testing.T, vfs2 bool) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+	conf := testutil.TestConfig(t)
+	conf.VFS2 = vfs2
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	// Create the container and check that it can be destroyed.
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	if err := c.Destroy(); err != nil {
+		t.Fatalf("deleting non-started container failed: %v", err)
+	}
+}
+
+// TestDestroyStarting attempts to force a race between start and destroy.
+func TestDestroyStarting(t *testing.T) {
+	doDestroyStartingTest(t, false)
+}
+
+func TestDestroyStartedVFS2(t *testing.T) {
+	doDestroyStartingTest(t, true)
+}
+
+func doDestroyStartingTest(t *testing.T, vfs2 bool) {
+	for i := 0; i < 10; i++ {
+		spec := testutil.NewSpecWithArgs("/bin/sleep", "100")
+		conf := testutil.TestConfig(t)
+		conf.VFS2 = vfs2
+		rootDir, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+		if err != nil {
+			t.Fatalf("error setting up container: %v", err)
+		}
+		defer cleanup()
+
+		// Create the container and check that it can be destroyed.
+		args := Args{
+			ID:        testutil.RandomContainerID(),
+			Spec:      spec,
+			BundleDir: bundleDir,
+		}
+		c, err := New(conf, args)
+		if err != nil {
+			t.Fatalf("error creating container: %v", err)
+		}
+
+		// Container is not thread safe, so load another instance to run in
+		// concurrently.
+		startCont, err := Load(rootDir, FullID{ContainerID: args.ID}, LoadOpts{})
+		if err != nil {
+			t.Fatalf("error loading container: %v", err)
+		}
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Ignore failures, start can fail if destroy runs first.
+			_ = startCont.Start(conf)
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Destroy(); err != nil {
+				t.Errorf("deleting non-started container failed: %v", err)
+			}
+		}()
+		wg.Wait()
+	}
+}
+
+func TestCreateWorkingDir(t *testing.T) {
+	for name, conf := range configs(t, all...) {
+		t.Run(name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir(testutil.TmpDir(), "cwd-create")
+			if err != nil {
+				t.Fatalf("ioutil.TempDir() failed: %v", err)
+			}
+			dir := path.Join(tmpDir, "new/working/dir")
+
+			// touch will fail if the directory doesn't exist.
+			spec := testutil.NewSpecWithArgs("/bin/touch", path.Join(dir, "file"))
+			spec.Process.Cwd = dir
+			spec.Root.Readonly = true
+
+			if err := run(spec, conf); err != nil {
+				t.Fatalf("Error running container: %v", err)
+			}
+		})
+	}
+}
+
+// TestMountPropagation verifies that mount propagates to slave but not to
+// private mounts.
+func TestMountPropagation(t *testing.T) {
+	// Setup dir structure:
+	//   - src: is mounted as shared and is used as source for both private and
+	//     slave mounts
+	//   - dir: will be bind mounted inside src and should propagate to slave
+	tmpDir, err := ioutil.TempDir(testutil.TmpDir(), "mount")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed: %v", err)
+	}
+	src := filepath.Join(tmpDir, "src")
+	srcMnt := filepath.Join(src, "mnt")
+	dir := filepath.Join(tmpDir, "dir")
+	for _, path := range []string{src, srcMnt, dir} {
+		if err := os.MkdirAll(path, 0777); err != nil {
+			t.Fatalf("MkdirAll(%q): %v", path, err)
+		}
+	}
+	dirFile := filepath.Join(dir, "file")
+	f, err := os.Create(dirFile)
+	if err != nil {
+		t.Fatalf("os.Create(%q): %v", dirFile, err)
+	}
+	f.Close()
+
+	// Setup src as a shared mount.
+	if err := unix.Mount(src, src, "bind", unix.MS_BIND, ""); err != nil {
+		t.Fatalf("mount(%q, %q, MS_BIND): %v", dir, srcMnt, err)
+	}
+	if err := unix.Mount("", src, "", unix.MS_SHARED, ""); err != nil {
+		t.Fatalf("mount(%q, MS_SHARED): %v", srcMnt, err)
+	}
+
+	spec := testutil.NewSpecWithArgs("sleep", "1000")
+
+	priv := filepath.Join(tmpDir, "priv")
+	slave := filepath.Join(tmpDir, "slave")
+	spec.Mounts = []specs.Mount{
+		{
+			Source:      src,
+			Destination: priv,
+			Type:        "bind",
+			Options:     []string{"private"},
+		},
+		{
+			Source:      src,
+			Destination: slave,
+			Type:        "bind",
+			Options:     []string{"slave"},
+		},
+	}
+
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("creating container: %v", err)
+	}
+	defer cont.Destroy()
+
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("starting container: %v", err)
+	}
+
+	// After the container is started, mount dir inside source and check what
+	// happens to both destinations.
+	if err := unix.Mount(dir, srcMnt, "bind", unix.MS_BIND, ""); err != nil {
+		t.Fatalf("mount(%q, %q, MS_BIND): %v", dir, srcMnt, err)
+	}
+
+	// Check that mount didn't propagate to private mount.
+	privFile := filepath.Join(priv, "mnt", "file")
+	if ws, err := execute(conf, cont, "/usr/bin/test", "!", "-f", privFile); err != nil || ws != 0 {
+		t.Fatalf("exec: test ! -f %q, ws: %v, err: %v", privFile, ws, err)
+	}
+
+	// Check that mount propagated to slave mount.
+	slaveFile := filepath.Join(slave, "mnt", "file")
+	if ws, err := execute(conf, cont, "/usr/bin/test", "-f", slaveFile); err != nil || ws != 0 {
+		t.Fatalf("exec: test -f %q, ws: %v, err: %v", privFile, ws, err)
+	}
+}
+
+func TestMountSymlink(t *testing.T) {
+	for name, conf := range configs(t, all...) {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(testutil.TmpDir(), "mount-symlink")
+			if err != nil {
+				t.Fatalf("ioutil.TempDir() failed: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			source := path.Join(dir, "source")
+			target := path.Join(dir, "target")
+			for _, path := range []string{source, target} {
+				if err := os.MkdirAll(path, 0777); err != nil {
+					t.Fatalf("os.MkdirAll(): %v", err)
+				}
+			}
 			f, err := os.Create(path.Join(source, "file"))
 			if err != nil {
 				t.Fatalf("os.Create(): %v", err)
@@ -2468,47 +2863,341 @@ func TestTTYField(t *testing.T) {
 					t.Fatalf("error waiting for sleep process: %v", err)
 				}
 
-				if gotTTYField != test.wantTTYField {
-					t.Errorf("tty field got %q, want %q", gotTTYField, test.wantTTYField)
-				}
-			})
+				if gotTTYField != test.wantTTYField {
+					t.Errorf("tty field got %q, want %q", gotTTYField, test.wantTTYField)
+				}
+			})
+		}
+	}
+}
+
+// Test that container can run even when there are corrupt state files in the
+// root directiry.
+func TestCreateWithCorruptedStateFile(t *testing.T) {
+	conf := testutil.TestConfig(t)
+	spec := testutil.NewSpecWithArgs("/bin/true")
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	// Create corrupted state file.
+	corruptID := testutil.RandomContainerID()
+	corruptState := buildPath(conf.RootDir, FullID{SandboxID: corruptID, ContainerID: corruptID}, stateFileExtension)
+	if err := ioutil.WriteFile(corruptState, []byte("this{file(is;not[valid.json"), 0777); err != nil {
+		t.Fatalf("createCorruptStateFile(): %v", err)
+	}
+	defer os.Remove(corruptState)
+
+	if _, err := Load(conf.RootDir, FullID{ContainerID: corruptID}, LoadOpts{SkipCheck: true}); err == nil {
+		t.Fatalf("loading corrupted state file should have failed")
+	}
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+		Attached:  true,
+	}
+	if ws, err := Run(conf, args); err != nil {
+		t.Errorf("running container: %v", err)
+	} else if !ws.Exited() || ws.ExitStatus() != 0 {
+		t.Errorf("container failed, waitStatus: %v", ws)
+	}
+}
+
+// TestLabels checks that labels are persisted in the state file and that
+// setting a label doesn't drop labels set through other Container instances.
+func TestLabels(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer cont.Destroy()
+
+	other, err := Load(conf.RootDir, FullID{ContainerID: cont.ID}, LoadOpts{})
+	if err != nil {
+		t.Fatalf("error loading container: %v", err)
+	}
+	if err := cont.SetLabel("foo", "1"); err != nil {
+		t.Fatalf("SetLabel(foo): %v", err)
+	}
+	if err := other.SetLabel("bar", "2"); err != nil {
+		t.Fatalf("SetLabel(bar): %v", err)
+	}
+	if err := cont.SetLabel("", "3"); err == nil {
+		t.Errorf("SetLabel with empty key should have failed")
+	}
+
+	loaded, err := Load(conf.RootDir, FullID{ContainerID: cont.ID}, LoadOpts{})
+	if err != nil {
+		t.Fatalf("error loading container: %v", err)
+	}
+	want := map[string]string{"foo": "1", "bar": "2"}
+	if got := loaded.GetLabels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLabels() = %v, want: %v", got, want)
+	}
+}
+
+// TestAttach checks that a running container's init process output can be
+// redirected with Attach.
+func TestAttach(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("bash", "-c", "while true; do echo attached; sleep 0.1; done")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer cont.Destroy()
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("error opening %q: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("error creating pipe: %v", err)
+	}
+	defer r.Close()
+
+	detach, err := cont.Attach(devNull, w, w)
+	w.Close()
+	if err != nil {
+		t.Fatalf("Attach(): %v", err)
+	}
+	if _, err := cont.Attach(devNull, devNull, devNull); err == nil {
+		t.Errorf("second Attach() should have failed")
+	}
+
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len("attached"))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("error reading attached output: %v", err)
+	}
+	if got := string(buf); got != "attached" {
+		t.Errorf("attached output = %q, want: %q", got, "attached")
+	}
+
+	// Once detached, the sandbox drops its end of the pipe.
+	detach()
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		t.Errorf("error reading until detach: %v", err)
+	}
+}
+
+// TestInitPidFD checks that the file returned by InitPidFD becomes readable
+// when the container's init process exits.
+func TestInitPidFD(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer cont.Destroy()
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	f, err := cont.InitPidFD()
+	if err != nil {
+		t.Fatalf("InitPidFD(): %v", err)
+	}
+	defer f.Close()
+
+	fds := []unix.PollFd{{Fd: int32(f.Fd()), Events: unix.POLLIN}}
+	if n, err := unix.Poll(fds, 0); err != nil || n != 0 {
+		t.Fatalf("poll() before exit = %d, %v, want: 0, nil", n, err)
+	}
+
+	if err := cont.SignalContainer(unix.SIGKILL, false); err != nil {
+		t.Fatalf("error killing container: %v", err)
+	}
+	for {
+		n, err := unix.Poll(fds, 10*1000)
+		if err == unix.EINTR {
+			continue
 		}
+		if err != nil || n != 1 {
+			t.Fatalf("poll() after exit = %d, %v, want: 1, nil", n, err)
+		}
+		break
 	}
 }
 
-// Test that container can run even when there are corrupt state files in the
-// root directiry.
-func TestCreateWithCorruptedStateFile(t *testing.T) {
+// TestNetworkStats checks that NIC statistics are reported for the sandbox
+// network stack.
+func TestNetworkStats(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("sleep", "100")
 	conf := testutil.TestConfig(t)
-	spec := testutil.NewSpecWithArgs("/bin/true")
 	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
 	if err != nil {
 		t.Fatalf("error setting up container: %v", err)
 	}
 	defer cleanup()
 
-	// Create corrupted state file.
-	corruptID := testutil.RandomContainerID()
-	corruptState := buildPath(conf.RootDir, FullID{SandboxID: corruptID, ContainerID: corruptID}, stateFileExtension)
-	if err := ioutil.WriteFile(corruptState, []byte("this{file(is;not[valid.json"), 0777); err != nil {
-		t.Fatalf("createCorruptStateFile(): %v", err)
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer cont.Destroy()
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
 	}
-	defer os.Remove(corruptState)
 
-	if _, err := Load(conf.RootDir, FullID{ContainerID: corruptID}, LoadOpts{SkipCheck: true}); err == nil {
-		t.Fatalf("loading corrupted state file should have failed")
+	stats, err := cont.NetworkStats()
+	if err != nil {
+		t.Fatalf("NetworkStats(): %v", err)
+	}
+	// The test config uses --network=none, which only sets up loopback.
+	if _, ok := stats["lo"]; !ok {
+		t.Errorf("NetworkStats() = %v, want stats for \"lo\"", stats)
+	}
+}
+
+// TestIPTables checks that iptables rules can be dumped from a running sandbox
+// and applied back.
+func TestIPTables(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
 	}
+	defer cleanup()
 
 	args := Args{
 		ID:        testutil.RandomContainerID(),
 		Spec:      spec,
 		BundleDir: bundleDir,
-		Attached:  true,
 	}
-	if ws, err := Run(conf, args); err != nil {
-		t.Errorf("running container: %v", err)
-	} else if !ws.Exited() || ws.ExitStatus() != 0 {
-		t.Errorf("container failed, waitStatus: %v", ws)
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer cont.Destroy()
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	for _, ipv6 := range []bool{false, true} {
+		for _, table := range []string{"filter", "nat"} {
+			want, err := cont.IPTables(table, ipv6)
+			if err != nil {
+				t.Fatalf("IPTables(%q, %t): %v", table, ipv6, err)
+			}
+			if err := cont.SetIPTables(want, ipv6); err != nil {
+				t.Fatalf("SetIPTables(%q, %t): %v", table, ipv6, err)
+			}
+			got, err := cont.IPTables(table, ipv6)
+			if err != nil {
+				t.Fatalf("IPTables(%q, %t): %v", table, ipv6, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("IPTables(%q, %t) after SetIPTables = %v, want: %v", table, ipv6, got, want)
+			}
+		}
+	}
+
+	if err := cont.SetIPTables([]byte{0}, false); err == nil {
+		t.Errorf("SetIPTables() with truncated rules succeeded, want error")
+	}
+	if _, err := cont.IPTables("bogus", false); err == nil {
+		t.Errorf("IPTables(\"bogus\") succeeded, want error")
+	}
+}
+
+// TestCheckpointProgress checks that checkpoint progress is reported and that
+// the final report matches the state file size.
+func TestCheckpointProgress(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("sleep", "100")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer cont.Destroy()
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	file, err := ioutil.TempFile(testutil.TmpDir(), "checkpoint-progress")
+	if err != nil {
+		t.Fatalf("error creating image file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	var last CheckpointProgress
+	if err := cont.CheckpointWithProgress(file, func(p CheckpointProgress) { last = p }); err != nil {
+		t.Fatalf("error checkpointing container: %v", err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatalf("error stating image file: %v", err)
+	}
+	if !last.Done || last.Written != fi.Size() {
+		t.Errorf("last progress = %+v, want Done with Written = %d", last, fi.Size())
+	}
+	if last.Total == 0 {
+		t.Errorf("last progress = %+v, want non-zero Total", last)
 	}
 }
 
@@ -2597,3 +3286,261 @@ func TestRlimitsExec(t *testing.T) {
 		t.Errorf("ulimit result, got: %q, want: %q", got, want)
 	}
 }
+
+// TestGoroutineDump checks that the stacks of all sentry goroutines can be
+// retrieved from a running sandbox.
+func TestGoroutineDump(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "1000")
+	conf := testutil.TestConfig(t)
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	dump, err := c.Sandbox.GoroutineDump()
+	if err != nil {
+		t.Fatalf("GoroutineDump() failed: %v", err)
+	}
+	// The dump includes the goroutine serving the request as well as the
+	// task goroutine running sleep.
+	for _, want := range []string{"runsc/boot.(*debug).GoroutineDump", "pkg/sentry/kernel.(*Task).run"} {
+		if !bytes.Contains(dump, []byte(want)) {
+			t.Errorf("goroutine dump doesn't contain %q:\n%s", want, dump)
+		}
+	}
+}
+
+// TestGC checks that GC destroys containers whose sandbox was killed, and
+// leaves running containers and containers without a sandbox PID alone.
+func TestGC(t *testing.T) {
+	stop := testutil.StartReaper()
+	defer stop()
+
+	conf := testutil.TestConfig(t)
+	var conts []*Container
+	for i := 0; i < 2; i++ {
+		spec := testutil.NewSpecWithArgs("/bin/sleep", "1000")
+		_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+		if err != nil {
+			t.Fatalf("error setting up container: %v", err)
+		}
+		defer cleanup()
+
+		args := Args{
+			ID:        testutil.RandomContainerID(),
+			Spec:      spec,
+			BundleDir: bundleDir,
+		}
+		c, err := New(conf, args)
+		if err != nil {
+			t.Fatalf("error creating container: %v", err)
+		}
+		defer c.Destroy()
+		if err := c.Start(conf); err != nil {
+			t.Fatalf("error starting container: %v", err)
+		}
+		conts = append(conts, c)
+	}
+	dead, alive := conts[0], conts[1]
+
+	// Simulate a container whose sandbox PID hasn't been recorded yet.
+	id := testutil.RandomContainerID()
+	noPid := &Container{
+		ID:     id,
+		Status: Created,
+		Sandbox: &sandbox.Sandbox{
+			ID: id,
+		},
+		Saver: StateFile{
+			RootDir: conf.RootDir,
+			ID:      FullID{SandboxID: id, ContainerID: id},
+		},
+	}
+	if err := noPid.Saver.lockForNew(); err != nil {
+		t.Fatalf("lockForNew() failed: %v", err)
+	}
+	if err := noPid.saveLocked(); err != nil {
+		t.Fatalf("saveLocked() failed: %v", err)
+	}
+	noPid.Saver.unlockOrDie()
+	defer noPid.Saver.destroy()
+
+	if err := unix.Kill(dead.Sandbox.Pid, unix.SIGKILL); err != nil {
+		t.Fatalf("killing sandbox: %v", err)
+	}
+	cb := func() error {
+		if dead.IsSandboxRunning() {
+			return fmt.Errorf("sandbox %d is still running", dead.Sandbox.Pid)
+		}
+		return nil
+	}
+	if err := testutil.Poll(cb, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	cleaned, err := GC(conf.RootDir)
+	if err != nil {
+		t.Fatalf("GC() failed: %v", err)
+	}
+	if want := []string{dead.ID}; !reflect.DeepEqual(cleaned, want) {
+		t.Errorf("GC() = %v, want %v", cleaned, want)
+	}
+	if _, err := Load(conf.RootDir, FullID{ContainerID: dead.ID}, LoadOpts{}); err == nil {
+		t.Errorf("container %q still exists after GC", dead.ID)
+	}
+	for _, c := range []*Container{alive, noPid} {
+		if _, err := Load(conf.RootDir, FullID{ContainerID: c.ID}, LoadOpts{SkipCheck: true}); err != nil {
+			t.Errorf("loading container %q after GC: %v", c.ID, err)
+		}
+	}
+}
+
+// TestGCCreating checks that GC doesn't remove containers that are still being
+// created.
+func TestGCCreating(t *testing.T) {
+	stop := testutil.StartReaper()
+	defer stop()
+
+	conf := testutil.TestConfig(t)
+
+	// Simulate a container that is being created and whose sandbox PID refers
+	// to a process that has already exited.
+	cmd := exec.Command("/bin/true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running %v: %v", cmd.Args, err)
+	}
+	id := testutil.RandomContainerID()
+	creating := &Container{
+		ID:     id,
+		Status: Creating,
+		Sandbox: &sandbox.Sandbox{
+			ID:  id,
+			Pid: cmd.Process.Pid,
+		},
+		Saver: StateFile{
+			RootDir: conf.RootDir,
+			ID:      FullID{SandboxID: id, ContainerID: id},
+		},
+	}
+	if err := creating.Saver.lockForNew(); err != nil {
+		t.Fatalf("lockForNew() failed: %v", err)
+	}
+	if err := creating.saveLocked(); err != nil {
+		t.Fatalf("saveLocked() failed: %v", err)
+	}
+	creating.Saver.unlockOrDie()
+	defer creating.Saver.destroy()
+
+	// Run GC repeatedly while a real container is created.
+	done := make(chan struct{})
+	gcErr := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				gcErr <- nil
+				return
+			default:
+			}
+			if cleaned, err := GC(conf.RootDir); err != nil || len(cleaned) != 0 {
+				gcErr <- fmt.Errorf("GC() = %v, %v, want no containers removed", cleaned, err)
+				return
+			}
+		}
+	}()
+
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "1000")
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	close(done)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := <-gcErr; err != nil {
+		t.Error(err)
+	}
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container %q after GC: %v", c.ID, err)
+	}
+	if _, err := Load(conf.RootDir, FullID{ContainerID: creating.ID}, LoadOpts{SkipCheck: true}); err != nil {
+		t.Errorf("loading container %q after GC: %v", creating.ID, err)
+	}
+}
+
+// TestForkRateLimit checks that --fork-rate-limit throttles process creation.
+// fork-bomb creates a chain of processes that only ends once a fork fails.
+func TestForkRateLimit(t *testing.T) {
+	app, err := testutil.FindFile("test/cmd/test_app/test_app")
+	if err != nil {
+		t.Fatal("error finding test_app:", err)
+	}
+
+	conf := testutil.TestConfig(t)
+	conf.ForkRateLimit = 1
+	spec := testutil.NewSpecWithArgs(app, "fork-bomb")
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	type result struct {
+		ws  unix.WaitStatus
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ws, err := c.Wait()
+		done <- result{ws, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("error waiting for container: %v", r.err)
+		}
+		if r.ws.ExitStatus() == 0 {
+			t.Errorf("fork-bomb exited with status 0, want a failed fork")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("fork-bomb wasn't throttled")
+	}
+}
@@ -834,6 +834,96 @@ func TestMultiContainerProcesses(t *testing.T) {
 	}
 }
 
+// TestMultiContainerRename checks that a sub-container can be renamed while
+// running, and that the root container can't.
+func TestMultiContainerRename(t *testing.T) {
+	rootDir, cleanup, err := testutil.SetupRootDir()
+	if err != nil {
+		t.Fatalf("error creating root dir: %v", err)
+	}
+	defer cleanup()
+
+	conf := testutil.TestConfig(t)
+	conf.RootDir = rootDir
+
+	specs, ids := createSpecs(
+		[]string{"sleep", "100"},
+		[]string{"sleep", "100"})
+	containers, cleanup, err := startContainers(conf, specs, ids)
+	if err != nil {
+		t.Fatalf("error starting containers: %v", err)
+	}
+	defer cleanup()
+
+	if err := containers[0].Rename(testutil.RandomContainerID()); err == nil {
+		t.Errorf("renaming root container should have failed")
+	}
+	if err := containers[1].Rename("invalid/id"); err == nil {
+		t.Errorf("renaming to an invalid ID should have failed")
+	}
+
+	newID := testutil.RandomContainerID()
+	if err := containers[1].Rename(newID); err != nil {
+		t.Fatalf("Rename(%q): %v", newID, err)
+	}
+	if containers[1].ID != newID {
+		t.Errorf("container ID = %q, want: %q", containers[1].ID, newID)
+	}
+
+	// The state file must have moved along with the processes.
+	if _, err := Load(rootDir, FullID{ContainerID: ids[1]}, LoadOpts{}); !os.IsNotExist(err) {
+		t.Errorf("Load(%q) after rename, got: %v, want: not exist", ids[1], err)
+	}
+	loaded, err := Load(rootDir, FullID{ContainerID: newID}, LoadOpts{})
+	if err != nil {
+		t.Fatalf("Load(%q): %v", newID, err)
+	}
+	if loaded.Status != Running {
+		t.Errorf("renamed container status = %v, want: %v", loaded.Status, Running)
+	}
+	expectedPL := []*control.Process{
+		newProcessBuilder().PID(2).Cmd("sleep").Process(),
+	}
+	if err := waitForProcessList(loaded, expectedPL); err != nil {
+		t.Errorf("failed to wait for renamed container processes: %v", err)
+	}
+}
+
+// TestMultiContainerRenameGoferHealthy checks that the gofer of a renamed
+// container can still be health checked.
+func TestMultiContainerRenameGoferHealthy(t *testing.T) {
+	rootDir, cleanup, err := testutil.SetupRootDir()
+	if err != nil {
+		t.Fatalf("error creating root dir: %v", err)
+	}
+	defer cleanup()
+
+	conf := testutil.TestConfig(t)
+	conf.RootDir = rootDir
+	conf.GoferHealthCheck = true
+
+	specs, ids := createSpecs(
+		[]string{"sleep", "100"},
+		[]string{"sleep", "100"})
+	containers, cleanup, err := startContainers(conf, specs, ids)
+	if err != nil {
+		t.Fatalf("error starting containers: %v", err)
+	}
+	defer cleanup()
+
+	newID := testutil.RandomContainerID()
+	if err := containers[1].Rename(newID); err != nil {
+		t.Fatalf("Rename(%q): %v", newID, err)
+	}
+	loaded, err := Load(rootDir, FullID{ContainerID: newID}, LoadOpts{})
+	if err != nil {
+		t.Fatalf("Load(%q): %v", newID, err)
+	}
+	if ok, err := loaded.GoferHealthy(); !ok || err != nil {
+		t.Errorf("got GoferHealthy() after rename = %t, %v, want = true, nil", ok, err)
+	}
+}
+
 // TestMultiContainerKillAll checks that all process that belong to a container
 // are killed when SIGKILL is sent to *all* processes in that container.
 func TestMultiContainerKillAll(t *testing.T) {
@@ -1965,6 +2055,14 @@ func TestMultiContainerEvent(t *testing.T) {
 		if exited := ret.ContainerUsage[containers[2].ID]; exited != 0 {
 			t.Errorf("Exited container should report 0 CPU usage, got: %d", exited)
 		}
+		if exited := ret.ContainerMemoryUsage[containers[2].ID]; exited.RSS != 0 {
+			t.Errorf("Exited container should report 0 RSS, got: %d", exited.RSS)
+		}
+
+		// Running containers have memory mapped.
+		if mem := evt.Data.Memory.Usage; mem.Usage == 0 || mem.Max < mem.Usage {
+			t.Errorf("Wrong memory usage, cid: %q, got: %+v", cont.ID, mem)
+		}
 	}
 
 	// Check that CPU reported by busy container is higher than sleep.
@@ -313,11 +313,59 @@ func (s *StateFile) saveLocked(v interface{}) error {
 	return nil
 }
 
+// renameLocked moves the state file to the one for 'id', saving 'v' to it.
+// The lock for the new ID is acquired before the old one is released, so
+// there is no window where neither is held.
+//
+// Preconditions: lock() must been called before.
+func (s *StateFile) renameLocked(id FullID, v interface{}) error {
+	if !s.flock.Locked() {
+		panic("renameLocked called without lock held")
+	}
+
+	next := &StateFile{RootDir: s.RootDir, ID: id}
+	if err := next.lockForNew(); err != nil {
+		return err
+	}
+	oldID := s.ID
+	s.ID = id
+	if err := s.saveLocked(v); err != nil {
+		s.ID = oldID
+		_ = next.destroy()
+		next.unlockOrDie()
+		_ = next.close()
+		return err
+	}
+
+	// Remove the old files while still holding the old lock, then switch over
+	// to the new one.
+	if err := os.Remove(buildPath(s.RootDir, oldID, stateFileExtension)); err != nil && !os.IsNotExist(err) {
+		log.Warningf("Failed to remove state file for %v: %v", &oldID, err)
+	}
+	if err := os.Remove(buildPath(s.RootDir, oldID, "lock")); err != nil && !os.IsNotExist(err) {
+		log.Warningf("Failed to remove lock file for %v: %v", &oldID, err)
+	}
+	s.unlockOrDie()
+	_ = s.flock.Close()
+	s.flock = next.flock
+	return nil
+}
+
 func (s *StateFile) load(v interface{}) error {
 	if err := s.lock(); err != nil {
 		return err
 	}
 	defer s.unlockOrDie()
+	return s.loadLocked(v)
+}
+
+// loadLocked loads 'v' from the state file.
+//
+// Preconditions: lock() must been called before.
+func (s *StateFile) loadLocked(v interface{}) error {
+	if !s.flock.Locked() {
+		panic("loadLocked called without lock held")
+	}
 
 	metaBytes, err := ioutil.ReadFile(s.statePath())
 	if err != nil {
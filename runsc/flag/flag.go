@@ -26,6 +26,7 @@ type FlagSet = flag.FlagSet
 var (
 	Bool        = flag.Bool
 	CommandLine = flag.CommandLine
+	Duration    = flag.Duration
 	Int         = flag.Int
 	NewFlagSet  = flag.NewFlagSet
 	Parse       = flag.Parse
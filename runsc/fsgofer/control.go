@@ -0,0 +1,79 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsgofer
+
+import (
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/fd"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/p9"
+	"gvisor.dev/gvisor/pkg/unet"
+	"gvisor.dev/gvisor/pkg/urpc"
+)
+
+// Gofer control commands.
+const (
+	// ControllerProbe serves 9P on a socket given by the caller, which can
+	// then check that the gofer handles file system requests.
+	ControllerProbe = "Controller.Probe"
+)
+
+// ControlSocketAddr generates an abstract unix socket name for the control
+// server of the gofer of container id.
+func ControlSocketAddr(id string) string {
+	return fmt.Sprintf("\x00runsc-gofer.%s", id)
+}
+
+// ProbeArgs are the arguments to Controller.Probe.
+type ProbeArgs struct {
+	// FilePayload contains the socket to serve 9P on.
+	urpc.FilePayload
+}
+
+// Controller handles requests sent to the gofer by runsc on the host. It's
+// registered with the gofer's control server.
+type Controller struct{}
+
+// Probe serves 9P on the socket in args until the caller closes it. The
+// socket gets its own read-only attach point for the gofer's root, so that
+// probing doesn't interfere with the sandbox's connections.
+func (*Controller) Probe(args *ProbeArgs, _ *struct{}) error {
+	if len(args.Files) != 1 {
+		return fmt.Errorf("probe requires exactly 1 socket, got %d", len(args.Files))
+	}
+	sockFD, err := fd.NewFromFile(args.Files[0])
+	if err != nil {
+		return fmt.Errorf("dup'ing probe socket: %w", err)
+	}
+	sock, err := unet.NewSocket(sockFD.FD())
+	if err != nil {
+		sockFD.Close()
+		return fmt.Errorf("creating probe socket: %w", err)
+	}
+	sockFD.Release()
+
+	ap, err := NewAttachPoint("/", Config{ROMount: true})
+	if err != nil {
+		sock.Close()
+		return fmt.Errorf("creating attach point: %w", err)
+	}
+	go func() {
+		if err := p9.NewServer(ap).Handle(sock); err != nil {
+			log.Debugf("Probe 9P server exited: %v", err)
+		}
+	}()
+	return nil
+}
@@ -248,6 +248,31 @@ var udsSyscalls = seccomp.SyscallRules{
 	},
 }
 
+// controlServerSyscalls returns the syscalls used by the control server
+// listening on fd.
+func controlServerSyscalls(fd int) seccomp.SyscallRules {
+	return seccomp.SyscallRules{
+		unix.SYS_ACCEPT: []seccomp.Rule{
+			{
+				seccomp.EqualTo(fd),
+			},
+		},
+		unix.SYS_LISTEN: []seccomp.Rule{
+			{
+				seccomp.EqualTo(fd),
+				seccomp.EqualTo(16 /* unet.backlog */),
+			},
+		},
+		unix.SYS_GETSOCKOPT: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_SOCKET),
+				seccomp.EqualTo(unix.SO_PEERCRED),
+			},
+		},
+	}
+}
+
 var xattrSyscalls = seccomp.SyscallRules{
 	unix.SYS_FGETXATTR: {},
 	unix.SYS_FSETXATTR: {},
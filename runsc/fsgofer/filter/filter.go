@@ -37,6 +37,12 @@ func InstallUDSFilters() {
 	allowedSyscalls.Merge(udsSyscalls)
 }
 
+// InstallControlServerFilters extends the allowed syscalls to include those
+// necessary for the control server listening on fd.
+func InstallControlServerFilters(fd int) {
+	allowedSyscalls.Merge(controlServerSyscalls(fd))
+}
+
 // InstallXattrFilters extends the allowed syscalls to include xattr calls that
 // are necessary for Verity enabled file systems.
 func InstallXattrFilters() {
@@ -17,6 +17,7 @@ package sandbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -37,7 +38,9 @@ import (
 	"gvisor.dev/gvisor/pkg/coverage"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/devpts"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/urpc"
 	"gvisor.dev/gvisor/runsc/boot"
@@ -159,8 +162,16 @@ func New(conf *config.Config, args *Args) (*Sandbox, error) {
 	}
 
 	// Wait until the sandbox has booted.
+	if conf.StartupTimeout > 0 {
+		if err := clientSyncFile.SetReadDeadline(time.Now().Add(conf.StartupTimeout)); err != nil {
+			return nil, fmt.Errorf("setting startup timeout for sandbox %q: %v", s.ID, err)
+		}
+	}
 	b := make([]byte, 1)
 	if l, err := clientSyncFile.Read(b); err != nil || l != 1 {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("sandbox %q did not start within --startup-timeout=%v", s.ID, conf.StartupTimeout)
+		}
 		err := fmt.Errorf("waiting for sandbox to start: %v", err)
 		// If the sandbox failed to start, it may be because the binary
 		// permissions were incorrect. Check the bits and return a more helpful
@@ -217,6 +228,15 @@ func (s *Sandbox) StartRoot(spec *specs.Spec, conf *config.Config) error {
 	}
 	defer conn.Close()
 
+	stop := watchStartup(conn, conf.StartupTimeout)
+	err = s.startRoot(conn, conf)
+	if stop() && err != nil {
+		return s.startupTimedOut(conf, err)
+	}
+	return err
+}
+
+func (s *Sandbox) startRoot(conn *urpc.Client, conf *config.Config) error {
 	// Configure the network.
 	if err := setupNetwork(conn, s.Pid, conf); err != nil {
 		return fmt.Errorf("setting up network: %v", err)
@@ -231,6 +251,31 @@ func (s *Sandbox) StartRoot(spec *specs.Spec, conf *config.Config) error {
 	return nil
 }
 
+// watchStartup closes conn if it is still in use after timeout, which fails
+// any call blocked on a sandbox that hangs while starting. A zero timeout
+// disables the watch. The returned function must be called once the startup
+// calls are done; it reports whether conn was closed.
+func watchStartup(conn *urpc.Client, timeout time.Duration) func() bool {
+	if timeout == 0 {
+		return func() bool { return false }
+	}
+	t := time.AfterFunc(timeout, func() {
+		// Client.Close would block behind the in-flight call, so close the
+		// socket directly.
+		conn.Socket.Close()
+	})
+	return func() bool { return !t.Stop() }
+}
+
+// startupTimedOut destroys a sandbox that failed to start within
+// conf.StartupTimeout and returns the corresponding error.
+func (s *Sandbox) startupTimedOut(conf *config.Config, err error) error {
+	if destroyErr := s.destroy(); destroyErr != nil {
+		log.Warningf("error destroying sandbox: %v", destroyErr)
+	}
+	return fmt.Errorf("sandbox %q did not start within --startup-timeout=%v: %v", s.ID, conf.StartupTimeout, err)
+}
+
 // StartSubcontainer starts running a sub-container inside the sandbox.
 func (s *Sandbox) StartSubcontainer(spec *specs.Spec, conf *config.Config, cid string, stdios, goferFiles []*os.File) error {
 	log.Debugf("Start sub-container %q in sandbox %q, PID: %d", cid, s.ID, s.Pid)
@@ -264,8 +309,10 @@ func (s *Sandbox) StartSubcontainer(spec *specs.Spec, conf *config.Config, cid s
 	return nil
 }
 
-// Restore sends the restore call for a container in the sandbox.
-func (s *Sandbox) Restore(cid string, spec *specs.Spec, conf *config.Config, filename string) error {
+// Restore sends the restore call for a container in the sandbox. If remapID is
+// set, the checkpoint may have been taken from a container with a different
+// ID, and the restored tasks are moved to container cid.
+func (s *Sandbox) Restore(cid string, spec *specs.Spec, conf *config.Config, filename string, remapID bool) error {
 	log.Debugf("Restore sandbox %q", s.ID)
 
 	rf, err := os.Open(filename)
@@ -278,7 +325,8 @@ func (s *Sandbox) Restore(cid string, spec *specs.Spec, conf *config.Config, fil
 		FilePayload: urpc.FilePayload{
 			Files: []*os.File{rf},
 		},
-		SandboxID: s.ID,
+		SandboxID:        s.ID,
+		RemapContainerID: remapID,
 	}
 
 	// If the platform needs a device FD we must pass it in.
@@ -295,13 +343,22 @@ func (s *Sandbox) Restore(cid string, spec *specs.Spec, conf *config.Config, fil
 	}
 	defer conn.Close()
 
+	stop := watchStartup(conn, conf.StartupTimeout)
+	err = s.restore(conn, cid, conf, &opt)
+	if stop() && err != nil {
+		return s.startupTimedOut(conf, err)
+	}
+	return err
+}
+
+func (s *Sandbox) restore(conn *urpc.Client, cid string, conf *config.Config, opt *boot.RestoreOpts) error {
 	// Configure the network.
 	if err := setupNetwork(conn, s.Pid, conf); err != nil {
 		return fmt.Errorf("setting up network: %v", err)
 	}
 
 	// Restore the container and start the root container.
-	if err := conn.Call(boot.ContMgrRestore, &opt, nil); err != nil {
+	if err := conn.Call(boot.ContMgrRestore, opt, nil); err != nil {
 		return fmt.Errorf("restoring container %q: %v", cid, err)
 	}
 
@@ -372,6 +429,225 @@ func (s *Sandbox) Event(cid string) (*boot.EventOut, error) {
 	return &e, nil
 }
 
+// Attach replaces the stdio of the init process of container 'cid' with the
+// given files.
+func (s *Sandbox) Attach(cid string, stdin, stdout, stderr *os.File) error {
+	log.Debugf("Attach to container %q in sandbox %q", cid, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.AttachArgs{
+		CID:         cid,
+		FilePayload: urpc.FilePayload{Files: []*os.File{stdin, stdout, stderr}},
+	}
+	if err := conn.Call(boot.ContMgrAttach, &args, nil); err != nil {
+		return fmt.Errorf("attaching to container %q: %v", cid, err)
+	}
+	return nil
+}
+
+// Detach restores the stdio of the init process of container 'cid' that was
+// replaced by Attach.
+func (s *Sandbox) Detach(cid string) error {
+	log.Debugf("Detach from container %q in sandbox %q", cid, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Call(boot.ContMgrDetach, &cid, nil); err != nil {
+		return fmt.Errorf("detaching from container %q: %v", cid, err)
+	}
+	return nil
+}
+
+// RenameContainer changes the ID of container 'cid' to 'newCID'.
+func (s *Sandbox) RenameContainer(cid, newCID string) error {
+	log.Debugf("Rename container %q to %q in sandbox %q", cid, newCID, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.RenameArgs{
+		CID:    cid,
+		NewCID: newCID,
+	}
+	if err := conn.Call(boot.ContMgrRename, &args, nil); err != nil {
+		return fmt.Errorf("renaming container %q to %q: %v", cid, newCID, err)
+	}
+	return nil
+}
+
+// BootTimings retrieves the phases of the sandbox startup recorded by the
+// sentry.
+func (s *Sandbox) BootTimings() ([]boot.BootPhase, error) {
+	log.Debugf("Getting boot timings for sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var phases []boot.BootPhase
+	if err := conn.Call(boot.ContMgrBootTimings, nil, &phases); err != nil {
+		return nil, fmt.Errorf("retrieving boot timings from sandbox: %v", err)
+	}
+	return phases, nil
+}
+
+// PlatformCapabilities retrieves the features of the platform that the sandbox
+// runs on.
+func (s *Sandbox) PlatformCapabilities() (*boot.PlatformCaps, error) {
+	log.Debugf("Getting platform capabilities for sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var caps boot.PlatformCaps
+	if err := conn.Call(boot.ContMgrPlatformCapabilities, nil, &caps); err != nil {
+		return nil, fmt.Errorf("retrieving platform capabilities from sandbox: %v", err)
+	}
+	return &caps, nil
+}
+
+// Terminals retrieves the terminals that have open file descriptions in the
+// sandbox, ordered by index.
+func (s *Sandbox) Terminals() ([]devpts.TerminalInfo, error) {
+	log.Debugf("Getting terminals for sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var terms []devpts.TerminalInfo
+	if err := conn.Call(boot.ContMgrTerminals, nil, &terms); err != nil {
+		return nil, fmt.Errorf("retrieving terminals from sandbox: %v", err)
+	}
+	return terms, nil
+}
+
+// NetworkStats retrieves per-NIC network statistics from the sandbox.
+func (s *Sandbox) NetworkStats() (map[string]boot.NICStats, error) {
+	log.Debugf("Getting network stats for sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var stats map[string]boot.NICStats
+	if err := conn.Call(boot.ContMgrNetworkStats, nil, &stats); err != nil {
+		return nil, fmt.Errorf("retrieving network stats from sandbox: %v", err)
+	}
+	return stats, nil
+}
+
+// IPTables returns the rules of a table of the sandbox network stack, in the
+// format of an IPT_SO_SET_REPLACE payload.
+func (s *Sandbox) IPTables(table string, ipv6 bool) ([]byte, error) {
+	log.Debugf("Getting iptables table %q for sandbox %q", table, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	args := boot.IPTablesArgs{
+		Table: table,
+		IPv6:  ipv6,
+	}
+	var replace []byte
+	if err := conn.Call(boot.ContMgrIPTables, &args, &replace); err != nil {
+		return nil, fmt.Errorf("retrieving iptables from sandbox: %v", err)
+	}
+	return replace, nil
+}
+
+// SetIPTables replaces a table of the sandbox network stack with the rules in
+// replace, an IPT_SO_SET_REPLACE payload.
+func (s *Sandbox) SetIPTables(replace []byte, ipv6 bool) error {
+	log.Debugf("Setting iptables for sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.SetIPTablesArgs{
+		Replace: replace,
+		IPv6:    ipv6,
+	}
+	if err := conn.Call(boot.ContMgrSetIPTables, &args, nil); err != nil {
+		return fmt.Errorf("setting iptables in sandbox: %v", err)
+	}
+	return nil
+}
+
+// ReloadSeccomp installs a new OCI seccomp profile for all processes in
+// container cid. See boot.ReloadSeccompArgs.
+func (s *Sandbox) ReloadSeccomp(cid string, profile *specs.LinuxSeccomp, override bool) error {
+	log.Debugf("Reloading seccomp profile for container %q in sandbox %q, override: %t", cid, s.ID, override)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.ReloadSeccompArgs{
+		CID:      cid,
+		Seccomp:  profile,
+		Override: override,
+	}
+	if err := conn.Call(boot.ContMgrReloadSeccomp, &args, nil); err != nil {
+		return fmt.Errorf("reloading seccomp profile in sandbox: %v", err)
+	}
+	return nil
+}
+
+// UpdateResources applies res to the sandbox cgroup, and informs the sandbox
+// of the new memory limit so that it's reflected in the total memory reported
+// to applications.
+func (s *Sandbox) UpdateResources(res *specs.LinuxResources) error {
+	log.Debugf("Updating resources for sandbox %q", s.ID)
+	if s.Cgroup == nil {
+		return fmt.Errorf("sandbox %q has no cgroup", s.ID)
+	}
+	if err := s.Cgroup.Update(res); err != nil {
+		return fmt.Errorf("updating cgroup: %w", err)
+	}
+
+	mem, err := s.Cgroup.MemoryLimit()
+	if err != nil {
+		return fmt.Errorf("getting memory limit from cgroups: %v", err)
+	}
+	// When memory limit is unset, a "large" number is returned. In that case,
+	// lift the limit.
+	if mem >= 0x7ffffffffffff000 {
+		mem = 0
+	}
+
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.UpdateResourcesArgs{TotalMem: mem}
+	if err := conn.Call(boot.ContMgrUpdateResources, &args, nil); err != nil {
+		return fmt.Errorf("updating resources in sandbox: %v", err)
+	}
+	return nil
+}
+
 func (s *Sandbox) sandboxConnect() (*urpc.Client, error) {
 	log.Debugf("Connecting to sandbox %q", s.ID)
 	conn, err := client.ConnectTo(boot.ControlSocketAddr(s.ID))
@@ -418,14 +694,21 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 		}
 	}
 	if conf.DebugLog != "" {
-		debugLogFile, err := specutils.DebugLogFile(conf.DebugLog, "boot", test)
+		rotate := 0
+		if conf.LogRotateSize > 0 {
+			rotate = conf.LogRotateCount
+		}
+		debugLogFiles, err := specutils.DebugLogFiles(conf.DebugLog, "boot", test, rotate)
 		if err != nil {
 			return fmt.Errorf("opening debug log file in %q: %v", conf.DebugLog, err)
 		}
-		defer debugLogFile.Close()
-		cmd.ExtraFiles = append(cmd.ExtraFiles, debugLogFile)
+		// Rotated files, if any, are donated right after the debug log FD.
 		cmd.Args = append(cmd.Args, "--debug-log-fd="+strconv.Itoa(nextFD))
-		nextFD++
+		for _, f := range debugLogFiles {
+			defer f.Close()
+			cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+			nextFD++
+		}
 	}
 	if conf.PanicLog != "" {
 		panicLogFile, err := specutils.DebugLogFile(conf.PanicLog, "panic", test)
@@ -475,7 +758,14 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 	cmd.Args = append(cmd.Args, "--mounts-fd="+strconv.Itoa(nextFD))
 	nextFD++
 
-	specFile, err := specutils.OpenSpec(args.BundleDir)
+	var specFile *os.File
+	if conf.EnvPassthrough != "" {
+		// The bundle's config.json lacks the passed through environment
+		// variables, send the spec that has them instead.
+		specFile, err = specutils.SpecToFile(args.Spec)
+	} else {
+		specFile, err = specutils.OpenSpec(args.BundleDir)
+	}
 	if err != nil {
 		return err
 	}
@@ -701,8 +991,10 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 	// shown as `exe`.
 	cmd.Args[0] = "runsc-sandbox"
 
+	cpuNum := 0
 	if s.Cgroup != nil {
-		cpuNum, err := s.Cgroup.NumCPU()
+		var err error
+		cpuNum, err = s.Cgroup.NumCPU()
 		if err != nil {
 			return fmt.Errorf("getting cpu count from cgroups: %v", err)
 		}
@@ -726,7 +1018,6 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 				}
 			}
 		}
-		cmd.Args = append(cmd.Args, "--cpu-num", strconv.Itoa(cpuNum))
 
 		mem, err := s.Cgroup.MemoryLimit()
 		if err != nil {
@@ -738,6 +1029,22 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 			cmd.Args = append(cmd.Args, "--total-memory", strconv.FormatUint(mem, 10))
 		}
 	}
+	if conf.CPUAffinity != "" {
+		// The sandbox process pins itself to the CPUs during boot. Check them
+		// here to report errors early, and don't create more CPUs in the
+		// sandbox than it can run on. This applies on top of
+		// --cpu-num-from-quota.
+		cpus, err := specutils.CPUAffinity(conf.CPUAffinity)
+		if err != nil {
+			return fmt.Errorf("invalid CPU affinity: %v", err)
+		}
+		if cpuNum == 0 || len(cpus) < cpuNum {
+			cpuNum = len(cpus)
+		}
+	}
+	if cpuNum > 0 {
+		cmd.Args = append(cmd.Args, "--cpu-num", strconv.Itoa(cpuNum))
+	}
 
 	if args.UserLog != "" {
 		f, err := os.OpenFile(args.UserLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
@@ -850,6 +1157,30 @@ func (s *Sandbox) Wait(cid string) (unix.WaitStatus, error) {
 	return s.status, nil
 }
 
+// WaitWithUsage waits for the containerized process to exit, and returns its
+// WaitStatus and resource usage. Unlike Wait, it fails if the sandbox exits
+// before the usage can be collected.
+func (s *Sandbox) WaitWithUsage(cid string) (unix.WaitStatus, *unix.Rusage, error) {
+	log.Debugf("Waiting with usage for container %q in sandbox %q", cid, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return unix.WaitStatus(0), nil, err
+	}
+	defer conn.Close()
+
+	var res boot.WaitWithUsageResult
+	if err := conn.Call(boot.ContMgrWaitWithUsage, &cid, &res); err != nil {
+		return unix.WaitStatus(0), nil, fmt.Errorf("waiting on container %q in sandbox %q: %v", cid, s.ID, err)
+	}
+	conn.Close()
+	if s.IsRootContainer(cid) {
+		if err := s.waitForStopped(); err != nil {
+			return unix.WaitStatus(0), nil, err
+		}
+	}
+	return unix.WaitStatus(res.WaitStatus), &res.Rusage, nil
+}
+
 // WaitPID waits for process 'pid' in the container's sandbox and returns its
 // WaitStatus.
 func (s *Sandbox) WaitPID(cid string, pid int32) (unix.WaitStatus, error) {
@@ -951,8 +1282,9 @@ func (s *Sandbox) SignalProcess(cid string, pid int32, sig unix.Signal, fgProces
 }
 
 // Checkpoint sends the checkpoint call for a container in the sandbox.
-// The statefile will be written to f.
-func (s *Sandbox) Checkpoint(cid string, f *os.File) error {
+// The statefile will be written to f. If resume is set, the sandbox keeps
+// running after the checkpoint, otherwise it exits.
+func (s *Sandbox) Checkpoint(cid string, f *os.File, resume bool) error {
 	log.Debugf("Checkpoint sandbox %q", s.ID)
 	conn, err := s.sandboxConnect()
 	if err != nil {
@@ -961,6 +1293,7 @@ func (s *Sandbox) Checkpoint(cid string, f *os.File) error {
 	defer conn.Close()
 
 	opt := control.SaveOpts{
+		Resume: resume,
 		FilePayload: urpc.FilePayload{
 			Files: []*os.File{f},
 		},
@@ -1014,20 +1347,63 @@ func (s *Sandbox) IsRunning() bool {
 	return false
 }
 
-// Stacks collects and returns all stacks for the sandbox.
-func (s *Sandbox) Stacks() (string, error) {
-	log.Debugf("Stacks sandbox %q", s.ID)
+// GoroutineDump collects the stacks of all goroutines in the sandbox.
+func (s *Sandbox) GoroutineDump() ([]byte, error) {
+	log.Debugf("Goroutine dump sandbox %q", s.ID)
 	conn, err := s.sandboxConnect()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer conn.Close()
 
-	var stacks string
-	if err := conn.Call(boot.DebugStacks, nil, &stacks); err != nil {
-		return "", fmt.Errorf("getting sandbox %q stacks: %v", s.ID, err)
+	var dump []byte
+	if err := conn.Call(boot.DebugGoroutineDump, nil, &dump); err != nil {
+		return nil, fmt.Errorf("getting sandbox %q goroutine dump: %v", s.ID, err)
 	}
-	return stacks, nil
+	return dump, nil
+}
+
+// Devices lists the devices registered in the sandbox.
+func (s *Sandbox) Devices() ([]vfs.DeviceInfo, error) {
+	log.Debugf("Devices sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var devices []vfs.DeviceInfo
+	if err := conn.Call(boot.DebugDevices, nil, &devices); err != nil {
+		return nil, fmt.Errorf("getting sandbox %q devices: %v", s.ID, err)
+	}
+	return devices, nil
+}
+
+// SetPacketLogging starts or stops logging packets that traverse the sandbox
+// NICs. If pcapPath is set, packets are written to it in PCAP format,
+// otherwise they are written to the sandbox log.
+func (s *Sandbox) SetPacketLogging(enabled bool, pcapPath string) error {
+	log.Debugf("Set packet logging %q, enabled: %t, path: %q", s.ID, enabled, pcapPath)
+	args := boot.PacketLoggingArgs{Enabled: enabled}
+	if enabled && pcapPath != "" {
+		f, err := os.OpenFile(pcapPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("opening PCAP file %q: %v", pcapPath, err)
+		}
+		defer f.Close()
+		args.FilePayload = urpc.FilePayload{Files: []*os.File{f}}
+	}
+
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Call(boot.DebugSetPacketLogging, &args, nil); err != nil {
+		return fmt.Errorf("setting sandbox %q packet logging: %v", s.ID, err)
+	}
+	return nil
 }
 
 // HeapProfile writes a heap profile to the given file.
@@ -0,0 +1,70 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/test/testutil"
+	"gvisor.dev/gvisor/pkg/unet"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// TestStartRootTimeout checks that StartRoot gives up on a sandbox whose
+// control server accepts connections but never answers.
+func TestStartRootTimeout(t *testing.T) {
+	s := &Sandbox{ID: testutil.RandomContainerID()}
+
+	// Stand in for a sandbox that hangs while starting.
+	server, err := unet.BindAndListen(boot.ControlSocketAddr(s.ID), false)
+	if err != nil {
+		t.Fatalf("unet.BindAndListen failed: %v", err)
+	}
+	defer server.Close()
+	go func() {
+		conn, err := server.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Hold the connection open without replying.
+		var b [1]byte
+		for {
+			if _, err := conn.Read(b[:]); err != nil {
+				return
+			}
+		}
+	}()
+
+	conf := &config.Config{
+		Network:        config.NetworkNone,
+		StartupTimeout: 100 * time.Millisecond,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.StartRoot(nil, conf)
+	}()
+	select {
+	case err := <-errCh:
+		if err == nil || !strings.Contains(err.Error(), "--startup-timeout") {
+			t.Errorf("StartRoot got error %v, want startup timeout", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("StartRoot did not return after --startup-timeout=%v", conf.StartupTimeout)
+	}
+}
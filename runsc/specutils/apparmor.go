@@ -0,0 +1,84 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package specutils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// appArmorProfilesPath lists the AppArmor profiles loaded in the kernel, one
+// per line in the form "<name> (<mode>)".
+const appArmorProfilesPath = "/sys/kernel/security/apparmor/profiles"
+
+// CheckAppArmorProfile returns an error if AppArmor isn't enabled on the host
+// or if profile isn't loaded.
+func CheckAppArmorProfile(profile string) error {
+	f, err := os.Open(appArmorProfilesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("AppArmor is not enabled on the host, cannot apply profile %q", profile)
+		}
+		return fmt.Errorf("reading loaded AppArmor profiles: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if appArmorProfileName(scanner.Text()) == profile {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading loaded AppArmor profiles: %v", err)
+	}
+	return fmt.Errorf("AppArmor profile %q is not loaded", profile)
+}
+
+// InAppArmorProfile returns true if the current process is confined to the
+// given AppArmor profile.
+func InAppArmorProfile(profile string) bool {
+	current, err := ioutil.ReadFile("/proc/self/attr/current")
+	if err != nil {
+		return false
+	}
+	return appArmorProfileName(strings.TrimRight(string(current), "\x00\n")) == profile
+}
+
+// SetAppArmorProfileOnExec sets the AppArmor profile that the current thread
+// transitions to on its next execve(2), like aa_change_onexec(3).
+//
+// Preconditions: The OS thread is locked.
+func SetAppArmorProfileOnExec(profile string) error {
+	path := fmt.Sprintf("/proc/self/task/%d/attr/exec", unix.Gettid())
+	if err := ioutil.WriteFile(path, []byte("exec "+profile), 0); err != nil {
+		return fmt.Errorf("setting AppArmor profile %q on exec: %v", profile, err)
+	}
+	return nil
+}
+
+// appArmorProfileName strips the mode suffix, e.g. " (enforce)", from a
+// profile label.
+func appArmorProfileName(label string) string {
+	if i := strings.LastIndex(label, " ("); i >= 0 {
+		return label[:i]
+	}
+	return label
+}
@@ -419,6 +419,42 @@ func WaitForReady(pid int, timeout time.Duration, ready func() (bool, error)) er
 //	 - %COMMAND%: is replaced with 'command'
 //	 - %TEST%: is replaced with 'test' (omitted by default)
 func DebugLogFile(logPattern, command, test string) (*os.File, error) {
+	name, err := debugLogPath(logPattern, command, test)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
+}
+
+// DebugLogFiles is like DebugLogFile, but also opens 'rotate' additional files
+// named <log>.1 to <log>.<rotate> to be used for log rotation. The current log
+// file is always the first one returned.
+func DebugLogFiles(logPattern, command, test string, rotate int) ([]*os.File, error) {
+	name, err := debugLogPath(logPattern, command, test)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*os.File, 0, rotate+1)
+	for i := 0; i <= rotate; i++ {
+		path := name
+		if i > 0 {
+			path = fmt.Sprintf("%s.%d", name, i)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// debugLogPath resolves 'logPattern' into a file path as described in
+// DebugLogFile, and creates the parent directory.
+func debugLogPath(logPattern, command, test string) (string, error) {
 	if strings.HasSuffix(logPattern, "/") {
 		// Default format: <debug-log>/runsc.log.<yyyymmdd-hhmmss.uuuuuu>.<command>
 		logPattern += "runsc.log.%TIMESTAMP%.%COMMAND%"
@@ -429,9 +465,9 @@ func DebugLogFile(logPattern, command, test string) (*os.File, error) {
 
 	dir := filepath.Dir(logPattern)
 	if err := os.MkdirAll(dir, 0775); err != nil {
-		return nil, fmt.Errorf("error creating dir %q: %v", dir, err)
+		return "", fmt.Errorf("error creating dir %q: %v", dir, err)
 	}
-	return os.OpenFile(logPattern, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
+	return logPattern, nil
 }
 
 // SafeSetupAndMount creates the mount point and calls Mount with the given
@@ -544,6 +580,70 @@ func GetOOMScoreAdj(pid int) (int, error) {
 	return strconv.Atoi(strings.TrimSpace(string(data)))
 }
 
+// CPUAffinity parses the CPU list in affinity, see config.ParseCPUList, and
+// checks that all CPUs in it are online.
+func CPUAffinity(affinity string) ([]int, error) {
+	cpus, err := config.ParseCPUList(affinity)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return nil, fmt.Errorf("reading online CPUs: %v", err)
+	}
+	online, err := config.ParseCPUList(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing online CPUs: %v", err)
+	}
+	onlineSet := make(map[int]struct{}, len(online))
+	for _, cpu := range online {
+		onlineSet[cpu] = struct{}{}
+	}
+	for _, cpu := range cpus {
+		if _, ok := onlineSet[cpu]; !ok {
+			return nil, fmt.Errorf("CPU %d is not online, online CPUs: %s", cpu, strings.TrimSpace(string(data)))
+		}
+	}
+	return cpus, nil
+}
+
+// SetCPUAffinity pins all threads of the current process to cpus. Threads
+// created afterwards inherit the affinity of their creator, and so does the
+// process after execve(2).
+func SetCPUAffinity(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	// New threads may be created while threads are being pinned. Repeat
+	// until all threads have been pinned.
+	pinned := make(map[int]struct{})
+	for {
+		tasks, err := ioutil.ReadDir("/proc/self/task")
+		if err != nil {
+			return err
+		}
+		done := true
+		for _, task := range tasks {
+			tid, err := strconv.Atoi(task.Name())
+			if err != nil {
+				continue
+			}
+			if _, ok := pinned[tid]; ok {
+				continue
+			}
+			done = false
+			if err := unix.SchedSetaffinity(tid, &set); err != nil && err != unix.ESRCH {
+				return fmt.Errorf("setting affinity of thread %d: %v", tid, err)
+			}
+			pinned[tid] = struct{}{}
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
 // EnvVar looks for a varible value in the env slice assuming the following
 // format: "NAME=VALUE".
 func EnvVar(env []string, name string) (string, bool) {
@@ -581,6 +681,50 @@ func ResolveEnvs(envs ...[]string) ([]string, error) {
 	return env, nil
 }
 
+// PassthroughEnv adds the variables listed in names that are set in the
+// current process's environment to the environment of spec's process.
+// Variables already defined by the spec are left untouched, i.e. the spec
+// takes precedence.
+func PassthroughEnv(spec *specs.Spec, names []string) {
+	if spec.Process == nil {
+		return
+	}
+	defined := make(map[string]struct{}, len(spec.Process.Env))
+	for _, env := range spec.Process.Env {
+		defined[strings.SplitN(env, "=", 2)[0]] = struct{}{}
+	}
+	for _, name := range names {
+		if _, ok := defined[name]; ok {
+			continue
+		}
+		if val, ok := os.LookupEnv(name); ok {
+			log.Infof("Passing through environment variable %q", name)
+			spec.Process.Env = append(spec.Process.Env, name+"="+val)
+			defined[name] = struct{}{}
+		}
+	}
+}
+
+// SpecToFile writes spec to an anonymous in-memory file that can be read with
+// ReadSpecFromFile. It's used to pass a spec that was modified after being
+// read from the bundle to child processes.
+func SpecToFile(spec *specs.Spec) (*os.File, error) {
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling spec: %v", err)
+	}
+	fd, err := unix.MemfdCreate("config.json", unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("creating spec file: %v", err)
+	}
+	f := os.NewFile(uintptr(fd), "config.json")
+	if _, err := f.Write(specBytes); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing spec file: %v", err)
+	}
+	return f, nil
+}
+
 // FaqErrorMsg returns an error message pointing to the FAQ.
 func FaqErrorMsg(anchor, msg string) string {
 	return fmt.Sprintf("%s; see https://gvisor.dev/faq#%s for more details", msg, anchor)
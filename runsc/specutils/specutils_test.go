@@ -16,12 +16,15 @@ package specutils
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
 )
 
 func TestWaitForReadyHappy(t *testing.T) {
@@ -269,3 +272,66 @@ func TestSpecInvalid(t *testing.T) {
 		}
 	}
 }
+
+func TestAppArmorProfileName(t *testing.T) {
+	for _, tc := range []struct {
+		label string
+		want  string
+	}{
+		{label: "runsc (enforce)", want: "runsc"},
+		{label: "/usr/bin/foo (complain)", want: "/usr/bin/foo"},
+		{label: "unconfined", want: "unconfined"},
+		{label: "name with (parens) (enforce)", want: "name with (parens)"},
+	} {
+		if got := appArmorProfileName(tc.label); got != tc.want {
+			t.Errorf("appArmorProfileName(%q) = %q, want: %q", tc.label, got, tc.want)
+		}
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		want    unix.Signal
+		wantErr bool
+	}{
+		{name: "SIGTERM", want: unix.SIGTERM},
+		{name: "TERM", want: unix.SIGTERM},
+		{name: "term", want: unix.SIGTERM},
+		{name: "15", want: unix.SIGTERM},
+		{name: "sigkill", want: unix.SIGKILL},
+		{name: "SIGFOO", wantErr: true},
+		{name: "1000", wantErr: true},
+		{name: "", wantErr: true},
+	} {
+		got, err := ParseSignal(tc.name)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseSignal(%q) = %v, want error", tc.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseSignal(%q) = %v, %v, want: %v, nil", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestPassthroughEnv(t *testing.T) {
+	for _, name := range []string{"RUNSC_TEST_PASSED", "RUNSC_TEST_DEFINED", "RUNSC_TEST_NOT_LISTED"} {
+		os.Setenv(name, "host")
+		defer os.Unsetenv(name)
+	}
+
+	spec := &specs.Spec{
+		Process: &specs.Process{
+			Env: []string{"RUNSC_TEST_DEFINED=spec"},
+		},
+	}
+	PassthroughEnv(spec, []string{"RUNSC_TEST_PASSED", "RUNSC_TEST_DEFINED", "RUNSC_TEST_UNSET"})
+
+	want := []string{"RUNSC_TEST_DEFINED=spec", "RUNSC_TEST_PASSED=host"}
+	if !reflect.DeepEqual(spec.Process.Env, want) {
+		t.Errorf("PassthroughEnv() got env: %v, want: %v", spec.Process.Env, want)
+	}
+}
@@ -16,6 +16,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -23,6 +24,7 @@ import (
 	"time"
 
 	"github.com/google/subcommands"
+	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/test/testutil"
 	"gvisor.dev/gvisor/pkg/unet"
 	"gvisor.dev/gvisor/runsc/flag"
@@ -184,3 +186,88 @@ func (fdr *fdReceiver) Execute(ctx context.Context, f *flag.FlagSet, args ...int
 	log.Print("FD RECEIVER exiting successfully")
 	return subcommands.ExitSuccess
 }
+
+// fdHolder opens descriptors until the requested count or the FD limit is
+// reached, and keeps them open.
+type fdHolder struct {
+	count        int
+	fdType       string
+	holdDuration time.Duration
+}
+
+// Name implements subcommands.Command.Name.
+func (*fdHolder) Name() string {
+	return "fd-holder"
+}
+
+// Synopsis implements subcommands.Command.Synopsys.
+func (*fdHolder) Synopsis() string {
+	return "opens descriptors until --count or the FD limit is reached, prints how many were opened and keeps them open"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*fdHolder) Usage() string {
+	return "fd-holder <flags>"
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (fdh *fdHolder) SetFlags(f *flag.FlagSet) {
+	f.IntVar(&fdh.count, "count", 1024, "number of descriptors to open")
+	f.StringVar(&fdh.fdType, "type", "file", "type of descriptors to open: file, socket or pipe")
+	f.DurationVar(&fdh.holdDuration, "hold-duration", 0, "how long to keep the descriptors open. 0 keeps them open until the process is killed")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (fdh *fdHolder) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	var open func() ([]int, error)
+	switch fdh.fdType {
+	case "file":
+		open = func() ([]int, error) {
+			fd, err := unix.Open("/dev/null", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+			return []int{fd}, err
+		}
+	case "socket":
+		open = func() ([]int, error) {
+			fd, err := unix.Socket(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+			return []int{fd}, err
+		}
+	case "pipe":
+		open = func() ([]int, error) {
+			var fds [2]int
+			err := unix.Pipe2(fds[:], unix.O_CLOEXEC)
+			return fds[:], err
+		}
+	default:
+		log.Fatalf("Invalid --type %q, must be file, socket or pipe", fdh.fdType)
+	}
+
+	var held []int
+	for len(held) < fdh.count {
+		fds, err := open()
+		if err == unix.EMFILE || err == unix.ENFILE {
+			log.Printf("Stopped opening descriptors: %v", err)
+			break
+		}
+		if err != nil {
+			log.Fatalf("Opening %s: %v", fdh.fdType, err)
+		}
+		for _, fd := range fds {
+			if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+				log.Fatalf("Descriptor %d is not valid: %v", fd, err)
+			}
+		}
+		held = append(held, fds...)
+	}
+	// A pipe may overshoot the count by one descriptor.
+	for len(held) > fdh.count {
+		unix.Close(held[len(held)-1])
+		held = held[:len(held)-1]
+	}
+	fmt.Printf("opened %d of %d descriptors\n", len(held), fdh.count)
+
+	if fdh.holdDuration == 0 {
+		select {}
+	}
+	time.Sleep(fdh.holdDuration)
+	return subcommands.ExitSuccess
+}
@@ -32,6 +32,7 @@ import (
 
 	"github.com/google/subcommands"
 	"github.com/kr/pty"
+	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/test/testutil"
 	"gvisor.dev/gvisor/runsc/flag"
 )
@@ -40,9 +41,11 @@ func main() {
 	subcommands.Register(subcommands.HelpCommand(), "")
 	subcommands.Register(subcommands.FlagsCommand(), "")
 	subcommands.Register(new(capability), "")
+	subcommands.Register(new(fdHolder), "")
 	subcommands.Register(new(fdReceiver), "")
 	subcommands.Register(new(fdSender), "")
 	subcommands.Register(new(forkBomb), "")
+	subcommands.Register(new(mmapChurn), "")
 	subcommands.Register(new(ptyRunner), "")
 	subcommands.Register(new(reaper), "")
 	subcommands.Register(new(syscall), "")
@@ -58,6 +61,7 @@ func main() {
 type uds struct {
 	fileName   string
 	socketPath string
+	eventFD    int
 }
 
 // Name implements subcommands.Command.Name.
@@ -79,6 +83,7 @@ func (*uds) Usage() string {
 func (c *uds) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.fileName, "file", "", "name of output file")
 	f.StringVar(&c.socketPath, "socket", "", "path to socket")
+	f.IntVar(&c.eventFD, "eventfd", -1, "inherited eventfd that the server signals once it is listening")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -99,7 +104,11 @@ func (c *uds) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{})
 		log.Fatalf("error listening on socket %q: %v", c.socketPath, err)
 	}
 
-	go server(listener, outputFile)
+	var ready func()
+	if c.eventFD >= 0 {
+		ready = func() { signalEventFD(c.eventFD) }
+	}
+	go server(listener, outputFile, ready)
 	for i := 0; ; i++ {
 		conn, err := net.Dial("unix", c.socketPath)
 		if err != nil {
@@ -113,9 +122,15 @@ func (c *uds) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{})
 	}
 }
 
-func server(listener net.Listener, out *os.File) {
+// server accepts connections from listener and prints the data received on
+// each of them to out. If ready isn't nil, it's called once before accepting
+// the first connection.
+func server(listener net.Listener, out *os.File, ready func()) {
 	buf := make([]byte, 16)
 
+	if ready != nil {
+		ready()
+	}
 	for {
 		c, err := listener.Accept()
 		if err != nil {
@@ -130,6 +145,17 @@ func server(listener net.Listener, out *os.File) {
 	}
 }
 
+// signalEventFD adds 1 to the counter of eventfd fd.
+func signalEventFD(fd int) {
+	f := os.NewFile(uintptr(fd), "eventfd")
+	defer f.Close()
+	buf := make([]byte, 8)
+	hostarch.ByteOrder.PutUint64(buf, 1)
+	if _, err := f.Write(buf); err != nil {
+		log.Fatalf("error signaling eventfd %d: %v", fd, err)
+	}
+}
+
 type taskTree struct {
 	depth int
 	width int
@@ -0,0 +1,111 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// mmapChurn maps, touches and unmaps anonymous memory in a loop to stress the
+// memory manager.
+type mmapChurn struct {
+	iterations int
+	regionSize int
+	threads    int
+	dontNeed   bool
+}
+
+// Name implements subcommands.Command.
+func (*mmapChurn) Name() string {
+	return "mmap-churn"
+}
+
+// Synopsis implements subcommands.Command.
+func (*mmapChurn) Synopsis() string {
+	return "repeatedly maps, touches and unmaps anonymous memory and prints the throughput"
+}
+
+// Usage implements subcommands.Command.
+func (*mmapChurn) Usage() string {
+	return "mmap-churn <flags>"
+}
+
+// SetFlags implements subcommands.Command.
+func (c *mmapChurn) SetFlags(f *flag.FlagSet) {
+	f.IntVar(&c.iterations, "iterations", 1000, "number of map/touch/unmap iterations per thread")
+	f.IntVar(&c.regionSize, "region-size", 1<<20, "size in bytes of each mapped region, rounded up to the page size")
+	f.IntVar(&c.threads, "threads", 1, "number of goroutines mapping memory concurrently")
+	f.BoolVar(&c.dontNeed, "dontneed", false, "release the pages with MADV_DONTNEED before unmapping them")
+}
+
+// Execute implements subcommands.Command.
+func (c *mmapChurn) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.iterations <= 0 || c.regionSize <= 0 || c.threads <= 0 {
+		log.Fatalf("Flags must be positive, given: iterations: %d, region-size: %d, threads: %d", c.iterations, c.regionSize, c.threads)
+	}
+	pageSize := os.Getpagesize()
+	size := (c.regionSize + pageSize - 1) / pageSize * pageSize
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < c.threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < c.iterations; j++ {
+				if err := c.churn(size, pageSize); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	maps := c.threads * c.iterations
+	bytes := float64(maps) * float64(size)
+	fmt.Printf("%d regions of %d bytes in %v: %.0f regions/s, %.1f MiB/s\n", maps, size, elapsed, float64(maps)/elapsed.Seconds(), bytes/(1<<20)/elapsed.Seconds())
+	return subcommands.ExitSuccess
+}
+
+// churn maps a region of size bytes, writes to each of its pages and unmaps
+// it.
+func (c *mmapChurn) churn(size, pageSize int) error {
+	m, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return fmt.Errorf("mmap(%d bytes): %v", size, err)
+	}
+	for off := 0; off < size; off += pageSize {
+		m[off] = 1
+	}
+	if c.dontNeed {
+		if err := unix.Madvise(m, unix.MADV_DONTNEED); err != nil {
+			return fmt.Errorf("madvise(MADV_DONTNEED): %v", err)
+		}
+	}
+	if err := unix.Munmap(m); err != nil {
+		return fmt.Errorf("munmap: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <errno.h>
+#include <fcntl.h>
+#include <poll.h>
+#include <signal.h>
+#include <sys/syscall.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#include "gtest/gtest.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+#ifndef SYS_pidfd_send_signal
+#define SYS_pidfd_send_signal 424
+#endif
+
+#ifndef SYS_pidfd_open
+#define SYS_pidfd_open 434
+#endif
+
+int pidfd_open(pid_t pid, unsigned int flags) {
+  return syscall(SYS_pidfd_open, pid, flags);
+}
+
+int pidfd_send_signal(int pidfd, int sig, siginfo_t* info,
+                      unsigned int flags) {
+  return syscall(SYS_pidfd_send_signal, pidfd, sig, info, flags);
+}
+
+// Returns true if the system supports pidfds.
+bool PidfdSupported() {
+  int fd = pidfd_open(getpid(), 0);
+  if (fd < 0) {
+    return errno != ENOSYS;
+  }
+  close(fd);
+  return true;
+}
+
+TEST(PidfdTest, ReadableOnExit) {
+  SKIP_IF(IsRunningWithVFS1() || !PidfdSupported());
+
+  pid_t child = fork();
+  if (child == 0) {
+    pause();
+    _exit(1);
+  }
+  ASSERT_THAT(child, SyscallSucceeds());
+
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(child, 0), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+
+  EXPECT_THAT(fcntl(pidfd.get(), F_GETFD),
+              SyscallSucceedsWithValue(FD_CLOEXEC));
+
+  struct pollfd pfd = {.fd = pidfd.get(), .events = POLLIN};
+  EXPECT_THAT(poll(&pfd, 1, 0), SyscallSucceedsWithValue(0));
+
+  ASSERT_THAT(pidfd_send_signal(pidfd.get(), SIGKILL, nullptr, 0),
+              SyscallSucceeds());
+
+  // The pidfd becomes readable once the child exits, before it's reaped.
+  ASSERT_THAT(RetryEINTR(poll)(&pfd, 1, -1), SyscallSucceedsWithValue(1));
+  EXPECT_EQ(pfd.revents & POLLIN, POLLIN);
+
+  int status;
+  ASSERT_THAT(RetryEINTR(waitpid)(child, &status, 0),
+              SyscallSucceedsWithValue(child));
+  EXPECT_TRUE(WIFSIGNALED(status) && WTERMSIG(status) == SIGKILL)
+      << "status = " << status;
+}
+
+TEST(PidfdTest, InvalidArgs) {
+  SKIP_IF(IsRunningWithVFS1() || !PidfdSupported());
+
+  EXPECT_THAT(pidfd_open(getpid(), O_CLOEXEC), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_open(0, 0), SyscallFailsWithErrno(EINVAL));
+
+  int fd;
+  ASSERT_THAT(fd = pidfd_open(getpid(), 0), SyscallSucceeds());
+  FileDescriptor pidfd(fd);
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), 0, nullptr, 1),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), 0, nullptr, 0),
+              SyscallSucceeds());
+
+  // Only pidfds can be used to send signals.
+  EXPECT_THAT(pidfd_send_signal(STDIN_FILENO, 0, nullptr, 0),
+              SyscallFailsWithErrno(EBADF));
+}
+
+TEST(PidfdTest, NotThreadGroupLeader) {
+  SKIP_IF(IsRunningWithVFS1() || !PidfdSupported());
+
+  ScopedThread t([] {
+    EXPECT_THAT(pidfd_open(gettid(), 0), SyscallFailsWithErrno(EINVAL));
+  });
+  t.Join();
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
@@ -531,6 +531,53 @@ TEST(BasicPtyTest, OpenNOCTTY) {
   ASSERT_THAT(ioctl(replica.get(), TIOCNOTTY), SyscallFailsWithErrno(ENOTTY));
 }
 
+#ifndef TIOCGPTPEER
+#define TIOCGPTPEER _IO('T', 0x41)
+#endif
+
+TEST(BasicPtyTest, GetPeer) {
+  SKIP_IF(IsRunningWithVFS1());
+  FileDescriptor master = ASSERT_NO_ERRNO_AND_VALUE(Open("/dev/ptmx", O_RDWR));
+  int unlock = 0;
+  ASSERT_THAT(ioctl(master.get(), TIOCSPTLCK, &unlock), SyscallSucceeds());
+
+  int fd;
+  ASSERT_THAT(fd = ioctl(master.get(), TIOCGPTPEER,
+                         O_RDWR | O_NOCTTY | O_NONBLOCK | O_CLOEXEC),
+              SyscallSucceeds());
+  FileDescriptor replica(fd);
+
+  // The replica is the same file as /dev/pts/N.
+  int index = -1;
+  ASSERT_THAT(ioctl(master.get(), TIOCGPTN, &index), SyscallSucceeds());
+  struct stat peer_st, path_st;
+  ASSERT_THAT(fstat(replica.get(), &peer_st), SyscallSucceeds());
+  ASSERT_THAT(stat(absl::StrCat("/dev/pts/", index).c_str(), &path_st),
+              SyscallSucceeds());
+  EXPECT_EQ(peer_st.st_dev, path_st.st_dev);
+  EXPECT_EQ(peer_st.st_ino, path_st.st_ino);
+
+  EXPECT_THAT(fcntl(replica.get(), F_GETFD),
+              SyscallSucceedsWithValue(FD_CLOEXEC));
+  EXPECT_THAT(fcntl(replica.get(), F_GETFL),
+              SyscallSucceedsWithValue(O_RDWR | O_NONBLOCK));
+
+  // Data written to the master can be read from the replica.
+  constexpr char kInput[] = "peer\n";
+  ASSERT_THAT(WriteFd(master.get(), kInput, sizeof(kInput) - 1),
+              SyscallSucceedsWithValue(sizeof(kInput) - 1));
+  char buf[sizeof(kInput) - 1] = {};
+  ExpectReadable(replica, sizeof(buf), buf);
+  EXPECT_EQ(0, memcmp(buf, kInput, sizeof(buf)));
+}
+
+TEST(BasicPtyTest, GetPeerInvalidFlags) {
+  SKIP_IF(IsRunningWithVFS1());
+  FileDescriptor master = ASSERT_NO_ERRNO_AND_VALUE(Open("/dev/ptmx", O_RDWR));
+  EXPECT_THAT(ioctl(master.get(), TIOCGPTPEER, O_RDWR | O_CREAT),
+              SyscallFailsWithErrno(EINVAL));
+}
+
 // The replica entry in /dev/pts/ disappears when the master is closed, even if
 // the replica is still open.
 TEST(BasicPtyTest, ReplicaEntryGoneAfterMasterClose) {